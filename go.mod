@@ -0,0 +1,3 @@
+module github.com/ProsperousLi/golang-deep-learn
+
+go 1.23
@@ -0,0 +1,133 @@
+// Batchbench measures building a map from n key/elem pairs known in
+// advance: hmap.FromSlice (one sizing, one hashing loop, buckets filled
+// in address order) against a loop of Put into hmap.New(0) and
+// hmap.New(n), and against the builtin map filled by assignment after
+// make(map[K]V) and make(map[K]V, n). Each benchmark operation builds a
+// whole map; the table reports the time and bytes per entry and the
+// allocations per map, measured with testing.Benchmark.
+//
+// Usage:
+//
+//	batchbench [-sizes 1000,10000,100000,1000000] [-benchtime 500ms]
+//
+// The keys are distinct random ints, so every insert lands on a random
+// bucket. New(0)+Put pays for every doubling on the way to n;
+// New(n)+Put only for the per-key checks and the random accesses, which
+// is what FromSlice saves over it.
+/*
+	batchbench 对比用已知的 n 个键值对建 map 的几种方式：hmap.FromSlice（一次算好 B、集中算 hash、按桶地址顺序放入）、
+	hmap.New(0)/New(n) 之后逐个 Put、内置 map 的 make(0)/make(n) 之后逐个赋值。
+	每次 benchmark 操作构建一个完整的 map，输出每个元素的耗时和字节数，以及每个 map 的分配次数。
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"text/tabwriter"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hmap"
+)
+
+// builder is one way of building the map.
+type builder struct {
+	name  string
+	build func(keys, elems []int)
+}
+
+var builders = []builder{
+	{"hmap FromSlice", func(keys, elems []int) {
+		hmap.FromSlice(keys, elems)
+	}},
+	{"hmap New(0)+Put", func(keys, elems []int) {
+		m := hmap.New[int, int](0)
+		for i, k := range keys {
+			m.Put(k, elems[i])
+		}
+	}},
+	{"hmap New(n)+Put", func(keys, elems []int) {
+		m := hmap.New[int, int](len(keys))
+		for i, k := range keys {
+			m.Put(k, elems[i])
+		}
+	}},
+	{"builtin make(0)", func(keys, elems []int) {
+		m := make(map[int]int)
+		for i, k := range keys {
+			m[k] = elems[i]
+		}
+	}},
+	{"builtin make(n)", func(keys, elems []int) {
+		m := make(map[int]int, len(keys))
+		for i, k := range keys {
+			m[k] = elems[i]
+		}
+	}},
+}
+
+func main() {
+	testing.Init()
+	var (
+		sizes     = flag.String("sizes", "1000,10000,100000,1000000", "comma-separated numbers of entries")
+		benchtime = flag.String("benchtime", "500ms", "run time per benchmark, as for go test")
+	)
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("batchbench: ")
+	if err := flag.Set("test.benchtime", *benchtime); err != nil {
+		log.Fatal(err)
+	}
+	var ns []int
+	for _, s := range strings.Split(*sizes, ",") {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			log.Fatalf("bad size %q", s)
+		}
+		ns = append(ns, n)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	defer w.Flush()
+	fmt.Fprintln(w, "n\tbuild\tns/entry\tbytes/entry\tallocs/map\tvs New(0)+Put\t")
+	for _, n := range ns {
+		keys, elems := make([]int, n), make([]int, n)
+		r := rand.New(rand.NewPCG(1, uint64(n)))
+		seen := make(map[int]bool, n)
+		for i := range keys {
+			k := r.Int()
+			for seen[k] {
+				k = r.Int()
+			}
+			seen[k] = true
+			keys[i], elems[i] = k, i
+		}
+
+		results := make([]testing.BenchmarkResult, len(builders))
+		for i, bl := range builders {
+			results[i] = testing.Benchmark(func(b *testing.B) {
+				b.ReportAllocs()
+				for range b.N {
+					bl.build(keys, elems)
+				}
+			})
+		}
+		base := perEntry(results[1], n)
+		for i, bl := range builders {
+			r := results[i]
+			fmt.Fprintf(w, "%d\t%s\t%.1f\t%.1f\t%d\t%.2fx\t\n",
+				n, bl.name, perEntry(r, n), float64(r.AllocedBytesPerOp())/float64(n),
+				r.AllocsPerOp(), base/perEntry(r, n))
+		}
+	}
+}
+
+// perEntry returns the time to build a map divided by its entries.
+func perEntry(r testing.BenchmarkResult, n int) float64 {
+	return float64(r.T.Nanoseconds()) / float64(r.N) / float64(n)
+}
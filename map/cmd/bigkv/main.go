@@ -0,0 +1,110 @@
+// Bigkv shows what storing large elems out of line costs. For elems of
+// 64 to 1024 bytes under int keys it fills an hmap.Map three ways: with
+// the runtime's rule (indirect above 128 bytes), always inline and
+// always indirect (hmap.WithIndirect), and prints the MemStats of each
+// (allocations, live bytes per entry) next to the time of a lookup and
+// of a fresh insert, measured with testing.Benchmark.
+//
+// Usage:
+//
+//	bigkv [-n 100000] [-benchtime 200ms]
+//
+// An elem stored inline makes every bucket 8 elems wide, empty cells
+// included; an indirect one costs an allocation per insert, a pointer
+// per cell and an extra dereference per lookup. The crossover is where
+// it pays to declare map[K]*V instead of map[K]V.
+/*
+	bigkv 对比 elem 从 64 到 1024 字节时三种存法的代价：按 runtime 规则（超过 128 字节间接存储）、
+	强制内联、强制间接（hmap.WithIndirect），输出每种的分配次数、每个键值对实际占用的字节数，
+	以及查找和插入的耗时，用来判断什么时候值得自己把 map[K]V 改成 map[K]*V。
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"testing"
+	"text/tabwriter"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hmap"
+)
+
+// storage is one way of laying out the elems.
+type storage struct {
+	name string
+	opts []hmap.Option
+}
+
+var storages = []storage{
+	{"runtime", nil},
+	{"inline", []hmap.Option{hmap.WithIndirect(math.MaxInt, math.MaxInt)}},
+	{"indirect", []hmap.Option{hmap.WithIndirect(0, 0)}},
+}
+
+func main() {
+	testing.Init()
+	var (
+		n         = flag.Int("n", 100000, "entries per map")
+		benchtime = flag.String("benchtime", "200ms", "run time per benchmark, as for go test")
+	)
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("bigkv: ")
+	if err := flag.Set("test.benchtime", *benchtime); err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	defer w.Flush()
+	fmt.Fprintln(w, "elem\tstorage\tbucket\tbuckets\tallocs\tMB allocated\tbytes/entry\tget ns/op\tput ns/op\tput allocs/op\t")
+	measure[[64]byte](w, *n)
+	measure[[128]byte](w, *n)
+	measure[[136]byte](w, *n)
+	measure[[256]byte](w, *n)
+	measure[[512]byte](w, *n)
+	measure[[1024]byte](w, *n)
+}
+
+// measure prints one row per storage for elems of type V.
+func measure[V any](w *tabwriter.Writer, n int) {
+	for _, s := range storages {
+		m := hmap.New[int, V](0, s.opts...)
+		var v V
+		for k := range n {
+			m.Put(k, v)
+		}
+		ms := m.MemStats()
+		allocs := ms.ArrayAllocs + ms.OverflowAllocs + ms.KeyAllocs + ms.ElemAllocs
+
+		get := testing.Benchmark(func(b *testing.B) {
+			for i := range b.N {
+				m.Get(i % n)
+			}
+		})
+		// Each put adds a key not seen before, so an indirect map
+		// allocates the elem as well; Clear keeps the buckets.
+		put := testing.Benchmark(func(b *testing.B) {
+			m := hmap.New[int, V](n, s.opts...)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := range b.N {
+				if i%n == 0 {
+					m.Clear()
+				}
+				m.Put(i%n, v)
+			}
+		})
+
+		fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%d\t%.1f\t%.1f\t%.1f\t%.1f\t%d\t\n",
+			ms.ElemSize, s.name, ms.BucketSize, ms.Buckets, allocs,
+			float64(ms.AllocBytes)/(1<<20), ms.BytesPerEntry,
+			nsPerOp(get), nsPerOp(put), put.AllocsPerOp())
+	}
+}
+
+func nsPerOp(r testing.BenchmarkResult) float64 {
+	return float64(r.T.Nanoseconds()) / float64(r.N)
+}
@@ -0,0 +1,201 @@
+// Concbench compares shardmap.Map with sync.Map and with a builtin map
+// behind a sync.Mutex or sync.RWMutex, over a range of read/write
+// mixes and GOMAXPROCS values. It runs the benchmarks with
+// testing.Benchmark and prints ns/op per implementation.
+//
+// Usage:
+//
+//	concbench [-keys 65536] [-reads 100,99,90,75,50,0] [-cpu 1,N] [-shards 0] [-benchtime 1s] [-impl list]
+//
+// Every map is filled with -keys keys first. Each operation then picks a
+// random key and loads it with the probability given in -reads (percent)
+// or stores it otherwise, from GOMAXPROCS goroutines at once.
+/*
+	concbench 用 testing.Benchmark 对比 shardmap、sync.Map、Mutex/RWMutex 包装的内置 map，
+	在不同的读写比例和 GOMAXPROCS 下的每次操作耗时。
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"text/tabwriter"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/shardmap"
+)
+
+// store is what the benchmark needs from each implementation.
+type store interface {
+	Load(key int) (int, bool)
+	Store(key, value int)
+}
+
+type syncMap struct{ m sync.Map }
+
+func (s *syncMap) Load(key int) (int, bool) {
+	v, ok := s.m.Load(key)
+	if !ok {
+		return 0, false
+	}
+	return v.(int), true
+}
+
+func (s *syncMap) Store(key, value int) { s.m.Store(key, value) }
+
+type mutexMap struct {
+	mu sync.Mutex
+	m  map[int]int
+}
+
+func (s *mutexMap) Load(key int) (int, bool) {
+	s.mu.Lock()
+	v, ok := s.m[key]
+	s.mu.Unlock()
+	return v, ok
+}
+
+func (s *mutexMap) Store(key, value int) {
+	s.mu.Lock()
+	s.m[key] = value
+	s.mu.Unlock()
+}
+
+type rwMutexMap struct {
+	mu sync.RWMutex
+	m  map[int]int
+}
+
+func (s *rwMutexMap) Load(key int) (int, bool) {
+	s.mu.RLock()
+	v, ok := s.m[key]
+	s.mu.RUnlock()
+	return v, ok
+}
+
+func (s *rwMutexMap) Store(key, value int) {
+	s.mu.Lock()
+	s.m[key] = value
+	s.mu.Unlock()
+}
+
+type impl struct {
+	name string
+	new  func(keys, shards int) store
+}
+
+var impls = []impl{
+	{"shardmap", func(keys, shards int) store {
+		var opts []shardmap.Option
+		if shards > 0 {
+			opts = append(opts, shardmap.WithShards(shards))
+		}
+		return shardmap.New[int, int](append(opts, shardmap.WithHint(keys))...)
+	}},
+	{"sync.Map", func(int, int) store { return new(syncMap) }},
+	{"mutex", func(keys, _ int) store { return &mutexMap{m: make(map[int]int, keys)} }},
+	{"rwmutex", func(keys, _ int) store { return &rwMutexMap{m: make(map[int]int, keys)} }},
+}
+
+func bench(s store, keys, reads int) testing.BenchmarkResult {
+	var seed atomic.Uint64
+	return testing.Benchmark(func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			r := rand.New(rand.NewPCG(seed.Add(1), 0))
+			for pb.Next() {
+				k := r.IntN(keys)
+				if r.IntN(100) < reads {
+					s.Load(k)
+				} else {
+					s.Store(k, k)
+				}
+			}
+		})
+	})
+}
+
+func ints(s string) ([]int, error) {
+	var out []int
+	for _, f := range strings.Split(s, ",") {
+		if f == "N" {
+			out = append(out, runtime.NumCPU())
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+func main() {
+	testing.Init()
+	var (
+		keys      = flag.Int("keys", 1<<16, "number of distinct keys")
+		readsFlag = flag.String("reads", "100,99,90,75,50,0", "comma-separated read percentages")
+		cpuFlag   = flag.String("cpu", "1,N", "comma-separated GOMAXPROCS values (N is the number of CPUs)")
+		shards    = flag.Int("shards", 0, "shardmap shard count (0 for the default)")
+		benchtime = flag.String("benchtime", "1s", "run time per benchmark, as for go test")
+		only      = flag.String("impl", "", "comma-separated implementations to run (default all)")
+	)
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("concbench: ")
+
+	if err := flag.Set("test.benchtime", *benchtime); err != nil {
+		log.Fatal(err)
+	}
+	reads, err := ints(*readsFlag)
+	if err != nil {
+		log.Fatalf("bad -reads: %v", err)
+	}
+	cpus, err := ints(*cpuFlag)
+	if err != nil {
+		log.Fatalf("bad -cpu: %v", err)
+	}
+
+	var run []impl
+	for _, im := range impls {
+		if *only == "" || strings.Contains(","+*only+",", ","+im.name+",") {
+			run = append(run, im)
+		}
+	}
+	if len(run) == 0 {
+		log.Fatalf("no implementation matches -impl %q", *only)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	defer w.Flush()
+	fmt.Fprint(w, "procs\treads%\t")
+	for _, im := range run {
+		fmt.Fprintf(w, "%s ns/op\t", im.name)
+	}
+	fmt.Fprintln(w)
+
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+	for _, procs := range cpus {
+		runtime.GOMAXPROCS(procs)
+		for _, rd := range reads {
+			fmt.Fprintf(w, "%d\t%d\t", procs, rd)
+			for _, im := range run {
+				s := im.new(*keys, *shards)
+				for k := range *keys {
+					s.Store(k, k)
+				}
+				res := bench(s, *keys, rd)
+				fmt.Fprintf(w, "%.1f\t", float64(res.T.Nanoseconds())/float64(res.N))
+			}
+			fmt.Fprintln(w)
+		}
+	}
+}
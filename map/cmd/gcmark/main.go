@@ -0,0 +1,198 @@
+// Gcmark measures how much work a large map gives the garbage collector.
+// For each kind of map it starts itself again with GODEBUG=gctrace=1,
+// fills one map with -n entries of uint64 keys and 16-byte elems, forces
+// -gcs collections and reads the gctrace lines they print:
+//
+//	builtin-ptr     map[uint64]*[2]uint64
+//	builtin         map[uint64][2]uint64, buckets the GC never scans
+//	hmap-ptr        hmap.Map[uint64, *[2]uint64]
+//	hmap            hmap.Map[uint64, [2]uint64], whose bucket structs are still scanned
+//	hmap-arena      the same with hmap.WithArena, buckets in unscanned slabs
+//
+// It prints the average stop-the-world pauses, the wall-clock and CPU time
+// of the concurrent mark phase and the live heap per kind. Mark time grows
+// with the memory the GC has to scan, not with the heap size.
+//
+// Usage:
+//
+//	gcmark [-n 4000000] [-gcs 10] [-kinds list]
+/*
+	gcmark 用 GODEBUG=gctrace=1 对比同样大小的 map 给 GC 带来的标记开销：
+	内置 map 有指针/无指针、hmap 有指针/无指针（桶结构体本身仍要扫描）/arena 分配（GC 完全不扫描桶）。
+	每种 map 在单独的子进程中构造，强制执行若干次 GC，解析 gctrace 输出，
+	汇总 STW 暂停、并发标记的墙钟时间和 CPU 时间，以及存活堆大小。
+*/
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hmap"
+)
+
+type elem = [2]uint64
+
+// kinds builds each map and returns it, to be kept alive while the
+// collections run.
+var kinds = []struct {
+	name  string
+	build func(n int) any
+}{
+	{"builtin-ptr", func(n int) any {
+		m := make(map[uint64]*elem, n)
+		for i := range uint64(n) {
+			m[i] = &elem{i, i}
+		}
+		return m
+	}},
+	{"builtin", func(n int) any {
+		m := make(map[uint64]elem, n)
+		for i := range uint64(n) {
+			m[i] = elem{i, i}
+		}
+		return m
+	}},
+	{"hmap-ptr", func(n int) any {
+		m := hmap.New[uint64, *elem](n)
+		for i := range uint64(n) {
+			m.Put(i, &elem{i, i})
+		}
+		return m
+	}},
+	{"hmap", func(n int) any {
+		m := hmap.New[uint64, elem](n)
+		for i := range uint64(n) {
+			m.Put(i, elem{i, i})
+		}
+		return m
+	}},
+	{"hmap-arena", func(n int) any {
+		m := hmap.New[uint64, elem](n, hmap.WithArena(0))
+		for i := range uint64(n) {
+			m.Put(i, elem{i, i})
+		}
+		return m
+	}},
+}
+
+const (
+	beginMarker = "gcmark: begin"
+	endMarker   = "gcmark: end"
+)
+
+// gcLine matches the fields of a gctrace line used here, e.g.
+//
+//	gc 7 @0.412s 9%: 0.021+35+0.043 ms clock, 0.17+0.12/69/0.10+0.35 ms cpu, 190->190->190 MB, ...
+var gcLine = regexp.MustCompile(`^gc \d+ @[\d.]+s \d+%: ([\d.]+)\+([\d.]+)\+([\d.]+) ms clock, [\d.]+\+([\d.]+)/([\d.]+)/([\d.]+)\+[\d.]+ ms cpu, \d+->\d+->(\d+) MB`)
+
+type result struct {
+	gcs         int
+	pause, mark float64 // ms clock: STW phases, concurrent mark
+	markCPU     float64 // ms cpu: assist + background + idle mark
+	liveMB      float64
+}
+
+func main() {
+	var (
+		n     = flag.Int("n", 4_000_000, "entries per map")
+		gcs   = flag.Int("gcs", 10, "forced collections per map")
+		only  = flag.String("kinds", "", "comma-separated kinds to run (default all)")
+		child = flag.String("child", "", "internal: build this kind and collect")
+	)
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("gcmark: ")
+
+	if *child != "" {
+		runChild(*child, *n, *gcs)
+		return
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	defer w.Flush()
+	fmt.Fprintln(w, "kind\tGCs\tpause ms\tmark ms\tmark cpu ms\tlive MB\t")
+	for _, k := range kinds {
+		if *only != "" && !strings.Contains(","+*only+",", ","+k.name+",") {
+			continue
+		}
+		cmd := exec.Command(exe, "-child", k.name, "-n", strconv.Itoa(*n), "-gcs", strconv.Itoa(*gcs))
+		cmd.Env = append(os.Environ(), "GODEBUG=gctrace=1")
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			log.Fatalf("%s: %v\n%s", k.name, err, stderr.Bytes())
+		}
+		r := parse(&stderr)
+		if r.gcs == 0 {
+			log.Fatalf("%s: no gctrace output", k.name)
+		}
+		d := float64(r.gcs)
+		fmt.Fprintf(w, "%s\t%d\t%.3f\t%.2f\t%.2f\t%.0f\t\n",
+			k.name, r.gcs, r.pause/d, r.mark/d, r.markCPU/d, r.liveMB/d)
+	}
+}
+
+// runChild builds one map and forces the collections the parent reads.
+func runChild(name string, n, gcs int) {
+	for _, k := range kinds {
+		if k.name != name {
+			continue
+		}
+		m := k.build(n)
+		runtime.GC()
+		fmt.Fprintln(os.Stderr, beginMarker)
+		for range gcs {
+			runtime.GC()
+		}
+		fmt.Fprintln(os.Stderr, endMarker)
+		runtime.KeepAlive(m)
+		return
+	}
+	log.Fatalf("unknown kind %q", name)
+}
+
+// parse sums the gctrace lines between the markers.
+func parse(b *bytes.Buffer) result {
+	var r result
+	in := false
+	sc := bufio.NewScanner(b)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == beginMarker:
+			in = true
+		case line == endMarker:
+			in = false
+		case in:
+			f := gcLine.FindStringSubmatch(line)
+			if f == nil {
+				continue
+			}
+			v := make([]float64, len(f))
+			for i := 1; i < len(f); i++ {
+				v[i], _ = strconv.ParseFloat(f[i], 64)
+			}
+			r.gcs++
+			r.pause += v[1] + v[3]
+			r.mark += v[2]
+			r.markCPU += v[4] + v[5] + v[6]
+			r.liveMB += v[7]
+		}
+	}
+	return r
+}
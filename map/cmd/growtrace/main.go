@@ -0,0 +1,190 @@
+// Growtrace replays a workload against an hmap.Map[int, int] and prints
+// its growth events as JSON lines (see hmap.JSONTracer). With -impl swiss
+// it traces a swiss.Map instead: table grows, splits and directory grows.
+//
+// Usage:
+//
+//	growtrace [-impl hmap|swiss] [-w workload] [-n 1000] [-del 0.0] [-hint 0] [-shrink 0] [-seed 1]
+//
+// The workload file has one operation per line: "put K", "del K" or
+// "clear", with integer keys; "-" reads it from standard input. Without
+// -w, growtrace inserts -n random keys and deletes a random earlier key
+// after each insert with probability -del. A non-zero -shrink creates the
+// hmap with hmap.WithShrink, so deletes can halve the bucket array.
+//
+// A summary of each growth (when it started, how many writes it took to
+// release the old buckets) is written to standard error.
+/*
+	growtrace 按给定的操作序列（或随机生成的插入/删除序列）操作 map，把扩容事件逐行输出为 JSON，
+	并在标准错误上汇总每次扩容从哪次写入开始、经过多少次写入才释放 oldbuckets。
+	-impl swiss 换成 Swiss table，汇总表翻倍、分裂和目录翻倍的次数。
+*/
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hmap"
+	"github.com/ProsperousLi/golang-deep-learn/map/mapapi"
+	"github.com/ProsperousLi/golang-deep-learn/map/swiss"
+)
+
+// summary tees events to the JSON tracer and keeps per-growth totals.
+type summary struct {
+	next   hmap.Tracer
+	grows  []*hmap.GrowStart
+	evacs  []int
+	writes []uint64 // 0 while a growth is still in progress
+
+	// swiss.Map events
+	tableGrows, splits, dirGrows int
+	rehashed                     int // entries moved by table grows and splits
+}
+
+func (s *summary) Trace(e hmap.Event) {
+	switch e := e.(type) {
+	case *hmap.GrowStart:
+		s.grows = append(s.grows, e)
+		s.evacs = append(s.evacs, 0)
+		s.writes = append(s.writes, 0)
+	case *hmap.Evacuate:
+		s.evacs[len(s.evacs)-1]++
+	case *hmap.Release:
+		s.writes[len(s.writes)-1] = e.Writes
+	case *swiss.TableGrow:
+		s.tableGrows++
+		s.rehashed += e.Used
+	case *swiss.TableSplit:
+		s.splits++
+		s.rehashed += e.Used
+	case *swiss.DirectoryGrow:
+		s.dirGrows++
+	}
+	s.next.Trace(e)
+}
+
+func (s *summary) print(w io.Writer) {
+	for i, g := range s.grows {
+		kind := "doubling"
+		switch {
+		case g.SameSize:
+			kind = "same size"
+		case g.Shrink:
+			kind = "shrink"
+		}
+		done := "still growing"
+		if s.writes[i] != 0 {
+			done = fmt.Sprintf("done after %d writes", s.writes[i])
+		}
+		fmt.Fprintf(w, "grow %d: B %d->%d (%s) at write %d (%s), count=%d, %d buckets evacuated, %s\n",
+			i+1, g.OldB, g.B, kind, g.Seq, g.Op, g.Count, s.evacs[i], done)
+	}
+	if s.tableGrows+s.splits > 0 {
+		fmt.Fprintf(w, "%d table grows, %d splits, %d directory grows, %d entries rehashed\n",
+			s.tableGrows, s.splits, s.dirGrows, s.rehashed)
+	}
+}
+
+func main() {
+	var (
+		impl     = flag.String("impl", "hmap", "map implementation: hmap or swiss")
+		workload = flag.String("w", "", "workload file, or - for stdin")
+		n        = flag.Int("n", 1000, "random inserts when no workload is given")
+		del      = flag.Float64("del", 0, "probability of a delete after each random insert")
+		hint     = flag.Int("hint", 0, "size hint passed to New")
+		shrink   = flag.Float64("shrink", 0, "hmap.WithShrink fraction (0: never shrink)")
+		seed     = flag.Uint64("seed", 1, "seed for the random workload")
+	)
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("growtrace: ")
+
+	out := bufio.NewWriter(os.Stdout)
+	jt := hmap.NewJSONTracer(out)
+	sum := &summary{next: jt}
+	var m mapapi.Map[int, int]
+	switch *impl {
+	case "hmap":
+		opts := []hmap.Option{hmap.WithTracer(sum)}
+		if *shrink != 0 {
+			opts = append(opts, hmap.WithShrink(*shrink))
+		}
+		m = hmap.New[int, int](*hint, opts...)
+	case "swiss":
+		m = swiss.New[int, int](*hint, swiss.WithTracer(sum))
+	default:
+		log.Fatalf("unknown -impl %q", *impl)
+	}
+
+	if *workload != "" {
+		if err := replay(m, *workload); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		r := rand.New(rand.NewPCG(*seed, 0))
+		var keys []int
+		for range *n {
+			k := r.Int()
+			keys = append(keys, k)
+			m.Put(k, k)
+			if r.Float64() < *del {
+				m.Delete(keys[r.IntN(len(keys))])
+			}
+		}
+	}
+
+	if err := out.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if err := jt.Err(); err != nil {
+		log.Fatal(err)
+	}
+	sum.print(os.Stderr)
+}
+
+func replay(m mapapi.Map[int, int], name string) error {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		f := strings.Fields(sc.Text())
+		if len(f) == 0 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		if f[0] == "clear" {
+			m.Clear()
+			continue
+		}
+		if len(f) != 2 {
+			return fmt.Errorf("%s:%d: want \"put K\", \"del K\" or \"clear\"", name, line)
+		}
+		k, err := strconv.Atoi(f[1])
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", name, line, err)
+		}
+		switch f[0] {
+		case "put":
+			m.Put(k, k)
+		case "del":
+			m.Delete(k)
+		default:
+			return fmt.Errorf("%s:%d: unknown operation %q", name, line, f[0])
+		}
+	}
+	return sc.Err()
+}
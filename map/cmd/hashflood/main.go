@@ -0,0 +1,277 @@
+// Hashflood simulates a hash-flooding attack on an hmap.Map[uint64, int]
+// and shows what the per-map seed hash0, and the choice of hasher, do
+// against it.
+//
+// Usage:
+//
+//	hashflood [-hasher runtime] [-seed 0] [-live 1000] [-n 256] [-waves 64]
+//
+// The victim is a table that holds -live entries of legitimate traffic
+// and sees -waves bursts of -n attacker keys, each deleted again before
+// the next one (a session table, a cache with expiry). The attacker
+// knows hash0: with -seed N the victim is created with hmap.WithSeed(N),
+// so a twin map built with the same seed has the same hash0; with -seed
+// 0 the victim's hash0 is random and leaks through Map.Layout. Knowing
+// hash0 and the hasher, the attacker brute-forces for each wave n keys
+// whose hash selects one bucket of the victim's 1<<B, a different bucket
+// per wave. Each wave then builds an overflow chain of n/8 buckets, so
+// every insert and lookup of the wave walks it; and since the deleted
+// keys leave their overflow buckets behind, the overflow count reaches
+// 1<<B after a number of waves and tooManyOverflowBuckets starts a
+// same-size grow, which rebuilds the whole table and is paid for by the
+// writes that follow.
+//
+// The same waves are run in four scenarios:
+//
+//   - random: random keys instead of colliding ones, for reference;
+//   - flood: the attack as described;
+//   - reseeded: the victim is cleared and refilled after the attacker
+//     learned hash0; mapclear picks a new hash0, so the precomputed keys
+//     spread like random ones;
+//   - siphash24: the victim uses SipHash-2-4 under a secret random key.
+//     hash0 still leaks, but without the key the attacker can only aim
+//     at a guess (the public test-vector key).
+//
+// With -hasher siphash24 the flood scenario uses the public key, which
+// shows that it is the secret key, not the hash function, that helps.
+// With -hasher fnv1a part of the flood survives reseeding: the low bits
+// of an FNV-1a hash depend only on the low bits of the seed and of the
+// input bytes, so keys crafted for one hash0 still cluster under others.
+/*
+	hashflood 模拟 hash 洪水攻击：受害 map 持有 -live 个正常 entry，攻击者分 -waves 批发送 -n 个 key，
+	每批在下一批到来之前被删除（类似会话表、带过期的缓存）。攻击者知道 hash0（-seed N 时用同一个种子造一个
+	孪生 map 即可得到；-seed 0 时通过 Map.Layout 泄露）和 hash 函数，就能暴力搜索出落在同一个桶里的 key，
+	每批换一个桶：每批都形成 n/8 个桶长的溢出链，插入和查找都要走完整条链；删除后溢出桶仍然留着，
+	累计到 1<<B 个时 tooManyOverflowBuckets 触发等量扩容，重建整个表。
+	四个场景对比：随机 key；攻击；攻击者拿到 hash0 之后受害者执行 clear（mapclear 会重新生成 hash0，
+	预先算好的 key 就不再冲突）；受害者改用带随机密钥的 SipHash-2-4（hash0 泄露也没用）。
+*/
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hasher"
+	"github.com/ProsperousLi/golang-deep-learn/map/hmap"
+)
+
+// growths counts the grows of the victim by kind.
+type growths struct {
+	sameSize, doubling int
+}
+
+func (g *growths) Trace(e hmap.Event) {
+	if e, ok := e.(*hmap.GrowStart); ok {
+		if e.SameSize {
+			g.sameSize++
+		} else {
+			g.doubling++
+		}
+	}
+}
+
+// result is one row of the report.
+type result struct {
+	scenario string
+	hasher   string
+	B        uint8
+	maxChain int     // longest chain seen, in buckets
+	maxOver  uint16  // highest noverflow seen
+	cells    float64 // cells inspected per lookup of an attack key
+	keyCmps  float64 // key comparisons per lookup of an attack key
+	putNs    float64
+	getNs    float64
+	growths
+}
+
+func main() {
+	var (
+		hname = flag.String("hasher", "runtime", fmt.Sprintf("hasher of the victim, one of %v", hasher.Names()))
+		seed  = flag.Uint64("seed", 0, "fixed seed of the victim map (0: random, leaked through Layout)")
+		live  = flag.Int("live", 1000, "legitimate entries the victim holds")
+		n     = flag.Int("n", 256, "attack keys per wave")
+		waves = flag.Int("waves", 64, "attack waves")
+	)
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("hashflood: ")
+	h, err := hasher.ByName(*hname)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *live <= 0 || *n <= 0 || *waves <= 0 {
+		log.Fatal("-live, -n and -waves must be positive")
+	}
+
+	r := rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	secret := hasher.NewSipHash24(r.Uint64(), r.Uint64())
+	public, _ := hasher.ByName("siphash24")
+	s := sim{seed: *seed, live: *live, n: *n, waves: *waves, r: r}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	defer w.Flush()
+	fmt.Fprintln(w, "scenario\thasher\tB\tmax chain\tmax noverflow\tcells/lookup\tkey cmps/lookup\tput ns\tget ns\tsame-size grows\tgrows\t")
+	for _, res := range []result{
+		s.run("random", *hname, h, nil, false),
+		s.run("flood", *hname, h, h, false),
+		s.run("reseeded", *hname, h, h, true),
+		s.run("siphash24", "siphash24 (secret key)", secret, public, false),
+	} {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%.1f\t%.2f\t%.0f\t%.0f\t%d\t%d\t\n",
+			res.scenario, res.hasher, res.B, res.maxChain, res.maxOver, res.cells, res.keyCmps,
+			res.putNs, res.getNs, res.sameSize, res.doubling)
+	}
+}
+
+// sim holds the parameters shared by the scenarios.
+type sim struct {
+	seed           uint64
+	live, n, waves int
+	r              *rand.Rand
+}
+
+// newVictim creates the victim map with hasher h and fills it with the
+// live entries.
+func (s *sim) newVictim(h hasher.Hasher, g *growths) (*hmap.Map[uint64, int], []uint64) {
+	seed := s.seed
+	if seed == 0 {
+		seed = s.r.Uint64()
+	}
+	m := hmap.New[uint64, int](0, hmap.WithHasher(h), hmap.WithSeed(seed), hmap.WithTracer(g))
+	keys := make([]uint64, s.live)
+	for i := range keys {
+		keys[i] = s.r.Uint64()
+		m.Put(keys[i], i)
+	}
+	return m, keys
+}
+
+// leakHash0 returns what the attacker knows of the victim's hash0: with a
+// fixed seed, the hash0 of a twin map; otherwise the victim's own.
+func (s *sim) leakHash0(m *hmap.Map[uint64, int]) uint32 {
+	if s.seed != 0 {
+		return hmap.New[uint64, int](0, hmap.WithSeed(s.seed)).Layout().Hash0
+	}
+	return m.Layout().Hash0
+}
+
+// run plays the waves against a victim using h. The attacker aims with
+// aim (nil for random keys) at the hash0 it learned; if reseed is set
+// the victim is cleared and refilled after the leak.
+func (s *sim) run(scenario, hname string, h, aim hasher.Hasher, reseed bool) result {
+	var g growths
+	m, liveKeys := s.newVictim(h, &g)
+	hash0 := s.leakHash0(m)
+	B := m.Layout().B
+	if reseed {
+		m.Clear()
+		for i, k := range liveKeys {
+			m.Put(k, i)
+		}
+	}
+	g = growths{} // count only the grows of the attack
+
+	res := result{scenario: scenario, hasher: hname, B: B}
+	var putTime, getTime time.Duration
+	var lookups, cells, keyCmps int
+	next := s.r.Uint64()
+	wave := make([]uint64, s.n)
+	for w := range s.waves {
+		target := uint64(w) & (1<<B - 1)
+		for i := range wave {
+			if aim == nil {
+				wave[i] = s.r.Uint64()
+				continue
+			}
+			for ; bucketOf(aim, next, hash0, B) != target; next++ {
+			}
+			wave[i] = next
+			next++
+		}
+
+		start := time.Now()
+		for i, k := range wave {
+			m.Put(k, i)
+		}
+		putTime += time.Since(start)
+		start = time.Now()
+		for _, k := range wave {
+			m.Get(k)
+		}
+		getTime += time.Since(start)
+
+		// Finish a grow in progress, so that the layout shows where
+		// the lookups end up: rewriting a key does growWork.
+		for m.Growing() {
+			m.Put(wave[0], 0)
+		}
+		l := m.Layout()
+		res.maxOver = max(res.maxOver, l.NOverflow)
+		c, k, chain := probes(l, wave)
+		cells += c
+		keyCmps += k
+		lookups += len(wave)
+		res.maxChain = max(res.maxChain, chain)
+
+		for _, k := range wave {
+			m.Delete(k)
+		}
+	}
+	res.cells = float64(cells) / float64(lookups)
+	res.keyCmps = float64(keyCmps) / float64(lookups)
+	res.putNs = float64(putTime.Nanoseconds()) / float64(lookups)
+	res.getNs = float64(getTime.Nanoseconds()) / float64(lookups)
+	res.growths = g
+	return res
+}
+
+// bucketOf is the bucket the victim puts key in when it has 1<<B buckets
+// and hashes with h under hash0, as the attacker computes it.
+func bucketOf(h hasher.Hasher, key uint64, hash0 uint32, B uint8) uint64 {
+	var b [8]byte
+	binary.NativeEndian.PutUint64(b[:], key)
+	return h.Hash(b[:], uint64(hash0)) & (1<<B - 1)
+}
+
+// probes returns the cells inspected and the keys compared by lookups of
+// keys in the layout l, and the length of the longest chain holding one
+// of them. A lookup walks the chain of its bucket cell by cell, checking
+// tophash, and compares keys only where tophash matches.
+func probes(l *hmap.Layout, keys []uint64) (cells, keyCmps, longest int) {
+	want := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		want[strconv.FormatUint(k, 10)] = true
+	}
+	for _, c := range l.Buckets {
+		scanned := 0
+		hit := false
+		var tops [256]int
+		for _, b := range c {
+			for i, key := range b.Keys {
+				scanned++
+				if key == "" {
+					continue
+				}
+				top := b.Tophash[i]
+				tops[top]++
+				if want[key] {
+					cells += scanned
+					keyCmps += tops[top]
+					hit = true
+				}
+			}
+		}
+		if hit {
+			longest = max(longest, len(c))
+		}
+	}
+	return cells, keyCmps, longest
+}
@@ -0,0 +1,319 @@
+// Hashqual measures how well the hashers of package hasher spread a key
+// corpus over the two parts of the hash the map uses: the low B bits,
+// which pick the bucket, and the top 8 bits, which become the tophash
+// byte that every probe compares before looking at a key. A hasher with
+// weak low bits overfills some buckets; one with weak high bits makes
+// tophash useless and turns probes into key comparisons. Either only
+// shows up as slower lookups, which is why this is worth checking on the
+// keys a program really uses.
+//
+// Usage:
+//
+//	hashqual [-hasher all] [-corpus seq] [-file keys.txt] [-n 100000] [-B 0] [-seed 0]
+//
+// The corpus is one of
+//
+//	seq      the integers 0, 1, 2, ... as 8-byte keys
+//	rand     random 8-byte integers
+//	strided  multiples of 4096 as 8-byte keys, like aligned addresses
+//	decimal  the strings "0", "1", "2", ...
+//	uuid     random version 4 UUIDs in their 36-character text form
+//
+// or, with -file, the lines of a file (- for standard input) as string
+// keys. The first half of the keys (-n for a generated corpus) is
+// inserted into a simulated table of 1<<B buckets, B being the one the
+// map would have for that many entries unless -B is given; the second
+// half is looked up as misses. For each hasher the report gives
+//
+//   - bucket χ²/df and p: the chi-square statistic of the bucket counts
+//     against a uniform spread, divided by its degrees of freedom (about
+//     1 for a good hasher), and the probability of a value at least that
+//     large by chance; p near 0 means the low bits are not uniform, and
+//     p near 1 that they are more even than chance, which costs nothing
+//     but shows the hasher passing the structure of the keys through;
+//   - max load: the fullest bucket, against the mean;
+//   - tophash χ²/df and p: the same test of the tophash bytes against
+//     the distribution tophash() gives uniform hashes (the values below
+//     minTopHash are bumped, so 5..9 are twice as likely);
+//   - same top: the fraction of pairs of keys in one bucket that share
+//     the tophash byte, relative to what uniform hashes give (1.00);
+//   - false/hit and false/miss: tophash matches on a key other than the
+//     one looked up, per lookup, counting the cells a lookup scans in
+//     insertion order. Each one costs a key comparison.
+/*
+	hashqual 分析 hash 函数在两个部分上的质量：低 B 位决定桶号，最高 8 位变成 tophash，每次探测先比 tophash 再比 key。
+	低位不均匀会让部分桶过满，高位不均匀会让 tophash 失去过滤作用、探测退化成 key 比较，两者都只表现为查找变慢。
+	输入可以是生成的语料（连续整数、随机整数、按 4096 对齐的整数、十进制字符串、UUID）或文件中的每一行；
+	前一半插入模拟的 1<<B 个桶中，后一半作为不存在的 key 查找。输出桶号分布的卡方统计量和 p 值、最满的桶、
+	tophash 分布的卡方检验、同一个桶中 tophash 相同的比例，以及命中/未命中查找平均遇到的 tophash 误匹配次数。
+*/
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hasher"
+)
+
+const (
+	bucketCnt     = 8
+	loadFactorNum = 13
+	loadFactorDen = 2
+	minTopHash    = 5
+	ptrSize       = 4 << (^uintptr(0) >> 63) // the map hashes into a uintptr
+)
+
+func main() {
+	var (
+		hnames = flag.String("hasher", "all", fmt.Sprintf("comma-separated hashers, or all of %v", hasher.Names()))
+		corpus = flag.String("corpus", "seq", "generated corpus: seq, rand, strided, decimal or uuid")
+		file   = flag.String("file", "", "read string keys from this file, one per line (- for stdin), instead of -corpus")
+		n      = flag.Int("n", 100000, "keys inserted from a generated corpus (as many again are looked up as misses)")
+		fixedB = flag.Int("B", 0, "log2 of the number of buckets (0: what the map would use)")
+		seed   = flag.Uint64("seed", 0, "hash0 passed to the hashers (0: random)")
+	)
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("hashqual: ")
+
+	var keys [][]byte
+	var err error
+	if *file != "" {
+		keys, err = readKeys(*file)
+		*corpus = *file
+	} else {
+		keys, err = generate(*corpus, 2**n)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(keys) < 2 {
+		log.Fatal("need at least 2 keys")
+	}
+	inserted, missed := keys[:len(keys)/2], keys[len(keys)/2:]
+
+	B := uint8(*fixedB)
+	if B == 0 {
+		for overLoadFactor(len(inserted), B) {
+			B++
+		}
+	}
+	hash0 := *seed
+	if hash0 == 0 {
+		hash0 = uint64(rand.Uint32())
+	}
+
+	var hs []string
+	if *hnames == "all" {
+		hs = hasher.Names()
+	} else {
+		hs = strings.Split(*hnames, ",")
+	}
+
+	fmt.Printf("corpus %s: %d keys inserted into %d buckets (B=%d), %d looked up as misses, hash0 %#x\n",
+		*corpus, len(inserted), 1<<B, B, len(missed), hash0)
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	defer w.Flush()
+	fmt.Fprintln(w, "hasher\tns/key\tbucket χ²/df\tp\tmax load\ttophash χ²/df\tp\tsame top\tfalse/hit\tfalse/miss\t")
+	for _, name := range hs {
+		h, err := hasher.ByName(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		a := analyze(h, hash0, B, inserted, missed)
+		fmt.Fprintf(w, "%s\t%.1f\t%.2f\t%.3g\t%d/%.1f\t%.2f\t%.3g\t%.2f\t%.4f\t%.4f\t\n",
+			name, a.nsPerKey, a.bucketChi2/a.bucketDF, a.bucketP, a.maxLoad, a.meanLoad,
+			a.topChi2/a.topDF, a.topP, a.sameTop, a.falseHit, a.falseMiss)
+	}
+}
+
+// overLoadFactor is the map's test for count entries in 1<<B buckets.
+func overLoadFactor(count int, B uint8) bool {
+	return count > bucketCnt && uint64(count) > loadFactorNum*((uint64(1)<<B)/loadFactorDen)
+}
+
+func tophash(hash uintptr) uint8 {
+	top := uint8(hash >> (ptrSize*8 - 8))
+	if top < minTopHash {
+		top += minTopHash
+	}
+	return top
+}
+
+// topProb is the probability of each tophash value for uniform hashes.
+func topProb(v int) float64 {
+	switch {
+	case v < minTopHash:
+		return 0
+	case v < 2*minTopHash:
+		return 2.0 / 256
+	}
+	return 1.0 / 256
+}
+
+// analysis is the report for one hasher.
+type analysis struct {
+	nsPerKey            float64
+	bucketChi2, bucketP float64
+	bucketDF            float64
+	maxLoad             int
+	meanLoad            float64
+	topChi2, topP       float64
+	topDF               float64
+	sameTop             float64 // relative to uniform hashes
+	falseHit, falseMiss float64
+}
+
+func analyze(h hasher.Hasher, hash0 uint64, B uint8, inserted, missed [][]byte) analysis {
+	var a analysis
+	mask := uintptr(1)<<B - 1
+	nb := 1 << B
+
+	hashes := make([]uintptr, len(inserted))
+	start := time.Now()
+	for i, k := range inserted {
+		hashes[i] = uintptr(h.Hash(k, hash0))
+	}
+	a.nsPerKey = float64(time.Since(start).Nanoseconds()) / float64(len(inserted))
+
+	// tops[b] counts the tophash values in bucket b, filled in insertion
+	// order, which is the order a lookup scans them in.
+	loads := make([]int, nb)
+	tops := make([]map[uint8]int, nb)
+	var topCounts [256]int
+	falseHits := 0
+	for _, hash := range hashes {
+		b, top := hash&mask, tophash(hash)
+		if tops[b] == nil {
+			tops[b] = make(map[uint8]int)
+		}
+		falseHits += tops[b][top]
+		tops[b][top]++
+		loads[b]++
+		topCounts[top]++
+	}
+	falseMisses := 0
+	for _, k := range missed {
+		hash := uintptr(h.Hash(k, hash0))
+		falseMisses += tops[hash&mask][tophash(hash)]
+	}
+	a.falseHit = float64(falseHits) / float64(len(inserted))
+	a.falseMiss = float64(falseMisses) / float64(len(missed))
+
+	n := float64(len(inserted))
+	a.meanLoad = n / float64(nb)
+	for _, l := range loads {
+		d := float64(l) - a.meanLoad
+		a.bucketChi2 += d * d / a.meanLoad
+		a.maxLoad = max(a.maxLoad, l)
+	}
+	a.bucketDF = float64(nb - 1)
+	a.bucketP = chi2Tail(a.bucketChi2, a.bucketDF)
+
+	sumP2 := 0.0
+	for v, c := range topCounts {
+		p := topProb(v)
+		if p == 0 {
+			continue
+		}
+		sumP2 += p * p
+		e := n * p
+		d := float64(c) - e
+		a.topChi2 += d * d / e
+		a.topDF++
+	}
+	a.topDF--
+	a.topP = chi2Tail(a.topChi2, a.topDF)
+
+	// Pairs in one bucket sharing tophash, against all pairs in one bucket.
+	var same, pairs float64
+	for b, m := range tops {
+		l := float64(loads[b])
+		pairs += l * (l - 1) / 2
+		for _, c := range m {
+			same += float64(c) * float64(c-1) / 2
+		}
+	}
+	if pairs > 0 {
+		a.sameTop = same / pairs / sumP2
+	}
+	return a
+}
+
+// chi2Tail returns P(X >= x) for X chi-square distributed with df degrees
+// of freedom, by the Wilson-Hilferty normal approximation, which is good
+// to a few percent for the hundreds of degrees of freedom seen here.
+func chi2Tail(x, df float64) float64 {
+	if df <= 0 {
+		return math.NaN()
+	}
+	v := 2 / (9 * df)
+	z := (math.Cbrt(x/df) - (1 - v)) / math.Sqrt(v)
+	return 0.5 * math.Erfc(z/math.Sqrt2)
+}
+
+// generate returns n keys of the named corpus, as the bytes the map hashes.
+func generate(corpus string, n int) ([][]byte, error) {
+	r := rand.New(rand.NewPCG(1, 2))
+	keys := make([][]byte, n)
+	for i := range keys {
+		switch corpus {
+		case "seq":
+			keys[i] = binary.NativeEndian.AppendUint64(nil, uint64(i))
+		case "rand":
+			keys[i] = binary.NativeEndian.AppendUint64(nil, r.Uint64())
+		case "strided":
+			keys[i] = binary.NativeEndian.AppendUint64(nil, uint64(i)<<12)
+		case "decimal":
+			keys[i] = strconv.AppendInt(nil, int64(i), 10)
+		case "uuid":
+			var u [16]byte
+			binary.LittleEndian.PutUint64(u[:8], r.Uint64())
+			binary.LittleEndian.PutUint64(u[8:], r.Uint64())
+			u[6] = u[6]&0x0f | 0x40
+			u[8] = u[8]&0x3f | 0x80
+			keys[i] = fmt.Appendf(nil, "%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+		default:
+			return nil, fmt.Errorf("unknown corpus %q", corpus)
+		}
+	}
+	return keys, nil
+}
+
+// readKeys returns the lines of the file name, without duplicates, in
+// the order they first appear.
+func readKeys(name string) ([][]byte, error) {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var keys [][]byte
+	seen := make(map[string]bool)
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		if seen[s.Text()] {
+			continue
+		}
+		seen[s.Text()] = true
+		keys = append(keys, []byte(s.Text()))
+	}
+	return keys, s.Err()
+}
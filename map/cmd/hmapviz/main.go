@@ -0,0 +1,135 @@
+// Hmapviz fills an hmap.Map[int, int] (or a swiss.Map[int, int]) and
+// draws its buckets with package hmapviz.
+//
+// Usage:
+//
+//	hmapviz [-impl hmap|swiss] [-n 100] [-hint 0] [-del 0] [-shrink 0] [-bucketcnt 8] [-growing] [-format svg|dot] [-o file]
+//
+// -n keys are inserted, then -del of them deleted. With -growing the
+// program keeps inserting until a growth is in progress, so the picture
+// shows old and new buckets side by side; -steps further writes then move
+// the evacuation along. With -shrink the map is created with
+// hmap.WithShrink and -growing deletes keys instead, until a shrink is in
+// progress. -bucketcnt draws buckets of 4, 16 or 32 cells (see
+// hmap.WithBucketCnt). -impl swiss draws a swiss.Map instead, whose
+// growth is never in progress between writes, so -growing does not apply.
+/*
+	hmapviz 命令构造一个 map[int]int 并输出它的桶结构图。
+	加 -growing 会一直插入直到正在扩容，再用 -steps 控制之后还要写几次，
+	可以一步步看 nevacuate 前进、旧桶被搬到新数组的 X/Y 两半。加 -shrink 则改为一直删除直到开始收缩，
+	看旧桶 i 和 i+2^B 合并进新桶 i。
+	-impl swiss 画的是 Swiss table：目录、每张表和它的 group。
+*/
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hmap"
+	"github.com/ProsperousLi/golang-deep-learn/map/hmapviz"
+	"github.com/ProsperousLi/golang-deep-learn/map/mapapi"
+	"github.com/ProsperousLi/golang-deep-learn/map/swiss"
+)
+
+func main() {
+	var (
+		impl    = flag.String("impl", "hmap", "map implementation: hmap or swiss")
+		n       = flag.Int("n", 100, "keys to insert")
+		hint    = flag.Int("hint", 0, "size hint passed to New")
+		del     = flag.Int("del", 0, "keys to delete after inserting")
+		shrink  = flag.Float64("shrink", 0, "hmap.WithShrink fraction; with -growing, delete until shrinking")
+		cnt     = flag.Int("bucketcnt", 8, "cells per hmap bucket: 4, 8, 16 or 32")
+		growing = flag.Bool("growing", false, "keep inserting until the map is growing")
+		steps   = flag.Int("steps", 0, "writes to do after growth starts (with -growing)")
+		format  = flag.String("format", "svg", "output format: svg or dot")
+		out     = flag.String("o", "", "output file (default stdout)")
+	)
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("hmapviz: ")
+
+	var m mapapi.Map[int, int]
+	switch *impl {
+	case "hmap":
+		opts := []hmap.Option{hmap.WithBucketCnt(*cnt)}
+		if *shrink != 0 {
+			opts = append(opts, hmap.WithShrink(*shrink))
+		}
+		m = hmap.New[int, int](*hint, opts...)
+	case "swiss":
+		if *growing {
+			log.Fatal("-growing needs -impl hmap: swiss tables grow within a single write")
+		}
+		m = swiss.New[int, int](*hint)
+	default:
+		log.Fatalf("unknown -impl %q", *impl)
+	}
+	next, deleted := 0, 0
+	put := func() {
+		m.Put(next, next)
+		next++
+	}
+	drop := func() {
+		m.Delete(deleted)
+		deleted++
+	}
+	for range *n {
+		put()
+	}
+	for range min(*del, *n) {
+		drop()
+	}
+	if *growing {
+		hm := m.(*hmap.Map[int, int])
+		write := put
+		if *shrink != 0 {
+			write = drop
+		}
+		for !hm.Growing() && m.Len() > 0 {
+			write()
+		}
+		for range *steps {
+			if !hm.Growing() || m.Len() == 0 {
+				break
+			}
+			write()
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	var err error
+	switch m := m.(type) {
+	case *hmap.Map[int, int]:
+		switch *format {
+		case "svg":
+			err = hmapviz.WriteSVG(w, m.Layout())
+		case "dot":
+			err = hmapviz.WriteDOT(w, m.Layout())
+		default:
+			log.Fatalf("unknown -format %q", *format)
+		}
+	case *swiss.Map[int, int]:
+		switch *format {
+		case "svg":
+			err = hmapviz.WriteSwissSVG(w, m.Layout())
+		case "dot":
+			err = hmapviz.WriteSwissDOT(w, m.Layout())
+		default:
+			log.Fatalf("unknown -format %q", *format)
+		}
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
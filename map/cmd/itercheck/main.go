@@ -0,0 +1,45 @@
+// Itercheck runs the randomized iteration checker of package itercheck
+// against hmap and prints the shrunk program of the first failure.
+//
+// Usage:
+//
+//	itercheck [-programs 2000] [-size 64] [-seed N]
+//
+// It exits with status 1 when a guarantee is broken.
+/*
+	itercheck 命令运行 itercheck 包的随机检查，发现问题时打印收缩后的最小操作序列，退出码为 1。
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/itercheck"
+)
+
+func main() {
+	var (
+		programs = flag.Int("programs", 2000, "random programs to run")
+		size     = flag.Int("size", 64, "ops per program")
+		seed     = flag.Uint64("seed", 0, "program generation seed (0 picks one)")
+	)
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("itercheck: ")
+
+	if *seed == 0 {
+		*seed = rand.Uint64()
+	}
+	fmt.Printf("seed %d: %d programs of %d ops\n", *seed, *programs, *size)
+	f := itercheck.Check(itercheck.Config{Programs: *programs, Size: *size, Seed: *seed})
+	if f == nil {
+		fmt.Println("ok")
+		return
+	}
+	fmt.Printf("FAIL: %v\nshrunk from %d to %d ops:\n%v", f.Violation, len(f.Original.Ops), len(f.Program.Ops), f.Program)
+	os.Exit(1)
+}
@@ -0,0 +1,209 @@
+// Loadfactor regenerates the loadFactor table quoted at the top of
+// map/map.go:
+//
+//	loadFactor    %overflow  bytes/entry     hitprobe    missprobe
+//	      6.50        20.90        10.79         4.25         6.50
+//
+// For every load factor num/den in the sweep it builds tables of 2^B
+// buckets, inserts keys until one more would make overLoadFactor report
+// true (count == num*(2^B/den)), and measures
+//
+//	%overflow   = percentage of buckets which have an overflow bucket
+//	bytes/entry = overhead bytes used per key/elem pair
+//	hitprobe    = # of entries to check when looking up a present key
+//	missprobe   = # of entries to check when looking up an absent key
+//
+// Keys are the integers 0..count-1 stored in keysize bytes and hashed with
+// the runtime's memhash under a random hash0, so the bucket a key lands in
+// is the one mapassign would pick. Within a bucket chain mapassign always
+// takes the first empty cell, so with inserts only an entry's probe count
+// is its position in the chain.
+//
+// Usage:
+//
+//	loadfactor [-num 8:16] [-den 2] [-bucketcnt 8] [-sizes 8/8,16/8,64/64,256/8] [-b 16] [-trials 4]
+//
+// -bucketcnt sweeps bucket sizes other than the runtime's 8 cells, the
+// ones hmap.WithBucketCnt accepts; pair it with -num and -den to pick the
+// arguments of hmap.WithLoadFactor.
+/*
+	loadfactor 重新生成 map.go 开头那张装载因子表。
+	对每个装载因子 num/den，构造 2^B 个桶的表，插入 key 直到再多插一个 overLoadFactor 就会返回 true
+	（即扩容前的最满状态），然后统计溢出桶占比、每个键值对的额外字节、命中与未命中时平均要比较的条目数。
+	key 用 runtime 的 memhash 计算 hash，选桶方式与 mapassign 一致；
+	mapassign 总是占用链上第一个空 cell，所以只插入不删除时，条目在链上的位置就是它的探测次数。
+	-sizes 可以换成自己业务的 key/elem 大小，超过 128 字节时按 runtime 的规则改为间接存储。
+	-bucketcnt 可以换成 4/16/32 格的桶，为 hmap.WithBucketCnt/WithLoadFactor 选参数。
+*/
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/internal/rtalg"
+)
+
+const (
+	maxKeySize  = 128
+	maxElemSize = 128
+	ptrSize     = rtalg.PtrSize
+)
+
+type kvsize struct{ key, elem uintptr }
+
+func main() {
+	var (
+		nums   = flag.String("num", "8:16", "loadFactorNum values, as a single value or lo:hi")
+		den    = flag.Int("den", 2, "loadFactorDen")
+		cnt    = flag.Uint("bucketcnt", 8, "cells per bucket: 4, 8, 16 or 32")
+		sizes  = flag.String("sizes", "8/8", "comma separated keysize/elemsize pairs in bytes")
+		b      = flag.Uint("b", 16, "log2 of the number of buckets per table")
+		trials = flag.Int("trials", 4, "tables averaged per row")
+	)
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("loadfactor: ")
+
+	lo, hi, err := parseRange(*nums)
+	if err != nil {
+		log.Fatal(err)
+	}
+	kvs, err := parseSizes(*sizes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *den <= 0 || *b > 24 || *trials <= 0 {
+		log.Fatal("need -den > 0, -b <= 24 and -trials > 0")
+	}
+	switch *cnt {
+	case 4, 8, 16, 32:
+	default:
+		log.Fatal("need -bucketcnt 4, 8, 16 or 32")
+	}
+
+	for i, kv := range kvs {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("(%d-bit, %d byte keys and %d byte elems, %d cells per bucket, 2^%d buckets, %d tables per row)\n",
+			ptrSize*8, kv.key, kv.elem, *cnt, *b, *trials)
+		fmt.Printf("%12s %12s %12s %12s %12s\n", "loadFactor", "%overflow", "bytes/entry", "hitprobe", "missprobe")
+		for num := lo; num <= hi; num++ {
+			var sum row
+			for range *trials {
+				r := measure(uintptr(num), uintptr(*den), uintptr(*cnt), uint8(*b), kv)
+				sum.overflow += r.overflow
+				sum.bytes += r.bytes
+				sum.hit += r.hit
+				sum.miss += r.miss
+			}
+			t := float64(*trials)
+			fmt.Printf("%12.2f %12.2f %12.2f %12.2f %12.2f\n",
+				float64(num)/float64(*den), sum.overflow/t, sum.bytes/t, sum.hit/t, sum.miss/t)
+		}
+	}
+}
+
+type row struct {
+	overflow, bytes, hit, miss float64
+}
+
+// measure fills one table of 2^B buckets of bucketCnt cells to just
+// below the growth threshold of loadFactorNum/loadFactorDen and reports
+// its statistics.
+func measure(num, den, bucketCnt uintptr, B uint8, kv kvsize) row {
+	nbuckets := uintptr(1) << B
+	count := num * nbuckets / den // largest count for which overLoadFactor is false
+	if count < bucketCnt {
+		count = bucketCnt
+	}
+
+	hash0 := uintptr(rand.Uint32())
+	mask := nbuckets - 1
+	key := make([]byte, max(kv.key, 8))
+	chain := make([]uint32, nbuckets) // entries in each bucket chain
+	for k := uintptr(0); k < count; k++ {
+		binary.LittleEndian.PutUint64(key, uint64(k))
+		hash := rtalg.Memhash(unsafe.Pointer(&key[0]), hash0, kv.key)
+		chain[hash&mask]++
+	}
+
+	var withOverflow, overflowBuckets, hitProbes, missProbes uintptr
+	for _, c := range chain {
+		c := uintptr(c)
+		if c > bucketCnt {
+			withOverflow++
+			overflowBuckets += (c - 1) / bucketCnt
+		}
+		hitProbes += c * (c + 1) / 2 // the i'th entry of a chain is found after i checks
+		missProbes += c              // a miss checks every entry of its chain
+	}
+
+	total := (nbuckets + overflowBuckets) * bucketSize(kv, bucketCnt)
+	if kv.key > maxKeySize {
+		total += count * rtalg.RoundupSize(kv.key) // newobject(t.Key) per entry
+	}
+	if kv.elem > maxElemSize {
+		total += count * rtalg.RoundupSize(kv.elem)
+	}
+	return row{
+		overflow: 100 * float64(withOverflow) / float64(nbuckets),
+		bytes:    float64(total)/float64(count) - float64(kv.key+kv.elem),
+		hit:      float64(hitProbes) / float64(count),
+		miss:     float64(missProbes) / float64(nbuckets),
+	}
+}
+
+// bucketSize is t.BucketSize as computed by reflect's bucketOf, for
+// buckets of bucketCnt cells. The keys start at the first word boundary
+// after tophash, which for 8 cells is the runtime's dataOffset.
+func bucketSize(kv kvsize, bucketCnt uintptr) uintptr {
+	k, e := kv.key, kv.elem
+	if k > maxKeySize {
+		k = ptrSize
+	}
+	if e > maxElemSize {
+		e = ptrSize
+	}
+	dataOffset := (bucketCnt + ptrSize - 1) &^ (ptrSize - 1)
+	size := dataOffset + bucketCnt*k + bucketCnt*e + ptrSize
+	return (size + ptrSize - 1) &^ (ptrSize - 1)
+}
+
+func parseRange(s string) (lo, hi int, err error) {
+	a, b, found := strings.Cut(s, ":")
+	if lo, err = strconv.Atoi(a); err != nil {
+		return 0, 0, fmt.Errorf("bad -num %q: %v", s, err)
+	}
+	hi = lo
+	if found {
+		if hi, err = strconv.Atoi(b); err != nil {
+			return 0, 0, fmt.Errorf("bad -num %q: %v", s, err)
+		}
+	}
+	if lo <= 0 || hi < lo {
+		return 0, 0, fmt.Errorf("bad -num %q: want 0 < lo <= hi", s)
+	}
+	return lo, hi, nil
+}
+
+func parseSizes(s string) ([]kvsize, error) {
+	var kvs []kvsize
+	for _, f := range strings.Split(s, ",") {
+		k, e, ok := strings.Cut(strings.TrimSpace(f), "/")
+		ks, err1 := strconv.ParseUint(k, 10, 32)
+		es, err2 := strconv.ParseUint(e, 10, 32)
+		if !ok || err1 != nil || err2 != nil || ks == 0 {
+			return nil, fmt.Errorf("bad -sizes entry %q: want keysize/elemsize", f)
+		}
+		kvs = append(kvs, kvsize{uintptr(ks), uintptr(es)})
+	}
+	return kvs, nil
+}
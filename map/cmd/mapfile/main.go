@@ -0,0 +1,158 @@
+// Mapfile builds and queries filemap files with uint64 keys and elems.
+//
+// Usage:
+//
+//	mapfile build [-n 1000000] [-seed 0] file   # keys 0..n-1 scrambled, elem = key*key
+//	mapfile build [-seed 0] file < pairs        # "key elem" per line
+//	mapfile get file key...
+//	mapfile stat file
+//	mapfile bench [-n 1000000] file
+//
+// Any number of get, stat and bench commands can run against the same
+// file at once; they all map it read-only and share its pages. bench
+// looks up -n random keys of the generated set and prints the time per
+// lookup.
+/*
+	mapfile 生成和查询 filemap 文件（key、elem 都是 uint64）。多个进程可以同时对同一个文件执行 get/stat/bench，
+	它们只读映射同一个文件、共享同一份页缓存。
+*/
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"math/bits"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/filemap"
+	"github.com/ProsperousLi/golang-deep-learn/map/hmap"
+)
+
+// scramble maps i to a key, a bijection on uint64 so the keys are
+// distinct but not sequential.
+func scramble(i uint64) uint64 {
+	return bits.RotateLeft64(i*0x9E3779B97F4A7C15, 17) ^ 0x5851F42D4C957F2D
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("mapfile: ")
+	if len(os.Args) < 2 {
+		log.Fatal("usage: mapfile build|get|stat|bench [flags] file [keys]")
+	}
+	fs := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	n := fs.Int("n", -1, "generated keys (build) or lookups (bench)")
+	seed := fs.Uint64("seed", 0, "seed for hash0 (0: random)")
+	fs.Parse(os.Args[2:])
+	if fs.NArg() < 1 {
+		log.Fatal("missing file name")
+	}
+	name := fs.Arg(0)
+
+	switch os.Args[1] {
+	case "build":
+		m := hmap.New[uint64, uint64](0)
+		if *n >= 0 {
+			for i := range uint64(*n) {
+				k := scramble(i)
+				m.Put(k, k*k)
+			}
+		} else if err := readPairs(m); err != nil {
+			log.Fatal(err)
+		}
+		var opts []filemap.Option
+		if *seed != 0 {
+			opts = append(opts, filemap.WithSeed(*seed))
+		}
+		if err := filemap.WriteFile(name, m, opts...); err != nil {
+			log.Fatal(err)
+		}
+		stat(name)
+	case "get":
+		m := open(name)
+		defer m.Close()
+		for _, arg := range fs.Args()[1:] {
+			k, err := strconv.ParseUint(arg, 0, 64)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if v, ok := m.Lookup(k); ok {
+				fmt.Println(k, v)
+			} else {
+				fmt.Println(k, "not found")
+			}
+		}
+	case "stat":
+		stat(name)
+	case "bench":
+		m := open(name)
+		defer m.Close()
+		lookups := *n
+		if lookups < 0 {
+			lookups = 1_000_000
+		}
+		r := rand.New(rand.NewPCG(rand.Uint64(), 0))
+		keys := uint64(m.Len())
+		start := time.Now()
+		found := 0
+		for range lookups {
+			if _, ok := m.Lookup(scramble(r.Uint64N(max(keys, 1)))); ok {
+				found++
+			}
+		}
+		d := time.Since(start)
+		fmt.Printf("pid %d: %d lookups, %d found, %.1f ns/lookup\n",
+			os.Getpid(), lookups, found, float64(d.Nanoseconds())/float64(lookups))
+	default:
+		log.Fatalf("unknown command %q", os.Args[1])
+	}
+}
+
+func open(name string) *filemap.Map[uint64, uint64] {
+	m, err := filemap.Open[uint64, uint64](name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return m
+}
+
+func stat(name string) {
+	m := open(name)
+	defer m.Close()
+	fi, err := os.Stat(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	main, overflow := m.Buckets()
+	fmt.Printf("%s: %d entries, %d buckets + %d overflow, %d bytes (%.1f bytes/entry)\n",
+		name, m.Len(), main, overflow, fi.Size(), float64(fi.Size())/float64(max(m.Len(), 1)))
+}
+
+func readPairs(m *hmap.Map[uint64, uint64]) error {
+	sc := bufio.NewScanner(os.Stdin)
+	for line := 1; sc.Scan(); line++ {
+		f := strings.Fields(sc.Text())
+		if len(f) == 0 {
+			continue
+		}
+		if len(f) != 2 {
+			return fmt.Errorf("stdin:%d: want \"key elem\"", line)
+		}
+		k, err := strconv.ParseUint(f[0], 0, 64)
+		if err != nil {
+			return fmt.Errorf("stdin:%d: %v", line, err)
+		}
+		v, err := strconv.ParseUint(f[1], 0, 64)
+		if err != nil {
+			return fmt.Errorf("stdin:%d: %v", line, err)
+		}
+		m.Put(k, v)
+	}
+	return sc.Err()
+}
@@ -0,0 +1,181 @@
+// Mapfuzz runs the differential fuzzing engine of package mapfuzz on
+// random byte streams, or replays a saved one.
+//
+// Usage:
+//
+//	mapfuzz [-inputs 10000] [-max 1024] [-seed N] [-out dir] [-svg]
+//	mapfuzz -replay crash-XXXX.bin [-svg]
+//
+// Each input is -max bytes at most, decoded by mapfuzz.Decode and run
+// against the builtin map. On the first divergence mapfuzz minimizes the
+// program and writes to -out:
+//
+//	crash-XXXX.bin    the minimized input, raw
+//	crash-XXXX        the same input in the go test fuzz corpus format;
+//	                  copied into map/mapfuzz/testdata/fuzz/FuzzMap, it
+//	                  makes go test rerun the failure
+//	crash-XXXX.txt    the report of mapfuzz.Dump: the program and the
+//	                  bucket layout before and after the step that
+//	                  diverged, with its growth events
+//
+// -svg renders both layouts with hmapviz as well. -replay takes either
+// form of the input. The exit status is 1 after a divergence.
+/*
+	mapfuzz 命令用随机字节流驱动 mapfuzz 差分测试引擎（或用 -replay 重放保存下来的输入）。
+	发现不一致时先收缩程序，再把最小输入写成 crash-XXXX.bin 和 go test 语料格式的 crash-XXXX（放进 map/mapfuzz/testdata/fuzz/FuzzMap 即成为回归用例），把出错步骤前后的桶布局、扩容事件写成 crash-XXXX.txt，
+	-svg 还会用 hmapviz 画出前后两张布局图。
+*/
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hmap"
+	"github.com/ProsperousLi/golang-deep-learn/map/hmapviz"
+	"github.com/ProsperousLi/golang-deep-learn/map/mapfuzz"
+)
+
+func main() {
+	var (
+		inputs = flag.Int("inputs", 10000, "random inputs to run")
+		maxLen = flag.Int("max", 1024, "maximum input length in bytes")
+		seed   = flag.Uint64("seed", 0, "input generation seed (0 picks one)")
+		out    = flag.String("out", ".", "directory for crash files")
+		svg    = flag.Bool("svg", false, "also render the layouts around the divergence as SVG")
+		replay = flag.String("replay", "", "replay this input and print its report instead of fuzzing")
+	)
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("mapfuzz: ")
+
+	if *replay != "" {
+		data, err := readInput(*replay)
+		if err != nil {
+			log.Fatal(err)
+		}
+		p := mapfuzz.Decode(data)
+		d := mapfuzz.Run(p)
+		if d == nil {
+			fmt.Printf("%s: no divergence in %d ops\n", *replay, len(p.Ops))
+			return
+		}
+		if err := mapfuzz.Dump(os.Stdout, p, d); err != nil {
+			log.Fatal(err)
+		}
+		if *svg {
+			writeSVGs(*replay, p, d)
+		}
+		os.Exit(1)
+	}
+
+	if *seed == 0 {
+		*seed = rand.Uint64()
+	}
+	if *maxLen < 2 {
+		log.Fatal("-max must be at least 2")
+	}
+	fmt.Printf("seed %d: %d inputs of up to %d bytes\n", *seed, *inputs, *maxLen)
+	r := rand.New(rand.NewPCG(*seed, 0))
+	ops := 0
+	for range *inputs {
+		data := make([]byte, 2+r.IntN(*maxLen-1))
+		for i := range data {
+			data[i] = byte(r.Uint32())
+		}
+		p := mapfuzz.Decode(data)
+		ops += len(p.Ops)
+		d := mapfuzz.Run(p)
+		if d == nil {
+			continue
+		}
+		fmt.Printf("FAIL: %v\n", d)
+		min := mapfuzz.Minimize(p)
+		d = mapfuzz.Run(min)
+		fmt.Printf("minimized from %d to %d ops: %v\n", len(p.Ops), len(min.Ops), d)
+		enc := mapfuzz.Encode(min)
+		base := filepath.Join(*out, fmt.Sprintf("crash-%x", sha256.Sum256(enc))[:len("crash-")+16])
+		if err := os.WriteFile(base+".bin", enc, 0o644); err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(base, corpusEntry(enc), 0o644); err != nil {
+			log.Fatal(err)
+		}
+		f, err := os.Create(base + ".txt")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := mapfuzz.Dump(f, min, d); err != nil {
+			log.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("wrote %s.bin, %s and %s.txt\n", base, base, base)
+		if *svg {
+			writeSVGs(base, min, d)
+		}
+		os.Exit(1)
+	}
+	fmt.Printf("ok: %d ops\n", ops)
+}
+
+// corpusHeader starts a file of the go test fuzz corpus.
+const corpusHeader = "go test fuzz v1\n"
+
+// corpusEntry returns data as a go test fuzz corpus file for a target
+// taking one []byte, such as FuzzMap.
+func corpusEntry(data []byte) []byte {
+	return fmt.Appendf(nil, "%s[]byte(%s)\n", corpusHeader, strconv.Quote(string(data)))
+}
+
+// readInput reads a raw input, or one in the corpus format of
+// corpusEntry.
+func readInput(name string) ([]byte, error) {
+	data, err := os.ReadFile(name)
+	if err != nil || !bytes.HasPrefix(data, []byte(corpusHeader)) {
+		return data, err
+	}
+	v := strings.TrimSpace(string(data[len(corpusHeader):]))
+	if !strings.HasPrefix(v, "[]byte(") || !strings.HasSuffix(v, ")") {
+		return nil, fmt.Errorf("%s: not a corpus file of a single []byte", name)
+	}
+	s, err := strconv.Unquote(v[len("[]byte(") : len(v)-1])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return []byte(s), nil
+}
+
+// writeSVGs renders the layouts before and after the diverging step to
+// base.before.svg and base.after.svg.
+func writeSVGs(base string, p mapfuzz.Program, d *mapfuzz.Divergence) {
+	before, after, _ := mapfuzz.Replay(p, d)
+	for _, l := range []struct {
+		name string
+		l    *hmap.Layout
+	}{{"before", before}, {"after", after}} {
+		if l.l == nil {
+			continue
+		}
+		f, err := os.Create(base + "." + l.name + ".svg")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := hmapviz.WriteSVG(f, l.l); err != nil {
+			log.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("wrote %s\n", f.Name())
+	}
+}
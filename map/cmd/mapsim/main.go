@@ -0,0 +1,256 @@
+// Mapsim runs a small script against an hmap.Map[int, int] and prints
+// the state of the map after every line: B, count, noverflow, whether
+// it is growing (doubling, same size or shrinking) and how far
+// nevacuate has got. It is meant for reading map/map.go with a map at
+// hand: every line of a script is one call into the code annotated
+// there.
+//
+// Usage:
+//
+//	mapsim [-grid] [-trace] [-bucketcnt 8] [-hint 0] [-shrink 0] [-seed 1] [script]
+//
+// The script is read from the named file, or from standard input. Each
+// line is one of
+//
+//	put K V   m[K] = V (mapassign)
+//	del K     delete(m, K) (mapdelete)
+//	get K     print m[K] (mapaccess2)
+//	iter N    advance the iterator N entries and print them; the first
+//	          iter, and the first after the iterator is done, starts one
+//	          (mapiterinit, mapiternext)
+//	clear     clear(m) (mapclear)
+//	clone     go on with maps.Clone(m) (mapclone2)
+//	grow      start a growth, or evacuate the next old bucket (Map.Grow)
+//
+// with integer K, V and N. Blank lines and lines starting with # are
+// skipped. The iterator lives across writes, as a range loop does, so
+// a script can watch it cope with a growth that starts under it.
+//
+// -grid prints every bucket after each line, in the text form of
+// hmapviz.WriteText: a cell is tophash:key when filled, and _, -, x, y
+// or e when it is emptyRest, emptyOne, evacuatedX, evacuatedY or
+// evacuatedEmpty. -trace prints the growth events of each line as JSON
+// (see hmap.JSONTracer); a clone has no tracer, so there are none after
+// a clone line. The map is created with hmap.WithSeed(-seed), so a
+// script prints the same every time.
+//
+// For example, with -bucketcnt 4 the thirteenth put starts doubling
+// from 4 to 8 buckets and evacuates two of the four old buckets; an
+// iterator started then walks old and new buckets together, two grow
+// lines finish the doubling, and a third starts a same-size growth:
+//
+//	for i in $(seq 13); do echo put $i $i; done |
+//		(cat; echo iter 3; echo grow; echo grow; echo iter 20; echo grow) |
+//		mapsim -bucketcnt 4 -trace
+/*
+	mapsim 按脚本（put/del/get/iter/clear/clone/grow，每行一个操作）操作 hmap.Map[int, int]，每执行一行就打印
+	B、count、noverflow、是否在扩容（翻倍、等量或收缩）以及 nevacuate 的进度；-grid 再打印所有桶的 tophash 网格，
+	-trace 打印这一行触发的扩容事件。迭代器跨行存在，可以观察扩容中途迭代的行为。
+	对照 map/map.go 的注释逐行运行脚本，比直接读两千行代码更容易理解 mapassign、evacuate、mapiternext 做了什么。
+*/
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hmap"
+	"github.com/ProsperousLi/golang-deep-learn/map/hmapviz"
+)
+
+// sim is the state a script runs in.
+type sim struct {
+	m      *hmap.Map[int, int]
+	it     *hmap.Iter[int, int] // nil until iter, and again once it is done
+	events bytes.Buffer         // growth events of the current line, as JSON lines
+	out    *bufio.Writer
+	grid   bool
+}
+
+// args parses the integer arguments of a script line.
+func args(f []string, n int) ([]int, error) {
+	if len(f)-1 != n {
+		return nil, fmt.Errorf("%s takes %d arguments", f[0], n)
+	}
+	v := make([]int, n)
+	for i := range v {
+		x, err := strconv.Atoi(f[i+1])
+		if err != nil {
+			return nil, err
+		}
+		v[i] = x
+	}
+	return v, nil
+}
+
+// exec runs one script line and returns what it printed as a result,
+// if anything.
+func (s *sim) exec(f []string) (string, error) {
+	switch f[0] {
+	case "put", "del", "get", "iter":
+	case "clear", "clone", "grow":
+		if len(f) != 1 {
+			return "", fmt.Errorf("%s takes no arguments", f[0])
+		}
+	default:
+		return "", fmt.Errorf("unknown operation %q", f[0])
+	}
+	switch f[0] {
+	case "put":
+		a, err := args(f, 2)
+		if err != nil {
+			return "", err
+		}
+		s.m.Put(a[0], a[1])
+	case "del":
+		a, err := args(f, 1)
+		if err != nil {
+			return "", err
+		}
+		s.m.Delete(a[0])
+	case "get":
+		a, err := args(f, 1)
+		if err != nil {
+			return "", err
+		}
+		if v, ok := s.m.Lookup(a[0]); ok {
+			return strconv.Itoa(v), nil
+		}
+		return "not found", nil
+	case "iter":
+		a, err := args(f, 1)
+		if err != nil {
+			return "", err
+		}
+		return s.iter(a[0]), nil
+	case "clear":
+		s.m.Clear()
+	case "clone":
+		s.m, s.it = s.m.Clone(), nil
+	case "grow":
+		s.m.Grow()
+	}
+	return "", nil
+}
+
+// iter advances the iterator n entries.
+func (s *sim) iter(n int) string {
+	var b strings.Builder
+	if s.it == nil {
+		s.it = s.m.Iter()
+		b.WriteString("(new iterator) ")
+	}
+	for i := 0; i < n; i++ {
+		if !s.it.Next() {
+			s.it = nil
+			b.WriteString("done")
+			break
+		}
+		fmt.Fprintf(&b, "%d:%d ", s.it.Key(), s.it.Elem())
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// state is the one-line summary of the map printed after each line.
+func state(l *hmap.Layout) string {
+	s := fmt.Sprintf("B=%d count=%d noverflow=%d", l.B, l.Count, l.NOverflow)
+	if l.OldBuckets == nil {
+		return s
+	}
+	kind, total := "growing", len(l.OldBuckets)
+	switch {
+	case l.SameSizeGrow:
+		kind = "sameSizeGrow"
+	case l.Shrinking:
+		// nevacuate counts new buckets while shrinking.
+		kind, total = "shrinking", len(l.Buckets)
+	}
+	return fmt.Sprintf("%s %s nevacuate=%d/%d", s, kind, l.NEvacuate, total)
+}
+
+func (s *sim) run(r io.Reader, name string) error {
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		f := strings.Fields(text)
+		if len(f) == 0 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		s.events.Reset()
+		res, err := s.exec(f)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", name, line, err)
+		}
+		l := s.m.Layout()
+		if res != "" {
+			text += " -> " + res
+		}
+		fmt.Fprintf(s.out, "%-24s %s\n", text, state(l))
+		for _, ev := range strings.SplitAfter(s.events.String(), "\n") {
+			if ev != "" {
+				fmt.Fprintf(s.out, "    %s", ev)
+			}
+		}
+		if s.grid {
+			if err := hmapviz.WriteText(s.out, l); err != nil {
+				return err
+			}
+			fmt.Fprintln(s.out)
+		}
+	}
+	return sc.Err()
+}
+
+func main() {
+	var (
+		grid      = flag.Bool("grid", false, "print every bucket after each line")
+		trace     = flag.Bool("trace", false, "print the growth events of each line")
+		bucketCnt = flag.Int("bucketcnt", 8, "cells per bucket (hmap.WithBucketCnt)")
+		hint      = flag.Int("hint", 0, "size hint passed to New")
+		shrink    = flag.Float64("shrink", 0, "hmap.WithShrink fraction (0: never shrink)")
+		seed      = flag.Uint64("seed", 1, "hash seed (hmap.WithSeed)")
+	)
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("mapsim: ")
+
+	var r io.Reader = os.Stdin
+	name := "stdin"
+	switch flag.NArg() {
+	case 0:
+	case 1:
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		r, name = f, flag.Arg(0)
+	default:
+		log.Fatal("at most one script")
+	}
+
+	s := &sim{out: bufio.NewWriter(os.Stdout), grid: *grid}
+	opts := []hmap.Option{hmap.WithSeed(*seed), hmap.WithBucketCnt(*bucketCnt)}
+	if *shrink != 0 {
+		opts = append(opts, hmap.WithShrink(*shrink))
+	}
+	if *trace {
+		opts = append(opts, hmap.WithTracer(hmap.NewJSONTracer(&s.events)))
+	}
+	s.m = hmap.New[int, int](*hint, opts...)
+
+	err := s.run(r, name)
+	if ferr := s.out.Flush(); err == nil {
+		err = ferr
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
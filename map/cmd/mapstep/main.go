@@ -0,0 +1,471 @@
+// Mapstep steps through single writes to an hmap.Map[int, int], inside
+// mapassign and mapdelete: the bucket being scanned, the cell whose
+// tophash is being compared, the free cell recorded as insertb/inserti,
+// the goto again after hashGrow, and the loop of mapdelete that turns
+// trailing emptyOne cells into emptyRest. It needs the probes of package
+// hmap, which are only compiled in with -tags hmapstep:
+//
+//	go run -tags hmapstep ./map/cmd/mapstep [-bucketcnt 8] [-hint 0] [-shrink 0] [-seed 1] [-init script]
+//
+// -init runs a mapsim script (put K V and del K lines) first, without
+// stopping, to set the map up. Then, at the "> " prompt:
+//
+//	put K V      step through m[K] = V
+//	del K        step through delete(m, K)
+//	get K        print m[K]
+//	fill A B     put the keys A to B without stopping
+//	show         print every bucket (see hmapviz.WriteText)
+//	break KIND   stop at every step of KIND (see below)
+//	delete KIND  remove the breakpoint; delete alone removes them all
+//	breaks       list the breakpoints
+//	quit
+//
+// Inside a write the prompt names the function, and takes
+//
+//	next [N]     go on N steps (1 by default); an empty line repeats
+//	continue     go on to the next breakpoint or the end of the write
+//	finish       go on to the end of the write, ignoring breakpoints
+//	show, where  print every bucket, or the current step again
+//	break, delete, breaks
+//
+// Each stop prints the step, what the code does there, and the chain of
+// buckets being scanned with the current cell in >< and the recorded
+// free cell marked ^. A KIND is a step of hmap.StepKind (hash, growwork,
+// bucket, cell, freecell, found, hashgrow, newoverflow, insert,
+// emptyone, emptyrest, shrink, done) or a growth event of hmap.Tracer
+// (grow, evacuate, overflow, nevacuate, release), which stops inside
+// growWork or hashGrow as the event is emitted.
+/*
+	mapstep 是一个单步调试 map 写操作的 REPL：在 mapassign、mapdelete 内部逐步执行，显示正在扫描的桶、正在比较 tophash 的 cell、
+	记录为 insertb/inserti 的空位、hashGrow 之后的 goto again，以及 mapdelete 向前把 emptyOne 改成 emptyRest 的循环。
+	支持 next/continue/finish 和按步骤类型或扩容事件（evacuate 等）设置断点。需要用 -tags hmapstep 编译，
+	否则 hmap 中的探针调用会被编译器删除。
+*/
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hmap"
+	"github.com/ProsperousLi/golang-deep-learn/map/hmapviz"
+)
+
+// eventKinds are the kinds of the growth events of hmap.Tracer.
+var eventKinds = []string{"grow", "evacuate", "overflow", "nevacuate", "release"}
+
+// debugger is both the Probe and the Tracer of the map, and stops a
+// write by not returning from them until a command resumes it.
+type debugger struct {
+	m   *hmap.Map[int, int]
+	in  *bufio.Scanner
+	out *bufio.Writer
+	eof bool
+
+	breaks  map[string]bool
+	skip    int  // steps to go on before stopping again
+	running bool // continue: stop only at breakpoints
+	finish  bool // stop nowhere until the write returns
+	last    string
+
+	step   hmap.Step
+	free   [3]int // bucket, overflow and cell of insertb/inserti; free[0] is -1 when none
+	events bytes.Buffer
+	json   *hmap.JSONTracer
+}
+
+func newDebugger(in io.Reader, out io.Writer) *debugger {
+	d := &debugger{
+		in:     bufio.NewScanner(in),
+		out:    bufio.NewWriter(out),
+		breaks: make(map[string]bool),
+	}
+	d.json = hmap.NewJSONTracer(&d.events)
+	return d
+}
+
+// Step implements hmap.Probe.
+func (d *debugger) Step(s *hmap.Step) {
+	d.step = *s
+	switch s.Kind {
+	case hmap.StepHash, hmap.StepHashGrow:
+		d.free[0] = -1
+	case hmap.StepFreeCell:
+		d.free = [3]int{int(s.Bucket), s.Overflow, s.Cell}
+	}
+	if d.finish {
+		return
+	}
+	stop := d.breaks[s.Kind.String()]
+	if !d.running {
+		if d.skip > 0 {
+			d.skip--
+		} else {
+			stop = true
+		}
+	}
+	if stop {
+		d.where()
+		d.prompt()
+	}
+}
+
+// Trace implements hmap.Tracer.
+func (d *debugger) Trace(e hmap.Event) {
+	d.json.Trace(e)
+	if d.finish {
+		d.events.Reset()
+		return
+	}
+	fmt.Fprintf(d.out, "    event %s", d.events.String())
+	d.events.Reset()
+	if d.breaks[e.Kind()] {
+		fmt.Fprintf(d.out, "stopped at %s event, after step %s\n", e.Kind(), d.step.Kind)
+		d.prompt()
+	}
+}
+
+// explain says what the code does at step s.
+func explain(s *hmap.Step) string {
+	switch s.Kind {
+	case hmap.StepHash:
+		return fmt.Sprintf("hash %#x: the low %d bits select bucket %d, the top 8 bits give top %d",
+			s.Hash, s.B, s.Bucket, s.Top)
+	case hmap.StepGrowWork:
+		return fmt.Sprintf("growing: growWork evacuates what feeds bucket %d, then the old bucket at nevacuate", s.Bucket)
+	case hmap.StepBucket:
+		if s.Overflow == 0 {
+			return fmt.Sprintf("scan bucket %d", s.Bucket)
+		}
+		return fmt.Sprintf("follow b.overflow to overflow bucket %d of bucket %d", s.Overflow, s.Bucket)
+	case hmap.StepCell:
+		switch {
+		case s.Tophash == s.Top:
+			return fmt.Sprintf("cell %d: tophash %d == top, compare the keys", s.Cell, s.Tophash)
+		case hmap.CellOf(s.Tophash) == hmap.CellEmptyRest:
+			return fmt.Sprintf("cell %d: emptyRest, nothing after it in the chain: stop scanning", s.Cell)
+		case hmap.CellOf(s.Tophash) == hmap.CellEmptyOne:
+			return fmt.Sprintf("cell %d: emptyOne, free but later cells may be filled: go on", s.Cell)
+		}
+		return fmt.Sprintf("cell %d: tophash %d != top %d, next cell", s.Cell, s.Tophash, s.Top)
+	case hmap.StepFreeCell:
+		return fmt.Sprintf("first free cell: insertb, inserti = this bucket, %d; the scan goes on in case the key is further", s.Cell)
+	case hmap.StepFound:
+		if s.Op == "mapdelete" {
+			return fmt.Sprintf("key %s is in cell %d: clear its key and elem", s.Key, s.Cell)
+		}
+		return fmt.Sprintf("key %s is in cell %d: update the key if needKeyUpdate, return its elem (goto done)", s.Key, s.Cell)
+	case hmap.StepHashGrow:
+		return fmt.Sprintf("not found, and count+1 is over the load factor or there are too many overflow buckets: "+
+			"hashGrow to B=%d, then goto again, as the bucket is now %d", s.B, s.Bucket)
+	case hmap.StepNewOverflow:
+		return fmt.Sprintf("not found and no free cell in the chain: newoverflow links overflow bucket %d, insert at its cell 0", s.Overflow)
+	case hmap.StepInsert:
+		return fmt.Sprintf("store key, elem and tophash %d at insertb/inserti (cell %d); count++ = %d", s.Top, s.Cell, s.Count)
+	case hmap.StepEmptyOne:
+		return fmt.Sprintf("cell %d = emptyOne; if every cell after it is emptyRest, it becomes emptyRest too", s.Cell)
+	case hmap.StepEmptyRest:
+		return fmt.Sprintf("cell %d = emptyRest; walk back while the previous cell (across buckets of the chain) is emptyOne", s.Cell)
+	case hmap.StepShrink:
+		return fmt.Sprintf("count %d under the shrink threshold: hashShrink to B=%d", s.Count, s.B)
+	case hmap.StepDone:
+		return fmt.Sprintf("return; count %d, B %d", s.Count, s.B)
+	}
+	return ""
+}
+
+// where prints the current step and the chain it is in.
+func (d *debugger) where() {
+	s := &d.step
+	fmt.Fprintf(d.out, "%s %s: %s\n", s.Op, s.Kind, explain(s))
+	l := d.m.Layout()
+	if int(s.Bucket) >= len(l.Buckets) || s.Kind == hmap.StepDone || s.Kind == hmap.StepShrink {
+		return
+	}
+	fmt.Fprintf(d.out, "  bucket %d:", s.Bucket)
+	for j, b := range l.Buckets[s.Bucket] {
+		if j > 0 {
+			fmt.Fprint(d.out, " ->")
+		}
+		fmt.Fprint(d.out, " [")
+		for k, top := range b.Tophash {
+			if k > 0 {
+				fmt.Fprint(d.out, " ")
+			}
+			if d.free == [3]int{int(s.Bucket), j, k} {
+				fmt.Fprint(d.out, "^")
+			}
+			c := hmapviz.CellText(top, b.Keys[k])
+			if s.Cell == k && s.Overflow == j {
+				c = ">" + c + "<"
+			}
+			fmt.Fprint(d.out, c)
+		}
+		fmt.Fprint(d.out, "]")
+	}
+	fmt.Fprintln(d.out)
+}
+
+// prompt reads commands until one resumes the write.
+func (d *debugger) prompt() {
+	for {
+		fmt.Fprintf(d.out, "(%s) ", d.step.Op)
+		f, ok := d.read()
+		if !ok {
+			d.finish = true
+			return
+		}
+		if len(f) == 0 {
+			if d.last == "" {
+				continue
+			}
+			f = strings.Fields(d.last)
+		}
+		d.last = strings.Join(f, " ")
+		switch f[0] {
+		case "n", "next":
+			n := 1
+			if len(f) > 1 {
+				var err error
+				if n, err = strconv.Atoi(f[1]); err != nil || n < 1 {
+					fmt.Fprintln(d.out, "next takes a positive count")
+					continue
+				}
+			}
+			d.running, d.skip = false, n-1
+			return
+		case "c", "continue":
+			d.running = true
+			return
+		case "f", "finish":
+			d.finish = true
+			return
+		case "w", "where":
+			d.where()
+		default:
+			d.common(f)
+		}
+	}
+}
+
+// common runs the commands available both inside and outside a write.
+func (d *debugger) common(f []string) {
+	switch f[0] {
+	case "s", "show":
+		hmapviz.WriteText(d.out, d.m.Layout())
+	case "b", "break":
+		for _, k := range f[1:] {
+			if !validKind(k) {
+				fmt.Fprintf(d.out, "unknown step or event %q\n", k)
+				continue
+			}
+			d.breaks[k] = true
+		}
+		d.listBreaks()
+	case "d", "delete":
+		if len(f) == 1 {
+			clear(d.breaks)
+		}
+		for _, k := range f[1:] {
+			delete(d.breaks, k)
+		}
+		d.listBreaks()
+	case "breaks":
+		d.listBreaks()
+	case "h", "help":
+		fmt.Fprintln(d.out, "inside a write: next [N], continue, finish, where; anywhere: show, break KIND, delete [KIND], breaks")
+		fmt.Fprintln(d.out, "outside: put K V, del K, get K, fill A B, quit")
+	default:
+		fmt.Fprintf(d.out, "unknown command %q (try help)\n", f[0])
+	}
+}
+
+func validKind(k string) bool {
+	for _, s := range hmap.StepKinds() {
+		if s.String() == k {
+			return true
+		}
+	}
+	return slices.Contains(eventKinds, k)
+}
+
+func (d *debugger) listBreaks() {
+	ks := make([]string, 0, len(d.breaks))
+	for k := range d.breaks {
+		ks = append(ks, k)
+	}
+	slices.Sort(ks)
+	fmt.Fprintf(d.out, "breakpoints: %s\n", strings.Join(ks, " "))
+}
+
+// read flushes the output and reads one line of input as fields.
+func (d *debugger) read() ([]string, bool) {
+	d.out.Flush()
+	if d.eof || !d.in.Scan() {
+		d.eof = true
+		return nil, false
+	}
+	return strings.Fields(d.in.Text()), true
+}
+
+// ints parses the n integer arguments of a command.
+func ints(f []string, n int) ([]int, error) {
+	if len(f)-1 != n {
+		return nil, fmt.Errorf("%s takes %d arguments", f[0], n)
+	}
+	v := make([]int, n)
+	for i := range v {
+		x, err := strconv.Atoi(f[i+1])
+		if err != nil {
+			return nil, err
+		}
+		v[i] = x
+	}
+	return v, nil
+}
+
+// write runs f, a write to the map, stopping at its first step.
+func (d *debugger) write(f func()) {
+	d.running, d.skip, d.finish, d.last = false, 0, false, ""
+	d.free[0] = -1
+	f()
+	d.finish = false
+	fmt.Fprintf(d.out, "count %d\n", d.m.Len())
+}
+
+// command runs one top-level command and reports whether to go on.
+func (d *debugger) command(f []string) bool {
+	switch f[0] {
+	case "q", "quit":
+		return false
+	case "put":
+		a, err := ints(f, 2)
+		if err != nil {
+			fmt.Fprintln(d.out, err)
+			break
+		}
+		d.write(func() { d.m.Put(a[0], a[1]) })
+	case "del":
+		a, err := ints(f, 1)
+		if err != nil {
+			fmt.Fprintln(d.out, err)
+			break
+		}
+		d.write(func() { d.m.Delete(a[0]) })
+	case "get":
+		a, err := ints(f, 1)
+		if err != nil {
+			fmt.Fprintln(d.out, err)
+			break
+		}
+		if v, ok := d.m.Lookup(a[0]); ok {
+			fmt.Fprintln(d.out, v)
+		} else {
+			fmt.Fprintln(d.out, "not found")
+		}
+	case "fill":
+		a, err := ints(f, 2)
+		if err != nil {
+			fmt.Fprintln(d.out, err)
+			break
+		}
+		d.quietly(func() {
+			for k := a[0]; k <= a[1]; k++ {
+				d.m.Put(k, k)
+			}
+		})
+		fmt.Fprintf(d.out, "count %d\n", d.m.Len())
+	default:
+		d.common(f)
+	}
+	return true
+}
+
+// quietly runs f without stopping or printing events.
+func (d *debugger) quietly(f func()) {
+	d.finish = true
+	f()
+	d.finish = false
+}
+
+// initScript runs the put and del lines of a mapsim script.
+func (d *debugger) initScript(name string) error {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	for i, line := range strings.Split(string(b), "\n") {
+		f := strings.Fields(line)
+		if len(f) == 0 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		var a []int
+		switch f[0] {
+		case "put":
+			if a, err = ints(f, 2); err == nil {
+				d.quietly(func() { d.m.Put(a[0], a[1]) })
+			}
+		case "del":
+			if a, err = ints(f, 1); err == nil {
+				d.quietly(func() { d.m.Delete(a[0]) })
+			}
+		default:
+			err = fmt.Errorf("only put and del lines are run")
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", name, i+1, err)
+		}
+	}
+	return nil
+}
+
+func main() {
+	var (
+		bucketCnt = flag.Int("bucketcnt", 8, "cells per bucket (hmap.WithBucketCnt)")
+		hint      = flag.Int("hint", 0, "size hint passed to New")
+		shrink    = flag.Float64("shrink", 0, "hmap.WithShrink fraction (0: never shrink)")
+		seed      = flag.Uint64("seed", 1, "hash seed (hmap.WithSeed)")
+		initFile  = flag.String("init", "", "mapsim script of put and del lines to run first")
+	)
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("mapstep: ")
+	if !hmap.Probing {
+		log.Fatal("built without the hmap probes; run with go run -tags hmapstep")
+	}
+
+	d := newDebugger(os.Stdin, os.Stdout)
+	opts := []hmap.Option{hmap.WithSeed(*seed), hmap.WithBucketCnt(*bucketCnt), hmap.WithProbe(d), hmap.WithTracer(d)}
+	if *shrink != 0 {
+		opts = append(opts, hmap.WithShrink(*shrink))
+	}
+	d.m = hmap.New[int, int](*hint, opts...)
+	if *initFile != "" {
+		if err := d.initScript(*initFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	for {
+		fmt.Fprint(d.out, "> ")
+		f, ok := d.read()
+		if !ok {
+			break
+		}
+		if len(f) > 0 && !d.command(f) {
+			break
+		}
+	}
+	fmt.Fprintln(d.out)
+	if err := d.out.Flush(); err != nil {
+		log.Fatal(err)
+	}
+}
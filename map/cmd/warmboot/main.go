@@ -0,0 +1,112 @@
+// Warmboot measures what hmap snapshots save at startup. It fills an
+// hmap.Map[uint64, [2]uint64] with -n random keys, writes it with Save,
+// and compares the time to Load the file against the time to rebuild the
+// table with Put, which hashes every key again.
+//
+// Usage:
+//
+//	warmboot [-n 10000000] [-o file] [-keep]
+//
+// The snapshot goes to -o, or to a temporary file removed afterwards
+// unless -keep is given. Load is timed after the file has been written,
+// so it usually comes from the page cache; drop the cache first to see
+// the cost of the disk as well.
+/*
+	warmboot 对比服务启动时两种预热方式：逐个 Put 重建（每个 key 都要重新 hash、找桶）
+	与用 hmap.Load 从快照直接还原桶数组（不计算任何 hash）。
+*/
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"time"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hmap"
+)
+
+type elem = [2]uint64
+
+func main() {
+	var (
+		n    = flag.Int("n", 10_000_000, "number of keys")
+		out  = flag.String("o", "", "snapshot file (default: a temporary file)")
+		keep = flag.Bool("keep", false, "keep the temporary snapshot file")
+	)
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("warmboot: ")
+
+	r := rand.New(rand.NewPCG(1, 2))
+	keys := make([]uint64, *n)
+	for i := range keys {
+		keys[i] = r.Uint64()
+	}
+
+	start := time.Now()
+	m := hmap.New[uint64, elem](0)
+	for i, k := range keys {
+		m.Put(k, elem{k, uint64(i)})
+	}
+	rebuild := time.Since(start)
+
+	f, err := create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *out == "" && !*keep {
+		defer os.Remove(f.Name())
+	}
+	start = time.Now()
+	w := bufio.NewWriterSize(f, 1<<20)
+	if err := m.Save(w); err != nil {
+		log.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	save := time.Since(start)
+	fi, err := f.Stat()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	start = time.Now()
+	f, err = os.Open(f.Name())
+	if err != nil {
+		log.Fatal(err)
+	}
+	l, err := hmap.Load[uint64, elem](bufio.NewReaderSize(f, 1<<20))
+	f.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	load := time.Since(start)
+
+	for i, k := range keys {
+		if v, ok := l.Lookup(k); !ok || v != (elem{k, uint64(i)}) {
+			log.Fatalf("key %d lost by Save/Load", k)
+		}
+	}
+
+	mb := float64(fi.Size()) / (1 << 20)
+	fmt.Printf("%d keys, snapshot %s: %.1f MB (%.1f bytes/key)\n", *n, f.Name(), mb, float64(fi.Size())/float64(*n))
+	fmt.Printf("rebuild with Put  %v\n", rebuild)
+	fmt.Printf("Save              %v (%.0f MB/s)\n", save, mb/save.Seconds())
+	fmt.Printf("Load              %v (%.0f MB/s, %.1fx faster than rebuilding)\n",
+		load, mb/load.Seconds(), rebuild.Seconds()/load.Seconds())
+}
+
+func create(name string) (*os.File, error) {
+	if name == "" {
+		return os.CreateTemp("", "warmboot-*.snap")
+	}
+	return os.Create(name)
+}
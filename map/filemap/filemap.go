@@ -0,0 +1,261 @@
+// Package filemap is a read-only hash map whose buckets live in a file.
+// Write lays a map out as the runtime would (8-cell buckets selected by
+// the low B bits of the hash, tophash bytes, overflow chains) straight
+// into the file, and Open maps the file into memory and answers lookups
+// with the mapaccess2 loop over the mapped bytes. Nothing is decoded or
+// rehashed on Open, and any number of processes can map the same file
+// and share its pages.
+//
+// This only works for keys and elems without pointers: the case in which
+// the runtime marks a bucket type as containing no pointers and keeps
+// mapextra.overflow alive so the GC still sees the overflow buckets. In
+// a file there is no GC to tell, and the overflow pointer is replaced by
+// the offset of the next bucket in the file.
+/*
+	filemap 是一个只读 map，桶数组和溢出桶都放在文件里：Write 按 runtime 的布局（8 格的桶、低 B 位选桶、
+	tophash、溢出链）直接把 map 写进文件，Open 用 mmap 把文件映射进来，查找时在映射的内存上跑 mapaccess2 的循环。
+	打开时不需要解码也不需要重新 hash，多个进程可以同时只读映射同一个文件，共享同一份物理页。
+	前提是 key 和 elem 都不含指针，也就是 runtime 里 t.Bucket.PtrBytes == 0、靠 mapextra.overflow
+	保活溢出桶的那种情况；文件里没有 GC，溢出桶指针换成了下一个桶在文件中的偏移量。
+*/
+package filemap
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"unsafe"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/internal/rtalg"
+)
+
+const (
+	bucketCntBits = 3
+	bucketCnt     = 1 << bucketCntBits
+
+	// Values of tophash, as in map/map.go. A file is never written to
+	// after Write, so the only empty cells are emptyRest ones.
+	emptyRest  = 0
+	minTopHash = 5
+
+	// The load factor of map/map.go and hmap: 13/16 of a bucket rounded
+	// down, 12/2 = 6 entries per bucket.
+	loadFactorDen = 2
+	loadFactorNum = (bucketCnt * 13 / 16) * loadFactorDen
+
+	magic         = "HMAPFILE"
+	version       = 1
+	byteOrderMark = 0x01020304
+	headerSize    = 128 // room for header, keeping the buckets 64-byte aligned
+	ptrSize       = rtalg.PtrSize
+)
+
+// header is the start of the file, in native byte order.
+type header struct {
+	Magic      [8]byte
+	Version    uint32
+	ByteOrder  uint32
+	KeySize    uint64
+	ElemSize   uint64
+	BucketSize uint64
+	Count      uint64
+	NBuckets   uint64 // 1<<B buckets, then the overflow buckets
+	ZeroHash   uint64 // hash of the zero key, to detect a different hash function
+	Hash0      uint32
+	B          uint8
+}
+
+// bucket is the runtime's bmap with the overflow pointer turned into the
+// file offset of the next bucket in the chain, 0 for none. Offsets only
+// ever point forward, which bounds every chain.
+type bucket[K comparable, V any] struct {
+	tophash  [bucketCnt]uint8
+	keys     [bucketCnt]K
+	elems    [bucketCnt]V
+	overflow uint64
+}
+
+// Map is a read-only map backed by a mapped file. It is safe for
+// concurrent use by any number of goroutines.
+type Map[K comparable, V any] struct {
+	hdr    *header
+	data   []byte // the whole file
+	hasher func(key K, seed uintptr) uintptr
+	unmap  func([]byte) error
+}
+
+// hasherFor returns the key hasher of a file map: the runtime's memhash
+// with a fixed key, so that every process computes the same hashes.
+func hasherFor[K comparable]() func(key K, seed uintptr) uintptr {
+	return rtalg.HasherFor[K](rtalg.Alg{})
+}
+
+func zeroHash[K comparable](hasher func(key K, seed uintptr) uintptr, hash0 uint32) uint64 {
+	var zero K
+	return uint64(hasher(zero, uintptr(hash0)))
+}
+
+func checkTypes[K comparable, V any]() error {
+	kt, et := reflect.TypeFor[K](), reflect.TypeFor[V]()
+	if rtalg.HasPointers(kt) || rtalg.HasPointers(et) {
+		return fmt.Errorf("filemap: %v and %v must not contain pointers", kt, et)
+	}
+	return nil
+}
+
+// Open maps the file name, written by Write with the same K and V, and
+// returns the map it holds. The mapping is read-only and shared: the
+// pages are loaded on first access and shared with every other process
+// that has the file open.
+func Open[K comparable, V any](name string) (*Map[K, V], error) {
+	if err := checkTypes[K, V](); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, unmap, err := mmapFile(f)
+	if err != nil {
+		return nil, err
+	}
+	m, err := newMap[K, V](data)
+	if err != nil {
+		unmap(data)
+		return nil, fmt.Errorf("filemap: %s: %w", name, err)
+	}
+	m.unmap = unmap
+	return m, nil
+}
+
+// newMap validates the header of data and wraps it.
+func newMap[K comparable, V any](data []byte) (*Map[K, V], error) {
+	if len(data) < headerSize {
+		return nil, errors.New("not a map file")
+	}
+	hdr := (*header)(unsafe.Pointer(unsafe.SliceData(data)))
+	if string(hdr.Magic[:]) != magic {
+		return nil, errors.New("not a map file")
+	}
+	if hdr.Version != version {
+		return nil, fmt.Errorf("file version %d, want %d", hdr.Version, version)
+	}
+	if hdr.ByteOrder != byteOrderMark {
+		return nil, errors.New("file was written on a machine of different byte order")
+	}
+	var b bucket[K, V]
+	if hdr.KeySize != uint64(unsafe.Sizeof(b.keys[0])) || hdr.ElemSize != uint64(unsafe.Sizeof(b.elems[0])) ||
+		hdr.BucketSize != uint64(unsafe.Sizeof(b)) {
+		return nil, errors.New("key or elem type does not match the file")
+	}
+	hasher := hasherFor[K]()
+	if zeroHash(hasher, hdr.Hash0) != hdr.ZeroHash {
+		return nil, errors.New("file was written with a different hash function")
+	}
+	if hdr.B >= 8*ptrSize-bucketCntBits || hdr.NBuckets < 1<<hdr.B ||
+		hdr.NBuckets > uint64(len(data)-headerSize)/hdr.BucketSize || hdr.Count > hdr.NBuckets*bucketCnt {
+		return nil, errors.New("file is truncated or corrupt")
+	}
+	return &Map[K, V]{hdr: hdr, data: data, hasher: hasher}, nil
+}
+
+// Close unmaps the file. The map must not be used afterwards.
+func (m *Map[K, V]) Close() error {
+	data := m.data
+	m.data, m.hdr = nil, nil
+	if m.unmap == nil {
+		return nil
+	}
+	return m.unmap(data)
+}
+
+// Len returns the number of entries.
+func (m *Map[K, V]) Len() int {
+	return int(m.hdr.Count)
+}
+
+// Buckets returns 1<<B and the number of overflow buckets in the file.
+func (m *Map[K, V]) Buckets() (main, overflow int) {
+	return 1 << m.hdr.B, int(m.hdr.NBuckets) - 1<<m.hdr.B
+}
+
+// bucketAt returns the bucket at file offset off. It panics rather than
+// read outside the file when off is not a bucket of the file.
+func (m *Map[K, V]) bucketAt(off uint64) *bucket[K, V] {
+	size := m.hdr.BucketSize
+	if off < headerSize || (off-headerSize)%size != 0 || (off-headerSize)/size >= m.hdr.NBuckets {
+		panic("filemap: corrupt overflow offset")
+	}
+	return (*bucket[K, V])(unsafe.Pointer(&m.data[off]))
+}
+
+// overflow returns the next bucket in the chain of b at offset off.
+func (m *Map[K, V]) overflow(b *bucket[K, V], off uint64) (*bucket[K, V], uint64) {
+	next := b.overflow
+	if next == 0 {
+		return nil, 0
+	}
+	if next <= off {
+		panic("filemap: corrupt overflow offset")
+	}
+	return m.bucketAt(next), next
+}
+
+func tophash(hash uintptr) uint8 {
+	top := uint8(hash >> (ptrSize*8 - 8))
+	if top < minTopHash {
+		top += minTopHash
+	}
+	return top
+}
+
+// Lookup returns the element for key and whether it is present: the loop
+// of mapaccess2, reading buckets out of the file.
+func (m *Map[K, V]) Lookup(key K) (V, bool) {
+	hdr := m.hdr
+	hash := m.hasher(key, uintptr(hdr.Hash0))
+	off := headerSize + uint64(hash&(1<<hdr.B-1))*hdr.BucketSize
+	top := tophash(hash)
+	for b := m.bucketAt(off); b != nil; b, off = m.overflow(b, off) {
+		for i := range bucketCnt {
+			if b.tophash[i] != top {
+				if b.tophash[i] == emptyRest {
+					var zero V
+					return zero, false
+				}
+				continue
+			}
+			if b.keys[i] == key {
+				return b.elems[i], true
+			}
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+// Get returns the element for key, or the zero value.
+func (m *Map[K, V]) Get(key K) V {
+	v, _ := m.Lookup(key)
+	return v
+}
+
+// Range calls f for each entry, bucket by bucket in file order, until f
+// returns false.
+func (m *Map[K, V]) Range(f func(key K, elem V) bool) {
+	for i := range uint64(1) << m.hdr.B {
+		off := headerSize + i*m.hdr.BucketSize
+		for b := m.bucketAt(off); b != nil; b, off = m.overflow(b, off) {
+			for j := range bucketCnt {
+				if b.tophash[j] == emptyRest {
+					break
+				}
+				if !f(b.keys[j], b.elems[j]) {
+					return
+				}
+			}
+		}
+	}
+}
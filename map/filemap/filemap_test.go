@@ -0,0 +1,205 @@
+package filemap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hmap"
+)
+
+type elem struct {
+	a int64
+	b [3]int32
+}
+
+func source(n int, opts ...hmap.Option) *hmap.Map[int64, elem] {
+	m := hmap.New[int64, elem](0, opts...)
+	for i := range n {
+		k := int64(i) * 7919
+		m.Put(k, elem{k, [3]int32{int32(i), -1, 2}})
+	}
+	return m
+}
+
+// write returns the file Write makes of src.
+func write[K comparable, V any](t *testing.T, src Source[K, V], opts ...Option) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Write(&buf, src, opts...); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// open writes data to a file and opens it.
+func open[K comparable, V any](t *testing.T, data []byte) (*Map[K, V], error) {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "map")
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return Open[K, V](name)
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, n := range []int{0, 1, 8, 9, 13, 100, 1000, 5000} {
+		src := source(n)
+		name := filepath.Join(t.TempDir(), "map")
+		if err := WriteFile(name, src, WithSeed(1)); err != nil {
+			t.Fatal(err)
+		}
+		m, err := Open[int64, elem](name)
+		if err != nil {
+			t.Fatalf("n=%d: %v", n, err)
+		}
+		if m.Len() != n {
+			t.Errorf("n=%d: Len() = %d", n, m.Len())
+		}
+		// B is the one makemap chooses for a hint of n.
+		if main, _ := m.Buckets(); main != 1<<hmap.New[int64, elem](n).Layout().B {
+			t.Errorf("n=%d: %d buckets, want as many as make(map, %d)", n, main, n)
+		}
+		src.Range(func(k int64, want elem) bool {
+			if v, ok := m.Lookup(k); !ok || v != want {
+				t.Errorf("n=%d: Lookup(%d) = %v, %v, want %v", n, k, v, ok, want)
+			}
+			return true
+		})
+		if _, ok := m.Lookup(-1); ok {
+			t.Errorf("n=%d: Lookup(-1) found an entry", n)
+		}
+		seen := make(map[int64]bool)
+		m.Range(func(k int64, v elem) bool {
+			if seen[k] {
+				t.Errorf("n=%d: Range returned %d twice", n, k)
+			}
+			seen[k] = true
+			if want, ok := src.Lookup(k); !ok || v != want {
+				t.Errorf("n=%d: Range returned %d: %v, want %v", n, k, v, want)
+			}
+			return true
+		})
+		if len(seen) != n {
+			t.Errorf("n=%d: Range returned %d entries", n, len(seen))
+		}
+		if err := m.Close(); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// The same entries in the same order with the same seed give the
+	// same file.
+	a, b := source(1000, hmap.WithSeed(3)), source(1000, hmap.WithSeed(3))
+	if !bytes.Equal(write(t, a, WithSeed(2)), write(t, b, WithSeed(2))) {
+		t.Error("two writes with WithSeed(2) differ")
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	data := write(t, source(1000))
+	size := int(unsafe.Sizeof(bucket[int64, elem]{}))
+	for _, n := range []int{0, 7, headerSize - 1, headerSize, len(data) - size, len(data) - 1} {
+		if _, err := open[int64, elem](t, data[:n]); err == nil {
+			t.Errorf("Open succeeded on the first %d of %d bytes", n, len(data))
+		}
+	}
+}
+
+func TestBadHeader(t *testing.T) {
+	data := write(t, source(100))
+	var h header
+	patch := func(off uintptr, v any) []byte {
+		b := bytes.Clone(data)
+		if _, err := binary.Encode(b[off:], binary.NativeEndian, v); err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	for _, tt := range []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"magic", patch(unsafe.Offsetof(h.Magic), [8]byte{'H', 'M', 'A', 'P'}), "not a map file"},
+		{"version", patch(unsafe.Offsetof(h.Version), uint32(version+1)), "file version"},
+		{"byte order", patch(unsafe.Offsetof(h.ByteOrder), uint32(0x04030201)), "byte order"},
+		{"key size", patch(unsafe.Offsetof(h.KeySize), uint64(4)), "does not match"},
+		{"bucket size", patch(unsafe.Offsetof(h.BucketSize), uint64(8)), "does not match"},
+		{"hash0", patch(unsafe.Offsetof(h.Hash0), uint32(1)^binary.NativeEndian.Uint32(data[unsafe.Offsetof(h.Hash0):])), "hash function"},
+		{"B", patch(unsafe.Offsetof(h.B), uint8(8*ptrSize-bucketCntBits)), "corrupt"},
+		{"B beyond NBuckets", patch(unsafe.Offsetof(h.B), uint8(20)), "corrupt"},
+		{"NBuckets", patch(unsafe.Offsetof(h.NBuckets), uint64(1)<<40), "corrupt"},
+		{"count", patch(unsafe.Offsetof(h.Count), uint64(len(data))), "corrupt"},
+	} {
+		_, err := open[int64, elem](t, tt.data)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: Open returned %v, want an error containing %q", tt.name, err, tt.err)
+		}
+	}
+
+	if _, err := open[int32, elem](t, data); err == nil {
+		t.Error("Open with a different key type succeeded")
+	}
+	if _, err := open[int64, *elem](t, data); err == nil {
+		t.Error("Open with a pointer elem succeeded")
+	}
+}
+
+// TestBadOffset checks that a corrupt overflow offset panics rather than
+// reading outside the file or looping.
+func TestBadOffset(t *testing.T) {
+	data := write(t, source(5000))
+	size := uint64(unsafe.Sizeof(bucket[int64, elem]{}))
+	m, err := open[int64, elem](t, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nb, _ := m.Buckets()
+	m.Close()
+
+	// at is the offset of the overflow field of the first main bucket
+	// with an overflow bucket.
+	at := -1
+	for i := range nb {
+		off := headerSize + uint64(i+1)*size - 8
+		if binary.NativeEndian.Uint64(data[off:]) != 0 {
+			at = int(off)
+			break
+		}
+	}
+	if at < 0 {
+		t.Fatal("no overflow buckets in 5000 entries")
+	}
+	for _, tt := range []struct {
+		name string
+		off  uint64
+	}{
+		{"past the end", uint64(len(data))},
+		{"far past the end", 1 << 62},
+		{"inside a bucket", headerSize + uint64(nb)*size + 8},
+		{"in the header", 8},
+		{"backwards", headerSize},
+		{"to itself", uint64(at) + 8 - size},
+	} {
+		b := bytes.Clone(data)
+		binary.NativeEndian.PutUint64(b[at:], tt.off)
+		m, err := open[int64, elem](t, b)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		func() {
+			defer func() {
+				if r := recover(); r == nil || !strings.Contains(r.(string), "corrupt overflow offset") {
+					t.Errorf("%s: Range panicked with %v", tt.name, r)
+				}
+			}()
+			m.Range(func(int64, elem) bool { return true })
+		}()
+		m.Close()
+	}
+}
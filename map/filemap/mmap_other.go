@@ -0,0 +1,18 @@
+//go:build !unix
+
+package filemap
+
+import (
+	"io"
+	"os"
+)
+
+// mmapFile reads all of f where mmap is not available. The map works
+// the same, but each process holds its own copy.
+func mmapFile(f *os.File) ([]byte, func([]byte) error, error) {
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func([]byte) error { return nil }, nil
+}
@@ -0,0 +1,30 @@
+//go:build unix
+
+package filemap
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// mmapFile maps all of f read-only and shared, so that every process
+// mapping the file uses the same page cache pages.
+func mmapFile(f *os.File) ([]byte, func([]byte) error, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	size := fi.Size()
+	if size == 0 {
+		return nil, nil, errors.New("filemap: empty file")
+	}
+	if int64(int(size)) != size {
+		return nil, nil, errors.New("filemap: file too large to map")
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, &os.PathError{Op: "mmap", Path: f.Name(), Err: err}
+	}
+	return data, syscall.Munmap, nil
+}
@@ -0,0 +1,137 @@
+package filemap
+
+import (
+	"errors"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/internal/rtalg"
+)
+
+// Source is what Write needs from the map it copies: hmap.Map,
+// swiss.Map and hmap.OrderedMap all qualify.
+type Source[K comparable, V any] interface {
+	Len() int
+	Range(f func(key K, elem V) bool)
+}
+
+// An Option configures Write.
+type Option func(*config)
+
+type config struct {
+	hash0    uint32
+	hasHash0 bool
+}
+
+// WithSeed derives hash0 from seed instead of choosing it at random, so
+// that writing the same entries in the same order gives the same file.
+func WithSeed(seed uint64) Option {
+	return func(c *config) {
+		c.hash0, c.hasHash0 = rand.New(rand.NewPCG(seed, 0)).Uint32(), true
+	}
+}
+
+// Write lays out the entries of src as a map file on w. B is chosen from
+// src.Len() as makemap chooses it from a size hint, so the table never
+// needs to grow, and each entry is placed by the mapassign loop: the
+// first free cell of its bucket's chain, with a new overflow bucket
+// appended to the file when the chain is full. K and V must not contain
+// pointers, and no key may be NaN.
+func Write[K comparable, V any](w io.Writer, src Source[K, V], opts ...Option) error {
+	if err := checkTypes[K, V](); err != nil {
+		return err
+	}
+	var c config
+	for _, o := range opts {
+		o(&c)
+	}
+	if !c.hasHash0 {
+		c.hash0 = rtalg.Fastrand()
+	}
+	hasher := hasherFor[K]()
+
+	B := uint8(0)
+	for n := src.Len(); n > bucketCnt && uint64(n)*loadFactorDen > loadFactorNum<<B; {
+		B++
+	}
+	buckets := make([]bucket[K, V], 1<<B)
+	size := uint64(unsafe.Sizeof(buckets[0]))
+	count := 0
+	var err error
+	src.Range(func(key K, elem V) bool {
+		if key != key {
+			err = errors.New("filemap: NaN keys cannot be looked up")
+			return false
+		}
+		hash := hasher(key, uintptr(c.hash0))
+		top := tophash(hash)
+		i := uint64(hash & (1<<B - 1))
+		for {
+			b := &buckets[i]
+			for j := range bucketCnt {
+				if b.tophash[j] == emptyRest {
+					b.tophash[j] = top
+					b.keys[j] = key
+					b.elems[j] = elem
+					count++
+					return true
+				}
+				if b.tophash[j] == top && b.keys[j] == key {
+					b.elems[j] = elem
+					return true
+				}
+			}
+			if b.overflow == 0 {
+				b.overflow = headerSize + uint64(len(buckets))*size
+				buckets = append(buckets, bucket[K, V]{})
+			}
+			i = (buckets[i].overflow - headerSize) / size
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	var hdr [headerSize]byte
+	h := (*header)(unsafe.Pointer(&hdr))
+	*h = header{
+		Version:    version,
+		ByteOrder:  byteOrderMark,
+		KeySize:    uint64(unsafe.Sizeof(buckets[0].keys[0])),
+		ElemSize:   uint64(unsafe.Sizeof(buckets[0].elems[0])),
+		BucketSize: size,
+		Count:      uint64(count),
+		NBuckets:   uint64(len(buckets)),
+		ZeroHash:   zeroHash(hasher, c.hash0),
+		Hash0:      c.hash0,
+		B:          B,
+	}
+	copy(h.Magic[:], magic)
+	if _, err := w.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err = w.Write(unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(buckets))), uint64(len(buckets))*size))
+	return err
+}
+
+// WriteFile writes the map file name. It writes a temporary file in the
+// same directory and renames it over name, so processes that have the
+// old file open keep reading the old contents until they reopen it.
+func WriteFile[K comparable, V any](name string, src Source[K, V], opts ...Option) error {
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // fails harmlessly after the rename
+	if err := Write(f, src, opts...); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
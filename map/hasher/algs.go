@@ -0,0 +1,224 @@
+package hasher
+
+import (
+	"encoding/binary"
+	"hash/maphash"
+	"math/bits"
+)
+
+// Maphash is hash/maphash. Its process seed is random, so hashes differ
+// between runs even with the same map seed.
+type Maphash struct {
+	seed maphash.Seed
+}
+
+// NewMaphash returns a Maphash with a fresh random process seed.
+func NewMaphash() *Maphash {
+	return &Maphash{seed: maphash.MakeSeed()}
+}
+
+func (m *Maphash) Hash(b []byte, seed uint64) uint64 {
+	var h maphash.Hash
+	h.SetSeed(m.seed)
+	var s [8]byte
+	binary.LittleEndian.PutUint64(s[:], seed)
+	h.Write(s[:])
+	h.Write(b)
+	return h.Sum64()
+}
+
+// FNV1a is 64-bit FNV-1a. It has no seed of its own; the seed is folded
+// into the offset basis, which does not stop inputs that collide under
+// one seed from colliding under every other.
+type FNV1a struct{}
+
+func (FNV1a) Hash(b []byte, seed uint64) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	h := uint64(offset64) ^ seed
+	for _, c := range b {
+		h ^= uint64(c)
+		h *= prime64
+	}
+	return h
+}
+
+// XXH64 is xxHash64.
+type XXH64 struct{}
+
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}
+
+func xxMerge(acc, val uint64) uint64 {
+	acc ^= xxRound(0, val)
+	return acc*xxPrime1 + xxPrime4
+}
+
+func (XXH64) Hash(b []byte, seed uint64) uint64 {
+	n := len(b)
+	var h uint64
+	if n >= 32 {
+		v1 := seed + xxPrime1 + xxPrime2
+		v2 := seed + xxPrime2
+		v3 := seed
+		v4 := seed - xxPrime1
+		for ; len(b) >= 32; b = b[32:] {
+			v1 = xxRound(v1, binary.LittleEndian.Uint64(b[0:]))
+			v2 = xxRound(v2, binary.LittleEndian.Uint64(b[8:]))
+			v3 = xxRound(v3, binary.LittleEndian.Uint64(b[16:]))
+			v4 = xxRound(v4, binary.LittleEndian.Uint64(b[24:]))
+		}
+		h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) + bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		h = xxMerge(h, v1)
+		h = xxMerge(h, v2)
+		h = xxMerge(h, v3)
+		h = xxMerge(h, v4)
+	} else {
+		h = seed + xxPrime5
+	}
+	h += uint64(n)
+
+	for ; len(b) >= 8; b = b[8:] {
+		h ^= xxRound(0, binary.LittleEndian.Uint64(b))
+		h = bits.RotateLeft64(h, 27)*xxPrime1 + xxPrime4
+	}
+	if len(b) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(b)) * xxPrime1
+		h = bits.RotateLeft64(h, 23)*xxPrime2 + xxPrime3
+		b = b[4:]
+	}
+	for _, c := range b {
+		h ^= uint64(c) * xxPrime5
+		h = bits.RotateLeft64(h, 11) * xxPrime1
+	}
+
+	h ^= h >> 33
+	h *= xxPrime2
+	h ^= h >> 29
+	h *= xxPrime3
+	h ^= h >> 32
+	return h
+}
+
+// Wyhash is wyhash final version 3 with its default secret, the
+// version the runtime's memhash fallback was derived from.
+type Wyhash struct{}
+
+var wySecret = [4]uint64{0xa0761d6478bd642f, 0xe7037ed1a0b428db, 0x8ebc6af09c88c6e3, 0x589965cc75374cc3}
+
+func wyMix(a, b uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	return hi ^ lo
+}
+
+func wyr4(b []byte) uint64 { return uint64(binary.LittleEndian.Uint32(b)) }
+func wyr8(b []byte) uint64 { return binary.LittleEndian.Uint64(b) }
+
+func (Wyhash) Hash(p []byte, seed uint64) uint64 {
+	n := len(p)
+	seed ^= wySecret[0]
+	var a, b uint64
+	switch {
+	case n <= 16:
+		switch {
+		case n >= 4:
+			a = wyr4(p)<<32 | wyr4(p[(n>>3)<<2:])
+			b = wyr4(p[n-4:])<<32 | wyr4(p[n-4-(n>>3)<<2:])
+		case n > 0:
+			a = uint64(p[0])<<16 | uint64(p[n>>1])<<8 | uint64(p[n-1])
+		}
+	default:
+		// The tail reads the last 16 bytes, which may reach back into
+		// data the loops already consumed; o is the loop position.
+		i, o := n, 0
+		if i > 48 {
+			see1, see2 := seed, seed
+			for ; i > 48; i, o = i-48, o+48 {
+				seed = wyMix(wyr8(p[o:])^wySecret[1], wyr8(p[o+8:])^seed)
+				see1 = wyMix(wyr8(p[o+16:])^wySecret[2], wyr8(p[o+24:])^see1)
+				see2 = wyMix(wyr8(p[o+32:])^wySecret[3], wyr8(p[o+40:])^see2)
+			}
+			seed ^= see1 ^ see2
+		}
+		for ; i > 16; i, o = i-16, o+16 {
+			seed = wyMix(wyr8(p[o:])^wySecret[1], wyr8(p[o+8:])^seed)
+		}
+		a = wyr8(p[n-16:])
+		b = wyr8(p[n-8:])
+	}
+	return wyMix(wySecret[1]^uint64(n), wyMix(a^wySecret[1], b^seed))
+}
+
+// SipHash24 is SipHash-2-4 under a 128-bit key. The map seed is mixed
+// into the first key word, so the key is what an attacker has to guess.
+type SipHash24 struct {
+	k0, k1 uint64
+}
+
+// NewSipHash24 returns SipHash-2-4 keyed with k0, k1 (the little-endian
+// halves of the 16-byte key).
+func NewSipHash24(k0, k1 uint64) *SipHash24 {
+	return &SipHash24{k0: k0, k1: k1}
+}
+
+func (s *SipHash24) Hash(b []byte, seed uint64) uint64 {
+	k0, k1 := s.k0^seed, s.k1
+	v0 := k0 ^ 0x736f6d6570736575
+	v1 := k1 ^ 0x646f72616e646f6d
+	v2 := k0 ^ 0x6c7967656e657261
+	v3 := k1 ^ 0x7465646279746573
+
+	round := func() {
+		v0 += v1
+		v1 = bits.RotateLeft64(v1, 13)
+		v1 ^= v0
+		v0 = bits.RotateLeft64(v0, 32)
+		v2 += v3
+		v3 = bits.RotateLeft64(v3, 16)
+		v3 ^= v2
+		v0 += v3
+		v3 = bits.RotateLeft64(v3, 21)
+		v3 ^= v0
+		v2 += v1
+		v1 = bits.RotateLeft64(v1, 17)
+		v1 ^= v2
+		v2 = bits.RotateLeft64(v2, 32)
+	}
+
+	n := len(b)
+	for ; len(b) >= 8; b = b[8:] {
+		m := binary.LittleEndian.Uint64(b)
+		v3 ^= m
+		round()
+		round()
+		v0 ^= m
+	}
+	last := uint64(n) << 56
+	for i, c := range b {
+		last |= uint64(c) << (8 * i)
+	}
+	v3 ^= last
+	round()
+	round()
+	v0 ^= last
+
+	v2 ^= 0xff
+	round()
+	round()
+	round()
+	round()
+	return v0 ^ v1 ^ v2 ^ v3
+}
@@ -0,0 +1,72 @@
+package hasher
+
+import (
+	"fmt"
+	"testing"
+)
+
+// seq returns the bytes 0, 1, ..., n-1, the messages and key of the
+// SipHash reference vectors.
+func seq(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return b
+}
+
+// TestVectors checks the hashers against published reference values,
+// under seed 0, which leaves each algorithm as specified.
+func TestVectors(t *testing.T) {
+	sip := NewSipHash24(0x0706050403020100, 0x0f0e0d0c0b0a0908) // key 00..0f
+	tests := []struct {
+		name string
+		h    Hasher
+		in   []byte
+		want uint64
+	}{
+		// SipHash-2-4 paper, appendix A (vectors_sip64).
+		{"siphash24", sip, seq(0), 0x726fdb47dd0e0e31},
+		{"siphash24", sip, seq(1), 0x74f839c593dc67fd},
+		{"siphash24", sip, seq(8), 0x93f5f5799a932462},
+		{"siphash24", sip, seq(15), 0xa129ca6149be45e5},
+		{"siphash24", sip, seq(63), 0x958a324ceb064572},
+
+		// xxHash64 reference implementation.
+		{"xxh64", XXH64{}, []byte(""), 0xef46db3751d8e999},
+		{"xxh64", XXH64{}, []byte("a"), 0xd24ec4f1a98c6e5b},
+		{"xxh64", XXH64{}, []byte("abc"), 0x44bc2cf5ad770999},
+		{"xxh64", XXH64{}, []byte("Nobody inspects the spammish repetition"), 0xfbcea83c8a378bf1},
+		{"xxh64", XXH64{}, []byte("The quick brown fox jumps over the lazy dog"), 0x0b242d361fda71bc},
+
+		// FNV-1a 64 (draft-eastlake-fnv).
+		{"fnv1a", FNV1a{}, []byte(""), 0xcbf29ce484222325},
+		{"fnv1a", FNV1a{}, []byte("a"), 0xaf63dc4c8601ec8c},
+		{"fnv1a", FNV1a{}, []byte("foobar"), 0x85944171f73967e8},
+	}
+	for _, tt := range tests {
+		if got := tt.h.Hash(tt.in, 0); got != tt.want {
+			t.Errorf("%s(%q) = %#x, want %#x", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestSeed checks that every hasher is deterministic and depends on the
+// seed.
+func TestSeed(t *testing.T) {
+	for _, name := range Names() {
+		h, err := ByName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for n := range 40 {
+			in := []byte(fmt.Sprint("key", n))
+			if h.Hash(in, 1) != h.Hash(in, 1) {
+				t.Errorf("%s(%q) is not deterministic", name, in)
+			}
+			if h.Hash(in, 1) == h.Hash(in, 2) {
+				t.Errorf("%s(%q) is the same under seeds 1 and 2", name, in)
+			}
+		}
+	}
+}
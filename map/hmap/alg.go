@@ -0,0 +1,77 @@
+package hmap
+
+import (
+	"reflect"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/internal/rtalg"
+)
+
+// maptype holds what the runtime keeps in abi.MapType for one map type:
+// the key hasher, the key flags and the bucket size used by makemap and
+// makeBucketArray, plus the bucket geometry the runtime has as constants.
+// (对应 runtime 的 abi.MapType：hasher、key 的标记位、桶大小；另加桶的几何参数)
+type maptype[K comparable] struct {
+	hasher func(key K, seed uintptr) uintptr
+
+	reflexiveKey   bool // t.ReflexiveKey()
+	needKeyUpdate  bool // t.NeedKeyUpdate()
+	hashMightPanic bool // t.HashMightPanic()
+
+	keyPtrs    bool    // t.Key.PtrBytes != 0
+	bucketPtrs bool    // t.Bucket.PtrBytes != 0
+	bucketSize uintptr // t.BucketSize, the size of the runtime's bmap for K/V
+
+	keySize      uintptr // t.Key.Size_
+	elemSize     uintptr // t.Elem.Size_
+	indirectKey  bool    // t.IndirectKey(): store ptr to key instead of key itself
+	indirectElem bool    // t.IndirectElem(): store ptr to elem instead of elem itself
+
+	// The bucketCnt, bucketCntBits, loadFactorNum and loadFactorDen
+	// constants of the runtime, fixed per map by WithBucketCnt and
+	// WithLoadFactor. See geometry.
+	bucketCntBits uint8
+	bucketCnt     uintptr
+	loadFactorNum uintptr
+	loadFactorDen uintptr
+	overflowShift uint8 // makeBucketArray's "b >= 4": preallocate 1<<(b-overflowShift) overflow buckets
+
+	arena *arena // where newarray allocates, if not the GC heap; see WithArena
+}
+
+// newMaptype builds the maptype for K and V. alg supplies the memory
+// hash (nil for memhash) and the random hashes of NaN keys, g the bucket
+// geometry.
+func newMaptype[K comparable, V any](alg rtalg.Alg, g geometry) *maptype[K] {
+	kt, et := reflect.TypeFor[K](), reflect.TypeFor[V]()
+	cnt := uintptr(1) << g.bucketCntBits
+	keyCell, indirectKey := cellSize(kt, g.maxKeySize)
+	elemCell, indirectElem := cellSize(et, g.maxElemSize)
+	return &maptype[K]{
+		hasher:         rtalg.HasherFor[K](alg),
+		reflexiveKey:   rtalg.IsReflexive(kt),
+		needKeyUpdate:  rtalg.NeedKeyUpdate(kt),
+		hashMightPanic: rtalg.HashMightPanic(kt),
+		keyPtrs:        rtalg.HasPointers(kt),
+		bucketPtrs:     rtalg.HasPointers(kt) || rtalg.HasPointers(et) || indirectKey || indirectElem,
+		bucketSize:     bucketSizeOf(keyCell, elemCell, cnt),
+		keySize:        kt.Size(),
+		elemSize:       et.Size(),
+		indirectKey:    indirectKey,
+		indirectElem:   indirectElem,
+		bucketCntBits:  g.bucketCntBits,
+		bucketCnt:      cnt,
+		loadFactorNum:  g.loadFactorNum,
+		loadFactorDen:  g.loadFactorDen,
+		overflowShift:  g.overflowShift(),
+	}
+}
+
+// bucketSizeOf computes the size reflect.MapOf would give the bucket type
+// with cnt cells of keySize and elemSize bytes: tophash, cnt keys, cnt
+// elems and the overflow pointer. The keys start at the first word
+// boundary after tophash, which for 8 cells is right behind it.
+func bucketSizeOf(keySize, elemSize, cnt uintptr) uintptr {
+	dataOffset := (cnt + ptrSize - 1) &^ (ptrSize - 1)
+	size := dataOffset + cnt*keySize + cnt*elemSize + ptrSize
+	return (size + ptrSize - 1) &^ (ptrSize - 1)
+}
@@ -0,0 +1,158 @@
+package hmap
+
+import (
+	"github.com/ProsperousLi/golang-deep-learn/map/internal/mapopt"
+	"github.com/ProsperousLi/golang-deep-learn/map/internal/rtalg"
+	"github.com/ProsperousLi/golang-deep-learn/map/mapapi"
+)
+
+// Map is a hash map with the same bucket layout and growth behaviour as
+// the builtin map. The zero Map is not usable; create one with New.
+// Like the builtin map, a Map must not be written concurrently with any
+// other access; doing so panics with "concurrent map writes" when detected.
+type Map[K comparable, V any] struct {
+	t *maptype[K]
+	h *hmap[K, V]
+}
+
+// New returns an empty map with room for about hint elements,
+// like make(map[K]V, hint), configured by opts.
+func New[K comparable, V any](hint int, opts ...Option) *Map[K, V] {
+	c := newConfig(opts)
+	h := &hmap[K, V]{rand: c.rand, shrink: c.shrink}
+	t := newMaptype[K, V](rtalg.Alg{Mem: mapopt.Memhash(c.hasher), Rand: h.fastrand}, c.geometry())
+	if c.arena {
+		checkArenaTypes[K, V]()
+		t.arena = newArena(c.slabSize)
+	}
+	makemap(t, hint, h)
+	h.minB = h.B
+	if c.tracer != nil {
+		h.trace = &tracer{t: c.tracer}
+	}
+	if c.probe != nil {
+		h.probe = &prober{p: c.probe}
+	}
+	return &Map[K, V]{t: t, h: h}
+}
+
+// Len returns the number of elements, like len(m).
+func (m *Map[K, V]) Len() int {
+	return m.h.count
+}
+
+// Growing reports whether an incremental grow (or shrink, see WithShrink)
+// is in progress, that is, whether oldbuckets still holds buckets waiting
+// to be evacuated.
+func (m *Map[K, V]) Growing() bool {
+	return m.h.growing()
+}
+
+// Get returns the element for key, or the zero value, like m[key].
+func (m *Map[K, V]) Get(key K) V {
+	if e := mapaccess1(m.t, m.h, key); e != nil {
+		return *e
+	}
+	var zero V
+	return zero
+}
+
+// Lookup is the comma-ok form of Get, like v, ok := m[key].
+func (m *Map[K, V]) Lookup(key K) (V, bool) {
+	if e, ok := mapaccess2(m.t, m.h, key); ok {
+		return *e, true
+	}
+	var zero V
+	return zero, false
+}
+
+// Put sets the element for key, like m[key] = elem.
+func (m *Map[K, V]) Put(key K, elem V) {
+	*mapassign(m.t, m.h, key) = elem
+	debugCheck(m.t, m.h)
+}
+
+// Delete removes key, like delete(m, key).
+func (m *Map[K, V]) Delete(key K) {
+	mapdelete(m.t, m.h, key)
+	debugCheck(m.t, m.h)
+}
+
+// Clear removes every element but keeps the bucket array, like clear(m).
+func (m *Map[K, V]) Clear() {
+	mapclear(m.t, m.h)
+	debugCheck(m.t, m.h)
+}
+
+// Clone returns a copy of m, like maps.Clone.
+func (m *Map[K, V]) Clone() *Map[K, V] {
+	t := m.t
+	if t.arena != nil {
+		nt := *t
+		nt.arena = newArena(int(t.arena.slabSize))
+		t = &nt
+	}
+	c := &Map[K, V]{t: t, h: mapclone2(t, m.h)}
+	debugCheck(c.t, c.h)
+	return c
+}
+
+// AppendKeys appends the keys of m to s in map order and returns the
+// extended slice. It is the runtime's keys(), which backs maps.Keys.
+func (m *Map[K, V]) AppendKeys(s []K) []K {
+	return keys(m.t, m.h, s)
+}
+
+// AppendValues appends the elements of m to s in map order and returns
+// the extended slice. It is the runtime's values(), which backs maps.Values.
+func (m *Map[K, V]) AppendValues(s []V) []V {
+	return values(m.t, m.h, s)
+}
+
+// Range calls f for each key and element, in the randomized order of a
+// range loop, until f returns false. As with range over a builtin map,
+// f may insert and delete entries.
+func (m *Map[K, V]) Range(f func(key K, elem V) bool) {
+	var it hiter[K, V]
+	for mapiterinit(m.t, m.h, &it); it.key != nil; mapiternext(&it) {
+		if !f(*it.key, *it.elem) {
+			return
+		}
+	}
+}
+
+// Iter is a map iterator, the exported form of the runtime's hiter.
+type Iter[K comparable, V any] struct {
+	m       *Map[K, V]
+	it      hiter[K, V]
+	started bool
+}
+
+// Iter returns an iterator positioned before the first entry.
+func (m *Map[K, V]) Iter() *Iter[K, V] {
+	return &Iter[K, V]{m: m}
+}
+
+// Next advances the iterator (mapiterinit on the first call, mapiternext
+// afterwards) and reports whether an entry is available.
+func (it *Iter[K, V]) Next() bool {
+	if !it.started {
+		it.started = true
+		mapiterinit(it.m.t, it.m.h, &it.it)
+	} else if it.it.key != nil {
+		mapiternext(&it.it)
+	}
+	return it.it.key != nil
+}
+
+// Key returns the key of the current entry.
+func (it *Iter[K, V]) Key() K {
+	return *it.it.key
+}
+
+// Elem returns the element of the current entry.
+func (it *Iter[K, V]) Elem() V {
+	return *it.it.elem
+}
+
+var _ mapapi.Map[int, int] = (*Map[int, int])(nil)
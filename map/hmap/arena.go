@@ -0,0 +1,190 @@
+package hmap
+
+import (
+	"reflect"
+	"unsafe"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/internal/rtalg"
+)
+
+// The runtime keeps mapextra.overflow so that a map whose keys and elems
+// hold no pointers can have a bucket type without pointers: the GC then
+// never scans the buckets, however large the map. Buckets in this port
+// are Go structs of slices, which the GC scans like any other. With
+// WithArena the bucket arrays, the overflow buckets and the cells behind
+// them are instead carved out of large []byte slabs. The GC sees a slab
+// as a pointer-free object, so it marks the slab and skips its contents;
+// the pointers stored in it (bucket slices, overflow links) only ever
+// point into slabs the arena keeps alive, or into the bucket array of
+// an iterator, which holds that array itself.
+/*
+	runtime 为 key/elem 都不含指针的 map 保留 mapextra.overflow，就是为了让桶类型不含指针、GC 完全不扫描桶。
+	本移植里的桶是由切片组成的 Go 结构体，GC 总要扫描。WithArena 把桶数组、溢出桶以及 tophash/key/elem
+	都从大块 []byte（slab）里切出来：GC 只把 slab 当作一个无指针对象标记，不看里面的内容。
+	slab 里保存的指针（桶的切片、overflow 链接）只会指向 arena 自己持有的 slab，所以不会被提前回收。
+	小的分配（溢出桶、小桶数组）从共享 slab 顺序分配，大于 slab 的桶数组单独占一个 slab，
+	扩容完成释放 oldbuckets 时可以立即交还给 GC；Map.Free 一次性交还全部内存。
+*/
+type arena struct {
+	slabSize uintptr
+	cur      []byte                    // unused tail of the current shared slab
+	shared   [][]byte                  // slabs that small allocations are carved from
+	own      map[unsafe.Pointer][]byte // slabs holding one bucket array each, by address
+	bytes    uintptr                   // total size of the slabs
+}
+
+// defaultSlabSize is the slab size of WithArena(0).
+const defaultSlabSize = 1 << 20
+
+func newArena(slabSize int) *arena {
+	if slabSize <= 0 {
+		slabSize = defaultSlabSize
+	}
+	return &arena{slabSize: uintptr(slabSize), own: map[unsafe.Pointer][]byte{}}
+}
+
+// alloc returns size zeroed bytes aligned to ptrSize. Requests of half a
+// slab or more get a slab of their own, which release can give back.
+func (a *arena) alloc(size uintptr) unsafe.Pointer {
+	size = (size + ptrSize - 1) &^ (ptrSize - 1)
+	if size == 0 {
+		size = ptrSize
+	}
+	if size >= a.slabSize/2 {
+		slab := make([]byte, size)
+		p := unsafe.Pointer(unsafe.SliceData(slab))
+		a.own[p] = slab
+		a.bytes += size
+		return p
+	}
+	if uintptr(len(a.cur)) < size {
+		a.cur = make([]byte, a.slabSize)
+		a.shared = append(a.shared, a.cur)
+		a.bytes += a.slabSize
+	}
+	p := unsafe.Pointer(unsafe.SliceData(a.cur))
+	a.cur = a.cur[size:]
+	return p
+}
+
+// release gives up the bucket array starting at p if it has a slab of
+// its own. Arrays carved from shared slabs are only freed by Free.
+func (a *arena) release(p unsafe.Pointer) {
+	if slab, ok := a.own[p]; ok {
+		delete(a.own, p)
+		a.bytes -= uintptr(len(slab))
+	}
+}
+
+// arenaArray is newarray for a map with an arena: the buckets, then
+// tophash, keys and elems (or their pointers), in one allocation.
+func arenaArray[K comparable, V any](t *maptype[K], n uintptr) []bmap[K, V] {
+	cells := n * t.bucketCnt
+	var (
+		b     bmap[K, V]
+		k     K
+		e     V
+		sizes = [...]uintptr{
+			n * unsafe.Sizeof(b),
+			cells,
+			cells * unsafe.Sizeof(k),
+			cells * unsafe.Sizeof(e),
+			0, 0,
+		}
+	)
+	if t.indirectKey {
+		sizes[2], sizes[4] = 0, cells*ptrSize
+	}
+	if t.indirectElem {
+		sizes[3], sizes[5] = 0, cells*ptrSize
+	}
+	var offs [len(sizes)]uintptr
+	total := uintptr(0)
+	for i, s := range sizes {
+		offs[i] = total
+		total += (s + ptrSize - 1) &^ (ptrSize - 1)
+	}
+	p := t.arena.alloc(total)
+	at := func(i int) unsafe.Pointer { return unsafe.Add(p, offs[i]) }
+
+	buckets := unsafe.Slice((*bmap[K, V])(p), n)
+	tophash := unsafe.Slice((*uint8)(at(1)), cells)
+	for i := range buckets {
+		lo, hi := uintptr(i)*t.bucketCnt, uintptr(i+1)*t.bucketCnt
+		b := &buckets[i]
+		b.tophash = tophash[lo:hi:hi]
+		if t.indirectKey {
+			b.ikeys = unsafe.Slice((**K)(at(4)), cells)[lo:hi:hi]
+		} else {
+			b.keys = unsafe.Slice((*K)(at(2)), cells)[lo:hi:hi]
+		}
+		if t.indirectElem {
+			b.ielems = unsafe.Slice((**V)(at(5)), cells)[lo:hi:hi]
+		} else {
+			b.elems = unsafe.Slice((*V)(at(3)), cells)[lo:hi:hi]
+		}
+	}
+	return buckets
+}
+
+// releaseArray hands a bucket array that the map no longer uses back to
+// the arena, if the map has one.
+func releaseArray[K comparable, V any](t *maptype[K], array []bmap[K, V]) {
+	if t.arena != nil && len(array) > 0 {
+		t.arena.release(unsafe.Pointer(&array[0]))
+	}
+}
+
+// checkArenaTypes panics unless K and V can live in an arena: the GC
+// would not see pointers stored in the slabs.
+func checkArenaTypes[K comparable, V any]() {
+	if rtalg.HasPointers(reflect.TypeFor[K]()) || rtalg.HasPointers(reflect.TypeFor[V]()) {
+		panic(plainError("hmap: WithArena needs key and elem types without pointers"))
+	}
+}
+
+// ArenaStats describes the arena of a map created with WithArena.
+type ArenaStats struct {
+	SharedSlabs int     // slabs holding overflow buckets and small arrays
+	OwnSlabs    int     // slabs holding one large bucket array each
+	Bytes       uintptr // total size of the slabs
+}
+
+// ArenaStats returns the state of m's arena, or the zero ArenaStats if m
+// was created without WithArena.
+func (m *Map[K, V]) ArenaStats() ArenaStats {
+	a := m.t.arena
+	if a == nil {
+		return ArenaStats{}
+	}
+	return ArenaStats{SharedSlabs: len(a.shared), OwnSlabs: len(a.own), Bytes: a.bytes}
+}
+
+// Free empties m and gives every slab of its arena back to the GC at
+// once, where Clear would keep the bucket array. m remains usable and
+// allocates new slabs as it grows again. Free panics if m was created
+// without WithArena. Iterators over m must not be used after Free.
+func (m *Map[K, V]) Free() {
+	t, h := m.t, m.h
+	if t.arena == nil {
+		panic(plainError("hmap: Free of a map without an arena"))
+	}
+	if h.flags&hashWriting != 0 {
+		fatal("concurrent map writes")
+	}
+	*t.arena = *newArena(int(t.arena.slabSize))
+	h.buckets, h.oldbuckets, h.extra = nil, nil, nil
+	h.flags &^= sameSizeGrow | shrinking
+	h.count, h.nevacuate, h.noverflow = 0, 0, 0
+	h.B = h.minB
+	h.hash0 = h.fastrand()
+	if h.B != 0 {
+		var nextOverflow *bmap[K, V]
+		h.buckets, nextOverflow = makeBucketArray[K, V](t, h.B, nil)
+		h.countArray(t, len(h.buckets))
+		if nextOverflow != nil {
+			h.extra = &mapextra[K, V]{nextOverflow: nextOverflow}
+		}
+	}
+	debugCheck(t, h)
+}
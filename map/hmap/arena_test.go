@@ -0,0 +1,144 @@
+package hmap
+
+import (
+	"math/rand/v2"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// churnGC forces a collection and then allocates and scribbles over
+// memory, so that anything the GC freed while a slab still pointed to it
+// is likely to be reused and show up as a wrong entry.
+func churnGC() {
+	runtime.GC()
+	junk := make([][]byte, 64)
+	for i := range junk {
+		junk[i] = make([]byte, 16<<10)
+		for j := range junk[i] {
+			junk[i][j] = 0xa5
+		}
+	}
+	runtime.KeepAlive(junk)
+}
+
+type arenaElem [3]int64
+
+// checkArenaMap compares m with model entry by entry.
+func checkArenaMap(t *testing.T, step int, m *Map[int, arenaElem], model map[int]arenaElem) {
+	t.Helper()
+	if m.Len() != len(model) {
+		t.Fatalf("step %d: Len() = %d, builtin len %d", step, m.Len(), len(model))
+	}
+	n := 0
+	m.Range(func(k int, v arenaElem) bool {
+		n++
+		if want, ok := model[k]; !ok || v != want {
+			t.Fatalf("step %d: range produced %d: %v, builtin %v, %v", step, k, v, want, ok)
+		}
+		return true
+	})
+	if n != len(model) {
+		t.Fatalf("step %d: range produced %d entries, builtin len %d", step, n, len(model))
+	}
+	for k, want := range model {
+		if v, ok := m.Lookup(k); !ok || v != want {
+			t.Fatalf("step %d: m[%d] = %v, %v; builtin %v", step, k, v, ok, want)
+		}
+	}
+	if err := m.Validate(); err != nil {
+		t.Fatalf("step %d: %v", step, err)
+	}
+}
+
+// TestArena grows and shrinks maps whose buckets live in an arena,
+// clones one mid-growth and frees it, with a forced GC between the
+// steps, and compares them with a builtin map throughout.
+func TestArena(t *testing.T) {
+	configs := []struct {
+		name string
+		opts []Option
+	}{
+		{"default", []Option{WithArena(0)}},
+		// Small slabs give the large bucket arrays slabs of their own,
+		// which go back to the GC when a growth ends.
+		{"small slabs", []Option{WithArena(4096)}},
+		{"indirect", []Option{WithArena(4096), WithIndirect(0, 0)}},
+	}
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			r := rand.New(rand.NewPCG(1, 2))
+			m := New[int, arenaElem](0, append(c.opts, WithShrink(0.25), WithSeed(3))...)
+			model := make(map[int]arenaElem)
+			elem := func(i int) arenaElem { return arenaElem{int64(i), -int64(i), 7} }
+			var clone *Map[int, arenaElem]
+			var cmodel map[int]arenaElem
+			top := uint8(0)
+			for i := range 12000 {
+				k := r.IntN(3000)
+				// Fill for the first half, then mostly delete so that
+				// the map shrinks.
+				if i < 6000 && r.IntN(4) != 0 || i >= 6000 && r.IntN(10) == 0 {
+					m.Put(k, elem(i))
+					model[k] = elem(i)
+				} else {
+					m.Delete(k)
+					delete(model, k)
+				}
+				if clone == nil && i > 1000 && m.Growing() {
+					clone, cmodel = m.Clone(), make(map[int]arenaElem)
+					for k, v := range model {
+						cmodel[k] = v
+					}
+					clone.Put(-1, elem(-1))
+					cmodel[-1] = elem(-1)
+				}
+				top = max(top, m.h.B)
+				if i%1000 == 0 {
+					churnGC()
+					checkArenaMap(t, i, m, model)
+				}
+			}
+			if m.ArenaStats().Bytes == 0 || m.h.B >= top {
+				t.Errorf("B=%d after growing to %d; %+v", m.h.B, top, m.ArenaStats())
+			}
+			churnGC()
+			checkArenaMap(t, -1, m, model)
+			checkArenaMap(t, -1, clone, cmodel)
+
+			m.Free()
+			churnGC()
+			if m.Len() != 0 {
+				t.Fatalf("Len() = %d after Free", m.Len())
+			}
+			clear(model)
+			for i := range 2000 {
+				m.Put(i, elem(i))
+				model[i] = elem(i)
+			}
+			churnGC()
+			checkArenaMap(t, -2, m, model)
+			checkArenaMap(t, -2, clone, cmodel)
+		})
+	}
+}
+
+// TestArenaPointers checks that WithArena refuses types with pointers,
+// and OrderedMap whatever its types.
+func TestArenaPointers(t *testing.T) {
+	for name, f := range map[string]func(){
+		"pointer elem": func() { New[int, *int](0, WithArena(0)) },
+		"string key":   func() { New[string, int](0, WithArena(0)) },
+		"ordered":      func() { NewOrdered[int, int](0, WithArena(0)) },
+	} {
+		func() {
+			defer func() {
+				err, _ := recover().(error)
+				if err == nil || !strings.Contains(err.Error(), "WithArena") {
+					t.Errorf("%s: recovered %v, want a WithArena error", name, err)
+				}
+			}()
+			f()
+		}()
+	}
+}
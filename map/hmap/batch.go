@@ -0,0 +1,225 @@
+package hmap
+
+import "iter"
+
+// Building a map with a loop of Put pays, for every key, the checks of
+// mapassign (hashWriting, growing, overLoadFactor), a share of each grow
+// it triggers on the way, and a cache miss on a random bucket. The batch
+// path does the same work in bulk: it sizes the table for the final count
+// up front, so the map grows at most once and in a single step; it hashes
+// all keys in one loop; and it sorts the keys by bucket (a stable
+// counting sort) so that the buckets are filled in address order.
+/*
+	批量插入：逐个 Put 时每个 key 都要经过 mapassign 的各项检查、分摊途中每次扩容的搬迁，
+	并随机访问一个桶。批量路径先按最终数量一次性算出 B（最多扩容一次，而且一步到位），
+	在一个紧凑的循环里算完所有 hash，再用稳定的计数排序按桶号排好，按地址顺序逐桶放入。
+	同一批里重复的 key 与逐个 Put 一样，后出现的覆盖先出现的。
+*/
+
+// InsertBatch sets m[keys[i]] = elems[i] for each i, with the result of
+// a loop of Put: a key repeated in the batch ends up with its last elem.
+// It panics if keys and elems differ in length.
+func (m *Map[K, V]) InsertBatch(keys []K, elems []V) {
+	if len(keys) != len(elems) {
+		panic(plainError("hmap: InsertBatch with keys and elems of different lengths"))
+	}
+	mapassignBatch(m.t, m.h, keys, elems)
+	debugCheck(m.t, m.h)
+}
+
+// FromSlice returns a map holding keys[i] -> elems[i], built by
+// InsertBatch into a map created with New(len(keys), opts...).
+func FromSlice[K comparable, V any](keys []K, elems []V, opts ...Option) *Map[K, V] {
+	m := New[K, V](len(keys), opts...)
+	m.InsertBatch(keys, elems)
+	return m
+}
+
+// FromSeq returns a map holding the pairs of seq. The pairs are collected
+// first, so that the table can be sized for all of them; see FromSlice.
+func FromSeq[K comparable, V any](seq iter.Seq2[K, V], opts ...Option) *Map[K, V] {
+	var keys []K
+	var elems []V
+	for k, v := range seq {
+		keys = append(keys, k)
+		elems = append(elems, v)
+	}
+	return FromSlice(keys, elems, opts...)
+}
+
+func mapassignBatch[K comparable, V any](t *maptype[K], h *hmap[K, V], keys []K, elems []V) {
+	if len(keys) == 0 {
+		return
+	}
+	if h.flags&hashWriting != 0 {
+		fatal("concurrent map writes")
+	}
+	// Hash before setting hashWriting, as mapassign does: t.hasher may
+	// panic. hash0 only changes when the map is cleared or emptied.
+	hashes := make([]uintptr, len(keys))
+	for i := range keys {
+		hashes[i] = t.hasher(keys[i], uintptr(h.hash0))
+	}
+
+	h.flags ^= hashWriting
+	if h.trace != nil {
+		h.trace.begin("mapassignBatch")
+	}
+
+	// Finish a grow in progress, then make room for every key being new.
+	for h.growing() {
+		evacuate(t, h, h.nevacuate)
+	}
+	B := h.B
+	for overLoadFactor(t, h.count+len(keys), B) {
+		B++
+	}
+	if h.buckets == nil {
+		h.buckets = newarray[K, V](t, 1)
+		h.countArray(t, 1)
+	}
+	if B > h.B {
+		resizeTo(t, h, B)
+	}
+
+	// Counting sort of the batch by bucket; start[b] is where bucket b's
+	// entries begin in order, and serves as the cursor while filling.
+	mask := bucketMask(h.B)
+	start := make([]int, bucketShift(h.B)+1)
+	for _, hash := range hashes {
+		start[hash&mask+1]++
+	}
+	for b := 1; b < len(start); b++ {
+		start[b] += start[b-1]
+	}
+	order := make([]int, len(keys))
+	for i, hash := range hashes {
+		b := hash & mask
+		order[start[b]] = i
+		start[b]++
+	}
+	for _, i := range order {
+		*mapassignHashed(t, h, keys[i], hashes[i]) = elems[i]
+	}
+
+	if h.flags&hashWriting == 0 {
+		fatal("concurrent map writes")
+	}
+	h.flags &^= hashWriting
+}
+
+// mapassignHashed is the lookup-or-insert loop of mapassign for a key
+// whose hash is known, in a map the caller has already sized: it never
+// starts a grow.
+func mapassignHashed[K comparable, V any](t *maptype[K], h *hmap[K, V], key K, hash uintptr) *V {
+	b := &h.buckets[hash&bucketMask(h.B)]
+	top := tophash(hash)
+
+	var insertb *bmap[K, V]
+	var inserti uintptr
+bucketloop:
+	for {
+		for i := uintptr(0); i < t.bucketCnt; i++ {
+			if b.tophash[i] != top {
+				if isEmpty(b.tophash[i]) && insertb == nil {
+					insertb, inserti = b, i
+				}
+				if b.tophash[i] == emptyRest {
+					break bucketloop
+				}
+				continue
+			}
+			k := b.key(i)
+			if key != *k {
+				continue
+			}
+			if t.needKeyUpdate {
+				*k = key
+			}
+			return b.elem(i)
+		}
+		ovf := b.overflow
+		if ovf == nil {
+			break
+		}
+		b = ovf
+	}
+
+	if insertb == nil {
+		insertb, inserti = h.newoverflow(t, b), 0
+	}
+	if t.indirectKey {
+		insertb.ikeys[inserti] = h.newKey(t)
+	}
+	if t.indirectElem {
+		insertb.ielems[inserti] = h.newElem(t)
+	}
+	*insertb.key(inserti) = key
+	insertb.tophash[inserti] = top
+	h.count++
+	return insertb.elem(inserti)
+}
+
+// resizeTo replaces the bucket array of h, which must not be growing,
+// with one of 1<<B buckets, moving every entry at once instead of through
+// oldbuckets and evacuate. The moved cells of the old array are marked
+// evacuated, so that an iterator still walking it looks each key up in
+// the new array, as it does after an ordinary grow.
+func resizeTo[K comparable, V any](t *maptype[K], h *hmap[K, V], B uint8) {
+	oldB, old := h.B, h.buckets
+	noverflow := h.noverflow
+	newbuckets, nextOverflow := makeBucketArray[K, V](t, B, nil)
+	h.countArray(t, len(newbuckets))
+
+	flags := h.flags &^ (iterator | oldIterator)
+	if h.flags&iterator != 0 {
+		flags |= oldIterator
+	}
+	h.flags = flags
+	h.B = B
+	h.buckets = newbuckets
+	h.nevacuate = 0
+	h.noverflow = 0
+	if h.extra == nil {
+		h.extra = new(mapextra[K, V])
+	}
+	h.extra.nextOverflow = nextOverflow
+	if h.trace != nil {
+		h.trace.growSeq = h.trace.seq
+		h.trace.emit(&GrowStart{OldB: oldB, B: B, Count: h.count, NOverflow: noverflow})
+	}
+
+	mask := bucketMask(B)
+	for i := range bucketShift(oldB) {
+		for b := &old[i]; b != nil; b = b.overflow {
+			for j := uintptr(0); j < t.bucketCnt; j++ {
+				top := b.tophash[j]
+				if isEmpty(top) {
+					b.tophash[j] = evacuatedEmpty
+					continue
+				}
+				k := b.key(j)
+				hash := t.hasher(*k, uintptr(h.hash0)) // random for NaNs, which keep their tophash
+				dst := &newbuckets[hash&mask]
+				for dst.tophash[t.bucketCnt-1] != emptyRest {
+					if dst.overflow == nil {
+						dst = h.newoverflow(t, dst)
+						break
+					}
+					dst = dst.overflow
+				}
+				di := uintptr(0)
+				for dst.tophash[di] != emptyRest {
+					di++
+				}
+				dst.tophash[di] = top
+				dst.moveCell(di, b, j)
+				b.tophash[j] = evacuatedX
+			}
+		}
+	}
+	releaseArray(t, old)
+	if h.trace != nil {
+		h.trace.emit(&Release{B: B, Writes: 1})
+	}
+}
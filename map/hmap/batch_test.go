@@ -0,0 +1,121 @@
+package hmap
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// batchKeys returns n keys drawn from [0, span), with repeats, a few NaNs
+// and both zeros, and their elems.
+func batchKeys(r *rand.Rand, n, span int) ([]float64, []int) {
+	keys := make([]float64, n)
+	elems := make([]int, n)
+	for i := range keys {
+		switch x := r.IntN(100); {
+		case x < 2:
+			keys[i] = math.NaN()
+		case x < 4:
+			keys[i] = math.Copysign(0, -1)
+		case x < 6:
+			keys[i] = 0
+		default:
+			keys[i] = float64(r.IntN(span))
+		}
+		elems[i] = r.Int()
+	}
+	return keys, elems
+}
+
+// checkBatch checks that m holds what model holds, -0 and +0 keys and
+// NaNs included.
+func checkBatch(t *testing.T, name string, m *Map[float64, int], model map[float64]int) {
+	t.Helper()
+	got, want := entries(m), builtinEntries(model)
+	if m.Len() != len(model) || len(got) != len(want) {
+		t.Fatalf("%s: %d entries, %d distinct; builtin %d, %d", name, m.Len(), len(got), len(model), len(want))
+	}
+	for e, n := range want {
+		if got[e] != n {
+			t.Fatalf("%s: %v: %d %d times, builtin %d", name, math.Float64frombits(e[0]), e[1], got[e], n)
+		}
+	}
+	if err := m.Validate(); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+}
+
+// TestInsertBatch compares InsertBatch with a loop of assignments to a
+// builtin map, into empty maps, maps in the middle of a growth and of a
+// shrink, and maps that already hold some of the keys.
+func TestInsertBatch(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	setups := []struct {
+		name  string
+		setup func(m *Map[float64, int], model map[float64]int)
+	}{
+		{"empty", func(m *Map[float64, int], model map[float64]int) {}},
+		{"growing", func(m *Map[float64, int], model map[float64]int) {
+			for i := 0; !m.Growing(); i++ {
+				m.Put(float64(i), i)
+				model[float64(i)] = i
+			}
+		}},
+		{"shrinking", func(m *Map[float64, int], model map[float64]int) {
+			for i := range 2000 {
+				m.Put(float64(i), i)
+				model[float64(i)] = i
+			}
+			for i := 0; !m.Growing(); i++ {
+				m.Delete(float64(i))
+				delete(model, float64(i))
+			}
+			if !m.h.shrinking() {
+				t.Fatal("deletes started a growth that is not a shrink")
+			}
+		}},
+	}
+	for _, s := range setups {
+		for _, n := range []int{1, 7, 100, 3000} {
+			m := New[float64, int](0, WithShrink(0.25))
+			model := make(map[float64]int)
+			s.setup(m, model)
+			keys, elems := batchKeys(r, n, 2*n)
+			m.InsertBatch(keys, elems)
+			for i, k := range keys {
+				model[k] = elems[i]
+			}
+			checkBatch(t, s.name, m, model)
+			if m.Growing() {
+				t.Errorf("%s, %d keys: growing after InsertBatch", s.name, n)
+			}
+		}
+	}
+}
+
+// TestFromSlice checks FromSlice and FromSeq against the builtin map,
+// with repeated keys, NaNs and both zeros in the input.
+func TestFromSlice(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	keys, elems := batchKeys(r, 5000, 3000)
+	model := make(map[float64]int)
+	for i, k := range keys {
+		model[k] = elems[i]
+	}
+	m := FromSlice(keys, elems)
+	checkBatch(t, "FromSlice", m, model)
+
+	s := FromSeq(func(yield func(float64, int) bool) {
+		for i, k := range keys {
+			if !yield(k, elems[i]) {
+				return
+			}
+		}
+	})
+	checkBatch(t, "FromSeq", s, model)
+
+	// The map was sized for every key, so it never grew.
+	if m.MemStats().ArrayAllocs != 1 {
+		t.Errorf("FromSlice allocated %d bucket arrays", m.MemStats().ArrayAllocs)
+	}
+}
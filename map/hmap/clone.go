@@ -0,0 +1,249 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hmap
+
+// moveToBmap moves a bucket from src to dst. It returns the destination bucket or new destination bucket if it overflows
+// and the pos that the next key/value will be written, if pos == t.bucketCnt means needs to written in overflow bucket.
+func moveToBmap[K comparable, V any](t *maptype[K], h *hmap[K, V], dst *bmap[K, V], pos int, src *bmap[K, V]) (*bmap[K, V], int) {
+	n := int(t.bucketCnt)
+	for i := 0; i < n; i++ {
+		if isEmpty(src.tophash[i]) {
+			continue
+		}
+
+		for ; pos < n; pos++ {
+			if isEmpty(dst.tophash[pos]) {
+				break
+			}
+		}
+
+		if pos == n {
+			dst = h.newoverflow(t, dst)
+			pos = 0
+		}
+
+		dst.tophash[pos] = src.tophash[i]
+		// Indirect keys and elems get their own copy: sharing the
+		// pointer would let a write to the clone show in src.
+		if t.indirectKey {
+			dst.ikeys[pos] = h.newKey(t)
+			*dst.ikeys[pos] = *src.ikeys[i]
+		} else {
+			dst.keys[pos] = src.keys[i]
+		}
+		if t.indirectElem {
+			dst.ielems[pos] = h.newElem(t)
+			*dst.ielems[pos] = *src.ielems[i]
+		} else {
+			dst.elems[pos] = src.elems[i]
+		}
+		pos++
+		h.count++
+	}
+	return dst, pos
+}
+
+func mapclone2[K comparable, V any](t *maptype[K], src *hmap[K, V]) *hmap[K, V] {
+	// The clone shares the random source of src, if any, so that cloning a
+	// deterministic map gives a deterministic map.
+	// The copy below needs dst.B <= src.B. A map in the middle of a
+	// shrink, or with a low load factor, can hold more than its load
+	// factor allows until the growth is done, so cap the hint there.
+	hint := src.count
+	if overLoadFactor(t, hint, src.B) {
+		hint = int(t.loadFactorNum * bucketShift(src.B) / t.loadFactorDen)
+	}
+	dst := makemap[K, V](t, hint, &hmap[K, V]{rand: src.rand, shrink: src.shrink, minB: src.minB})
+	dst.hash0 = src.hash0
+	dst.nevacuate = 0
+	//flags do not need to be copied here, just like a new map has no flags.
+
+	if src.count == 0 {
+		return dst
+	}
+
+	if src.flags&hashWriting != 0 {
+		fatal("concurrent map clone and map write")
+	}
+
+	if src.B == 0 {
+		// The runtime copies the whole bucket with typedmemmove, overflow
+		// pointer included. Copying the chain keeps the clone independent.
+		dst.buckets = newarray[K, V](t, 1)
+		dst.countArray(t, 1)
+		dstBmap, pos := &dst.buckets[0], 0
+		for b := &src.buckets[0]; b != nil; b = b.overflow {
+			dstBmap, pos = moveToBmap(t, dst, dstBmap, pos, b)
+		}
+		return dst
+	}
+
+	//src.B != 0
+	if dst.B == 0 {
+		dst.buckets = newarray[K, V](t, 1)
+		dst.countArray(t, 1)
+	}
+	dstArraySize := int(bucketShift(dst.B))
+	srcArraySize := int(bucketShift(src.B))
+	for i := 0; i < dstArraySize; i++ {
+		dstBmap := &dst.buckets[i]
+		pos := 0
+		for j := 0; j < srcArraySize; j += dstArraySize {
+			srcBmap := &src.buckets[i+j]
+			for srcBmap != nil {
+				dstBmap, pos = moveToBmap(t, dst, dstBmap, pos, srcBmap)
+				srcBmap = srcBmap.overflow
+			}
+		}
+	}
+
+	if src.oldbuckets == nil {
+		return dst
+	}
+
+	oldB := src.B
+	srcOldbuckets := src.oldbuckets
+	if src.shrinking() {
+		oldB++
+	} else if !src.sameSizeGrow() {
+		oldB--
+	}
+	oldSrcArraySize := int(bucketShift(oldB))
+
+	for i := 0; i < oldSrcArraySize; i++ {
+		srcBmap := &srcOldbuckets[i]
+		if evacuated(srcBmap) {
+			continue
+		}
+
+		if oldB >= dst.B { // main bucket bits in dst is less than oldB bits in src
+			dstBmap := &dst.buckets[uintptr(i)&bucketMask(dst.B)]
+			for dstBmap.overflow != nil {
+				dstBmap = dstBmap.overflow
+			}
+			pos := 0
+			for srcBmap != nil {
+				dstBmap, pos = moveToBmap(t, dst, dstBmap, pos, srcBmap)
+				srcBmap = srcBmap.overflow
+			}
+			continue
+		}
+
+		for srcBmap != nil {
+			// move from oldBlucket to new bucket
+			for i := uintptr(0); i < t.bucketCnt; i++ {
+				if isEmpty(srcBmap.tophash[i]) {
+					continue
+				}
+
+				if src.flags&hashWriting != 0 {
+					fatal("concurrent map clone and map write")
+				}
+
+				dstEle := mapassign(t, dst, *srcBmap.key(i))
+				*dstEle = *srcBmap.elem(i)
+			}
+			srcBmap = srcBmap.overflow
+		}
+	}
+	return dst
+}
+
+// keys appends the keys of h to s, the way maps.keys fills its slice:
+// buckets from a random start, then unevacuated old buckets.
+func keys[K comparable, V any](t *maptype[K], h *hmap[K, V], s []K) []K {
+	if h == nil || h.count == 0 {
+		return s
+	}
+	r := int(h.fastrand())
+	offset := uint8(r >> h.B & int(t.bucketCnt-1))
+	if h.B == 0 {
+		return copyKeys(t, h, &h.buckets[0], s, offset)
+	}
+	arraySize := int(bucketShift(h.B))
+	buckets := h.buckets
+	for i := 0; i < arraySize; i++ {
+		bucket := (i + r) & (arraySize - 1)
+		s = copyKeys(t, h, &buckets[bucket], s, offset)
+	}
+
+	if h.growing() {
+		oldArraySize := int(h.noldbuckets())
+		for i := 0; i < oldArraySize; i++ {
+			bucket := (i + r) & (oldArraySize - 1)
+			b := &h.oldbuckets[bucket]
+			if evacuated(b) {
+				continue
+			}
+			s = copyKeys(t, h, b, s, offset)
+		}
+	}
+	return s
+}
+
+func copyKeys[K comparable, V any](t *maptype[K], h *hmap[K, V], b *bmap[K, V], s []K, offset uint8) []K {
+	for b != nil {
+		for i := uintptr(0); i < t.bucketCnt; i++ {
+			offi := (i + uintptr(offset)) & (t.bucketCnt - 1)
+			if isEmpty(b.tophash[offi]) {
+				continue
+			}
+			if h.flags&hashWriting != 0 {
+				fatal("concurrent map read and map write")
+			}
+			s = append(s, *b.key(offi))
+		}
+		b = b.overflow
+	}
+	return s
+}
+
+// values is keys for elems.
+func values[K comparable, V any](t *maptype[K], h *hmap[K, V], s []V) []V {
+	if h == nil || h.count == 0 {
+		return s
+	}
+	r := int(h.fastrand())
+	offset := uint8(r >> h.B & int(t.bucketCnt-1))
+	if h.B == 0 {
+		return copyValues(t, h, &h.buckets[0], s, offset)
+	}
+	arraySize := int(bucketShift(h.B))
+	buckets := h.buckets
+	for i := 0; i < arraySize; i++ {
+		bucket := (i + r) & (arraySize - 1)
+		s = copyValues(t, h, &buckets[bucket], s, offset)
+	}
+
+	if h.growing() {
+		oldArraySize := int(h.noldbuckets())
+		for i := 0; i < oldArraySize; i++ {
+			bucket := (i + r) & (oldArraySize - 1)
+			b := &h.oldbuckets[bucket]
+			if evacuated(b) {
+				continue
+			}
+			s = copyValues(t, h, b, s, offset)
+		}
+	}
+	return s
+}
+
+func copyValues[K comparable, V any](t *maptype[K], h *hmap[K, V], b *bmap[K, V], s []V, offset uint8) []V {
+	for b != nil {
+		for i := uintptr(0); i < t.bucketCnt; i++ {
+			offi := (i + uintptr(offset)) & (t.bucketCnt - 1)
+			if isEmpty(b.tophash[offi]) {
+				continue
+			}
+			if h.flags&hashWriting != 0 {
+				fatal("concurrent map read and map write")
+			}
+			s = append(s, *b.elem(offi))
+		}
+		b = b.overflow
+	}
+	return s
+}
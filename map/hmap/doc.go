@@ -0,0 +1,27 @@
+// Package hmap is a user-space, generic port of the map implementation
+// annotated in map/map.go (runtime/map.go as of go1.21).
+//
+// The algorithm is kept as-is: 8-slot buckets (4 to 32 with WithBucketCnt)
+// selected by the low B bits of the hash, a tophash byte per slot holding
+// the high 8 bits, overflow chains, the emptyOne/emptyRest markers,
+// doubling and same-size growth that is spread over later writes by
+// growWork/evacuate and tracked by nevacuate, and iterators that start at
+// a random bucket and offset.
+// Function names follow the runtime (makemap, mapassign, mapaccess2,
+// mapdelete, mapiterinit, hashGrow, evacuate, ...) so the annotations in
+// map/map.go can be read side by side with code that actually runs.
+//
+// What differs is only what Go code outside the runtime cannot do:
+// buckets are a struct of slices instead of a raw memory block addressed
+// with add(), t.Hasher is a closure built once per key type, and
+// fatal/throw are ordinary panics.
+/*
+	hmap 包是 map/map.go 中源码的可运行版本（泛型）。
+	算法保持不变：每个桶 8 个 cell（WithBucketCnt 可改为 4~32），hash 低 B 位选桶，高 8 位作为 tophash，
+	溢出桶链表，emptyOne/emptyRest 标记，翻倍扩容与等量扩容，以及由 growWork/evacuate
+	渐进式完成、nevacuate 记录进度的搬迁过程。
+	函数名与 runtime 保持一致，方便对照 map.go 里的注释单步调试。
+	不同点只在于用户态做不到的部分：桶用切片表示而不是裸内存 + add() 指针运算，
+	t.Hasher 是按 key 类型生成的闭包，fatal/throw 变成普通的 panic。
+*/
+package hmap
@@ -0,0 +1,77 @@
+package hmap
+
+import (
+	"math"
+	"math/bits"
+)
+
+// geometry is the shape of a map's buckets: the runtime's bucketCnt,
+// loadFactorNum/loadFactorDen and maxKeySize/maxElemSize, chosen per map
+// instead of at compile time.
+/*
+	runtime 中桶的大小 bucketCnt=8、装载因子 loadFactorNum/loadFactorDen、makeBucketArray 里
+	b >= 4 时预分配 2^(b-4) 个溢出桶，都是编译期常量。这里把它们放进 maptype，每个 map 构造时决定：
+	  - 桶可以是 4/8/16/32 格，tophash 扫描、emptyRest 回填、搬迁、迭代的 offset 都按 t.bucketCnt 计算；
+	  - 装载因子可以任意取（不超过 bucketCnt），overLoadFactor 改为交叉相乘，分母不是 2 的幂时也精确；
+	  - 预分配溢出桶的阈值由 overflowShift 按几何参数估算，默认参数下正好得到 runtime 的 4。
+*/
+type geometry struct {
+	bucketCntBits uint8
+	loadFactorNum uintptr
+	loadFactorDen uintptr
+
+	maxKeySize, maxElemSize int
+}
+
+// geometry returns the bucket geometry selected by c, validated.
+func (c *config) geometry() geometry {
+	g := geometry{
+		bucketCntBits: bucketCntBits,
+		loadFactorNum: loadFactorNum,
+		loadFactorDen: loadFactorDen,
+		maxKeySize:    maxKeySize,
+		maxElemSize:   maxElemSize,
+	}
+	if c.indirect {
+		g.maxKeySize, g.maxElemSize = c.maxKeySize, c.maxElemSize
+	}
+	if c.bucketCnt != 0 {
+		g.bucketCntBits = uint8(bits.TrailingZeros(uint(c.bucketCnt)))
+		g.loadFactorNum = uintptr(c.bucketCnt*13/16) * loadFactorDen
+	}
+	if c.loadFactorDen != 0 {
+		g.loadFactorNum, g.loadFactorDen = uintptr(c.loadFactorNum), uintptr(c.loadFactorDen)
+		if g.loadFactorNum > g.loadFactorDen<<g.bucketCntBits {
+			panic(plainError("hmap: load factor exceeds the bucket count"))
+		}
+	}
+	return g
+}
+
+// overflowShift derives makeBucketArray's preallocation from g. Between
+// two grows a map of 2^b buckets holds loadFactor/2 to loadFactor entries
+// per bucket, so it sits at about 3/4 of loadFactor for the median number
+// of inserts. With hashes spread evenly the entries of one bucket follow
+// a Poisson distribution of that mean; the expected number of overflow
+// buckets each bucket needs, rounded up to a power of two 1/2^s, gives
+// 2^(b-s) overflow buckets for the whole array. The runtime's constants
+// (8 cells, load factor 6) give s = 4, its "b >= 4".
+func (g geometry) overflowShift() uint8 {
+	cnt := 1 << g.bucketCntBits
+	mean := 0.75 * float64(g.loadFactorNum) / float64(g.loadFactorDen)
+
+	// expected overflow buckets per bucket: sum over k of
+	// P(k entries) * ceil((k-cnt)/cnt), truncated where the terms vanish.
+	var overflow float64
+	p := math.Exp(-mean) // P(0)
+	for k := 1; k <= 8*cnt+64; k++ {
+		p *= mean / float64(k)
+		if k > cnt {
+			overflow += p * float64((k-1)/cnt)
+		}
+	}
+	if overflow < 0x1p-32 {
+		return math.MaxUint8 // never worth preallocating
+	}
+	return uint8(math.Floor(-math.Log2(overflow)))
+}
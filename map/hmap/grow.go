@@ -0,0 +1,44 @@
+package hmap
+
+// Grow does the growth work of one write without writing anything. If a
+// growth is in progress it evacuates the next old bucket, the step
+// growWork takes on every write to make progress; otherwise it starts a
+// growth as mapassign would on reaching the load factor: hashGrow doubles
+// the buckets if one more entry would be over the load factor and grows
+// to the same size otherwise. An empty map with no buckets is left alone.
+//
+// Grow is not in the runtime. It lets a program or a script (see
+// map/cmd/mapsim) walk through a growth one evacuation at a time.
+/*
+	Grow 在不写入任何数据的情况下完成一次写操作附带的扩容工作：正在扩容时搬迁下一个旧桶（growWork 里推进进度的那一步），
+	否则像 mapassign 达到装载因子时那样调用 hashGrow 开始扩容（再插入一个元素会超过装载因子则翻倍，否则等量扩容）。
+	runtime 没有这个操作，加上它是为了能一步一步地观察扩容和搬迁。
+*/
+func (m *Map[K, V]) Grow() {
+	mapgrow(m.t, m.h)
+	debugCheck(m.t, m.h)
+}
+
+func mapgrow[K comparable, V any](t *maptype[K], h *hmap[K, V]) {
+	if h.flags&hashWriting != 0 {
+		fatal("concurrent map writes")
+	}
+	if h.buckets == nil {
+		return
+	}
+	h.flags ^= hashWriting
+	if h.trace != nil {
+		h.trace.begin("mapgrow")
+	}
+
+	if h.growing() {
+		evacuate(t, h, h.nevacuate)
+	} else {
+		hashGrow(t, h)
+	}
+
+	if h.flags&hashWriting == 0 {
+		fatal("concurrent map writes")
+	}
+	h.flags &^= hashWriting
+}
@@ -0,0 +1,196 @@
+package hmap
+
+import (
+	"reflect"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/internal/rtalg"
+)
+
+// The runtime stores a key larger than maxKeySize bytes (an elem larger
+// than maxElemSize) out of line: the bucket cell holds a pointer, and
+// mapassign allocates the key with newobject(t.Key). t.IndirectKey() and
+// t.IndirectElem() make every access follow that pointer. Here a bucket
+// of such a map has ikeys (ielems) instead of keys (elems); key and elem
+// return the cell either way.
+/*
+	runtime 中 key 超过 128 字节（maxKeySize）、elem 超过 128 字节（maxElemSize）时，
+	桶里只存一个指针，mapassign 插入新 key 时用 newobject 单独分配，读写都要多解引用一次。
+	这里用 ikeys/ielems（指针切片）代替 keys/elems 来模拟，阈值可以用 WithIndirect 调整，
+	MemStats 报告分配次数和每个键值对占用的字节数，用来判断大结构体是否应该自己改为存指针。
+*/
+const (
+	maxKeySize  = 128
+	maxElemSize = 128
+)
+
+// key returns cell i's key, following the pointer if keys are indirect.
+func (b *bmap[K, V]) key(i uintptr) *K {
+	if b.ikeys != nil {
+		return b.ikeys[i]
+	}
+	return &b.keys[i]
+}
+
+// elem returns cell i's elem, following the pointer if elems are indirect.
+func (b *bmap[K, V]) elem(i uintptr) *V {
+	if b.ielems != nil {
+		return b.ielems[i]
+	}
+	return &b.elems[i]
+}
+
+// moveCell copies cell i of src to cell j of b as typedmemmove copies a
+// cell: for indirect keys and elems that is the pointer, not the object.
+func (b *bmap[K, V]) moveCell(j uintptr, src *bmap[K, V], i uintptr) {
+	if b.ikeys != nil {
+		b.ikeys[j] = src.ikeys[i]
+	} else {
+		b.keys[j] = src.keys[i]
+	}
+	if b.ielems != nil {
+		b.ielems[j] = src.ielems[i]
+	} else {
+		b.elems[j] = src.elems[i]
+	}
+}
+
+// clearCells zeroes every key and elem cell of b, pointers included.
+func (b *bmap[K, V]) clearCells() {
+	clear(b.keys)
+	clear(b.elems)
+	clear(b.ikeys)
+	clear(b.ielems)
+}
+
+// allocStats counts what a map has allocated, as mallocgc would see it.
+type allocStats struct {
+	arrays, overflows, keys, elems uint64
+	bytes                          uint64
+}
+
+// roundup is rtalg.RoundupSize, except that zero-size objects take no
+// memory (mallocgc hands out zerobase).
+func roundup(size uintptr) uint64 {
+	if size == 0 {
+		return 0
+	}
+	return uint64(rtalg.RoundupSize(size))
+}
+
+// countArray records the allocation of a bucket array of n buckets.
+func (h *hmap[K, V]) countArray(t *maptype[K], n int) {
+	h.allocs.arrays++
+	h.allocs.bytes += roundup(uintptr(n) * t.bucketSize)
+}
+
+// countOverflow records an overflow bucket from newobject(t.Bucket).
+func (h *hmap[K, V]) countOverflow(t *maptype[K]) {
+	h.allocs.overflows++
+	h.allocs.bytes += roundup(t.bucketSize)
+}
+
+// newKey allocates an indirect key, newobject(t.Key).
+func (h *hmap[K, V]) newKey(t *maptype[K]) *K {
+	h.allocs.keys++
+	h.allocs.bytes += roundup(t.keySize)
+	if t.arena != nil {
+		return (*K)(t.arena.alloc(t.keySize))
+	}
+	return new(K)
+}
+
+// newElem allocates an indirect elem, newobject(t.Elem).
+func (h *hmap[K, V]) newElem(t *maptype[K]) *V {
+	h.allocs.elems++
+	h.allocs.bytes += roundup(t.elemSize)
+	if t.arena != nil {
+		return (*V)(t.arena.alloc(t.elemSize))
+	}
+	return new(V)
+}
+
+// MemStats describes the memory of a Map as the runtime would lay it
+// out, and the allocations it has made since New.
+type MemStats struct {
+	Count        int
+	KeySize      uintptr // unsafe.Sizeof of K
+	ElemSize     uintptr // unsafe.Sizeof of V
+	IndirectKey  bool    // keys are stored out of line (see WithIndirect)
+	IndirectElem bool
+	BucketSize   uintptr // t.BucketSize, with pointer cells for indirect keys or elems
+
+	// Live memory: the bucket arrays, overflow buckets allocated one by
+	// one, and the out-of-line keys and elems, each rounded up to its
+	// size class. BytesPerEntry is their sum divided by Count.
+	Buckets       int
+	BucketBytes   uint64
+	IndirectBytes uint64
+	BytesPerEntry float64
+
+	// Allocations since New: bucket arrays, overflow buckets from
+	// newobject, and indirect keys and elems, with their total size.
+	ArrayAllocs    uint64
+	OverflowAllocs uint64
+	KeyAllocs      uint64
+	ElemAllocs     uint64
+	AllocBytes     uint64
+}
+
+// MemStats returns the memory statistics of m.
+func (m *Map[K, V]) MemStats() MemStats {
+	t, h := m.t, m.h
+	s := MemStats{
+		Count:          h.count,
+		KeySize:        t.keySize,
+		ElemSize:       t.elemSize,
+		IndirectKey:    t.indirectKey,
+		IndirectElem:   t.indirectElem,
+		BucketSize:     t.bucketSize,
+		ArrayAllocs:    h.allocs.arrays,
+		OverflowAllocs: h.allocs.overflows,
+		KeyAllocs:      h.allocs.keys,
+		ElemAllocs:     h.allocs.elems,
+		AllocBytes:     h.allocs.bytes,
+	}
+	arrays := [2][]bmap[K, V]{h.buckets, h.oldbuckets}
+	nmain := [2]uintptr{bucketShift(h.B), 0}
+	if h.oldbuckets != nil {
+		nmain[1] = h.noldbuckets()
+	}
+	for j, array := range arrays {
+		if len(array) == 0 {
+			continue
+		}
+		s.Buckets += len(array)
+		s.BucketBytes += roundup(uintptr(len(array)) * t.bucketSize)
+		for i := range nmain[j] {
+			for b := array[i].overflow; b != nil; b = b.overflow {
+				if indexIn(array, b) < 0 {
+					s.Buckets++
+					s.BucketBytes += roundup(t.bucketSize)
+				}
+			}
+		}
+	}
+	// Evacuated cells share their key and elem with the new cell, so
+	// every live entry owns exactly one of each.
+	if t.indirectKey {
+		s.IndirectBytes += uint64(h.count) * roundup(t.keySize)
+	}
+	if t.indirectElem {
+		s.IndirectBytes += uint64(h.count) * roundup(t.elemSize)
+	}
+	if h.count > 0 {
+		s.BytesPerEntry = float64(s.BucketBytes+s.IndirectBytes) / float64(h.count)
+	}
+	return s
+}
+
+// cellSize is the size of a key or elem cell of type typ: a pointer if
+// the type is larger than max and so stored indirectly.
+func cellSize(typ reflect.Type, max int) (size uintptr, indirect bool) {
+	if typ.Size() > uintptr(max) {
+		return ptrSize, true
+	}
+	return typ.Size(), false
+}
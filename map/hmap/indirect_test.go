@@ -0,0 +1,141 @@
+package hmap
+
+import (
+	"testing"
+)
+
+// allocTracer counts the bucket arrays and overflow buckets a map
+// allocates, from its growth events. It reads the map at each
+// GrowStart, which a Tracer outside the package must not do.
+type allocTracer[K comparable, V any] struct {
+	m         *Map[K, V]
+	arrays    []int // length of each bucket array after the first
+	overflows uint64
+}
+
+func (a *allocTracer[K, V]) Trace(e Event) {
+	switch e := e.(type) {
+	case *GrowStart:
+		a.arrays = append(a.arrays, len(a.m.h.buckets))
+	case *OverflowAlloc:
+		if !e.FromNextOverflow {
+			a.overflows++
+		}
+	}
+}
+
+// checkMemStats compares m.MemStats with what the layout of m and the
+// allocations seen by a add up to. newKeys counts the inserts of a key
+// that was not in the map.
+func checkMemStats[K comparable, V any](t *testing.T, m *Map[K, V], a *allocTracer[K, V], newKeys uint64) {
+	t.Helper()
+	s := m.MemStats()
+	h := m.h
+	bucket := roundup(s.BucketSize)
+
+	buckets, bytes := 0, uint64(0)
+	for _, array := range [][]bmap[K, V]{h.buckets, h.oldbuckets} {
+		if len(array) > 0 {
+			buckets += len(array)
+			bytes += roundup(uintptr(len(array)) * s.BucketSize)
+		}
+	}
+	l := m.Layout()
+	for _, chains := range [][]Chain{l.Buckets, l.OldBuckets} {
+		for _, c := range chains {
+			for _, b := range c {
+				if b.Index < 0 {
+					buckets++
+					bytes += bucket
+				}
+			}
+		}
+	}
+	if s.Count != m.Len() || s.Buckets != buckets || s.BucketBytes != bytes {
+		t.Errorf("%d entries in %d buckets of %d bytes; MemStats %+v", m.Len(), buckets, bytes, s)
+	}
+	var indirect uint64
+	if s.IndirectKey {
+		indirect += uint64(s.Count) * roundup(s.KeySize)
+	}
+	if s.IndirectElem {
+		indirect += uint64(s.Count) * roundup(s.ElemSize)
+	}
+	if s.IndirectBytes != indirect {
+		t.Errorf("IndirectBytes %d, want %d", s.IndirectBytes, indirect)
+	}
+
+	// The first array has one bucket; makemap allocates it on the first
+	// insert.
+	allocs := uint64(1) + uint64(len(a.arrays))
+	abytes := bucket + a.overflows*bucket
+	for _, n := range a.arrays {
+		abytes += roundup(uintptr(n) * s.BucketSize)
+	}
+	var keys, elems uint64
+	if s.IndirectKey {
+		keys = newKeys
+		abytes += keys * roundup(s.KeySize)
+	}
+	if s.IndirectElem {
+		elems = newKeys
+		abytes += elems * roundup(s.ElemSize)
+	}
+	if s.ArrayAllocs != allocs || s.OverflowAllocs != a.overflows || s.KeyAllocs != keys ||
+		s.ElemAllocs != elems || s.AllocBytes != abytes {
+		t.Errorf("allocated %d arrays, %d overflow buckets, %d keys, %d elems, %d bytes; MemStats %+v",
+			allocs, a.overflows, keys, elems, abytes, s)
+	}
+}
+
+// memStatsRun inserts, deletes and reinserts keys made by key, checking
+// MemStats along the way, in the middle of growths as well.
+func memStatsRun[K comparable, V any](t *testing.T, key func(int) K, opts ...Option) {
+	a := new(allocTracer[K, V])
+	m := New[K, V](0, append(opts, WithTracer(a))...)
+	a.m = m
+	var newKeys uint64
+	put := func(i int) {
+		if _, ok := m.Lookup(key(i)); !ok {
+			newKeys++
+		}
+		var v V
+		m.Put(key(i), v)
+	}
+	for i := range 3000 {
+		put(i)
+		if i%97 == 0 || m.Growing() && i%5 == 0 {
+			checkMemStats(t, m, a, newKeys)
+		}
+	}
+	for i := range 2000 {
+		m.Delete(key(i))
+		put(i + 3000)
+		put(i + 3000)
+		if i%97 == 0 {
+			checkMemStats(t, m, a, newKeys)
+		}
+	}
+	checkMemStats(t, m, a, newKeys)
+	if err := m.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if a.overflows == 0 {
+		t.Error("no overflow bucket came from newobject")
+	}
+}
+
+// TestMemStats checks the MemStats of maps with inline, indirect and
+// mixed storage against their layout.
+func TestMemStats(t *testing.T) {
+	t.Run("inline", func(t *testing.T) {
+		memStatsRun[int, int](t, func(i int) int { return i })
+	})
+	t.Run("large", func(t *testing.T) {
+		// 136 and 200 bytes, both over maxKeySize and maxElemSize.
+		memStatsRun[[17]int64, [200]byte](t, func(i int) [17]int64 { return [17]int64{16: int64(i)} })
+	})
+	t.Run("indirect keys", func(t *testing.T) {
+		memStatsRun[int, int](t, func(i int) int { return i }, WithIndirect(0, 8))
+	})
+}
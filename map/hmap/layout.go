@@ -0,0 +1,162 @@
+package hmap
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// Layout is a snapshot of the internals of a Map: the hmap header, every
+// bucket of buckets and oldbuckets with its overflow chain, and where
+// extra.nextOverflow points. It copies everything it reports, so it stays
+// valid after the map changes. Package hmapviz renders it.
+/*
+	Layout 是 Map 内部结构的快照：hmap 头部字段、buckets/oldbuckets 中每个桶及其溢出链、
+	extra.nextOverflow 的位置。数据都是拷贝出来的，map 之后再变化也不影响快照。
+*/
+type Layout struct {
+	Count        int
+	Flags        uint8
+	B            uint8
+	NOverflow    uint16
+	Hash0        uint32
+	SameSizeGrow bool    // the current growth keeps the bucket count
+	Shrinking    bool    // the current growth halves the bucket count
+	NEvacuate    uintptr // old buckets below this index have been evacuated (new buckets, when shrinking)
+
+	// Buckets has one chain per bucket of the current array (1<<B of
+	// them, or none before the first insert). OldBuckets has one chain
+	// per bucket of the previous array and is nil unless growing.
+	Buckets    []Chain
+	OldBuckets []Chain
+
+	// NBuckets is the length of the current bucket array, counting the
+	// preallocated overflow buckets after the first 1<<B. NextOverflow is
+	// the index extra.nextOverflow points at, or -1 when none are left.
+	NBuckets     int
+	NextOverflow int
+}
+
+// A Chain is a bucket followed by its overflow buckets.
+type Chain []Bucket
+
+// Bucket is the snapshot of one bmap.
+type Bucket struct {
+	// Index is the position of the bucket in the array it belongs to.
+	// Overflow buckets taken from the preallocated tail of the array have
+	// Index >= 1<<B; those allocated by newobject have Index -1.
+	Index   int
+	Tophash []uint8
+	// Keys holds fmt.Sprint of the key in each filled cell, "" elsewhere.
+	Keys []string
+}
+
+// Evacuated reports whether the first bucket of c has been evacuated,
+// the test evacuated() applies to an old bucket.
+func (c Chain) Evacuated() bool {
+	if len(c) == 0 {
+		return false
+	}
+	h := c[0].Tophash[0]
+	return h > emptyOne && h < minTopHash
+}
+
+// Layout returns a snapshot of the internals of m.
+func (m *Map[K, V]) Layout() *Layout {
+	h := m.h
+	l := &Layout{
+		Count:        h.count,
+		Flags:        h.flags,
+		B:            h.B,
+		NOverflow:    h.noverflow,
+		Hash0:        h.hash0,
+		SameSizeGrow: h.growing() && h.sameSizeGrow(),
+		Shrinking:    h.growing() && h.shrinking(),
+		NEvacuate:    h.nevacuate,
+		NBuckets:     len(h.buckets),
+		NextOverflow: -1,
+	}
+	if h.buckets != nil {
+		l.Buckets = chainsOf(h.buckets, bucketShift(h.B))
+	}
+	if h.oldbuckets != nil {
+		l.OldBuckets = chainsOf(h.oldbuckets, h.noldbuckets())
+	}
+	if h.extra != nil && h.extra.nextOverflow != nil {
+		l.NextOverflow = indexIn(h.buckets, h.extra.nextOverflow)
+	}
+	return l
+}
+
+// chainsOf snapshots the first n buckets of array and their overflow chains.
+func chainsOf[K comparable, V any](array []bmap[K, V], n uintptr) []Chain {
+	chains := make([]Chain, n)
+	for i := range chains {
+		for b := &array[i]; b != nil; b = b.overflow {
+			chains[i] = append(chains[i], bucketOf(array, b))
+		}
+	}
+	return chains
+}
+
+func bucketOf[K comparable, V any](array []bmap[K, V], b *bmap[K, V]) Bucket {
+	s := Bucket{
+		Index:   indexIn(array, b),
+		Tophash: append([]uint8(nil), b.tophash...),
+		Keys:    make([]string, len(b.tophash)),
+	}
+	for i, top := range b.tophash {
+		if top >= minTopHash {
+			s.Keys[i] = fmt.Sprint(*b.key(uintptr(i)))
+		}
+	}
+	return s
+}
+
+// indexIn returns the index of b in array, or -1 if b lives elsewhere.
+func indexIn[K comparable, V any](array []bmap[K, V], b *bmap[K, V]) int {
+	if len(array) == 0 {
+		return -1
+	}
+	off := uintptr(unsafe.Pointer(b)) - uintptr(unsafe.Pointer(&array[0]))
+	size := unsafe.Sizeof(array[0])
+	if off%size != 0 || off/size >= uintptr(len(array)) {
+		return -1
+	}
+	return int(off / size)
+}
+
+// Cell classifies a tophash value.
+type Cell uint8
+
+const (
+	CellEmptyRest      Cell = emptyRest
+	CellEmptyOne       Cell = emptyOne
+	CellEvacuatedX     Cell = evacuatedX
+	CellEvacuatedY     Cell = evacuatedY
+	CellEvacuatedEmpty Cell = evacuatedEmpty
+	CellFilled         Cell = minTopHash
+)
+
+// CellOf returns the state a tophash value encodes.
+func CellOf(top uint8) Cell {
+	if top >= minTopHash {
+		return CellFilled
+	}
+	return Cell(top)
+}
+
+func (c Cell) String() string {
+	switch c {
+	case CellEmptyRest:
+		return "emptyRest"
+	case CellEmptyOne:
+		return "emptyOne"
+	case CellEvacuatedX:
+		return "evacuatedX"
+	case CellEvacuatedY:
+		return "evacuatedY"
+	case CellEvacuatedEmpty:
+		return "evacuatedEmpty"
+	}
+	return "filled"
+}
@@ -0,0 +1,1205 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hmap
+
+// This file is the runnable counterpart of map/map.go. Comments that only
+// restate the annotations there are left out; see map/map.go for the full
+// walk-through of each function.
+// (本文件是 map/map.go 的可运行版本，逐函数对照阅读即可，详细注释见 map/map.go)
+
+import (
+	"math/bits"
+	"math/rand/v2"
+	"unsafe"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/internal/rtalg"
+)
+
+const (
+	// Default maximum number of key/elem pairs a bucket can hold.
+	// A map built with WithBucketCnt uses maptype.bucketCnt instead.
+	bucketCntBits = 3
+	bucketCnt     = 1 << bucketCntBits
+
+	// Default maximum average load of a bucket that triggers growth is bucketCnt*13/16 (about 80% full)
+	// Represent as loadFactorNum/loadFactorDen, to allow integer math.
+	// WithLoadFactor replaces both; see maptype.
+	loadFactorDen = 2
+	loadFactorNum = (bucketCnt * 13 / 16) * loadFactorDen
+
+	// Possible tophash values. We reserve a few possibilities for special marks.
+	emptyRest      = 0 // this cell is empty, and there are no more non-empty cells at higher indexes or overflows.
+	emptyOne       = 1 // this cell is empty
+	evacuatedX     = 2 // key/elem is valid.  Entry has been evacuated to first half of larger table.
+	evacuatedY     = 3 // same as above, but evacuated to second half of larger table.
+	evacuatedEmpty = 4 // cell is empty, bucket is evacuated.
+	minTopHash     = 5 // minimum tophash for a normal filled cell.
+
+	// flags
+	iterator     = 1  // there may be an iterator using buckets
+	oldIterator  = 2  // there may be an iterator using oldbuckets
+	hashWriting  = 4  // a goroutine is writing to the map
+	sameSizeGrow = 8  // the current map growth is to a new map of the same size
+	shrinking    = 16 // the current map growth halves the number of buckets; see hashShrink
+
+	// sentinel bucket ID for iterator checks
+	noCheck = 1<<(8*ptrSize) - 1
+
+	ptrSize  = rtalg.PtrSize
+	maxAlloc = (1 << 48) - 1 // runtime.maxAlloc on 64-bit linux
+)
+
+// isEmpty reports whether the given tophash array entry represents an empty bucket entry.
+func isEmpty(x uint8) bool {
+	return x <= emptyOne
+}
+
+// A header for a Go map.
+type hmap[K comparable, V any] struct {
+	count     int // # live cells == size of map.
+	flags     uint8
+	B         uint8  // log_2 of # of buckets (can hold up to loadFactor * 2^B items)
+	noverflow uint16 // approximate number of overflow buckets; see incrnoverflow for details
+	hash0     uint32 // hash seed
+
+	buckets    []bmap[K, V] // array of 2^B Buckets (plus preallocated overflow buckets). may be nil if count==0.
+	oldbuckets []bmap[K, V] // previous bucket array of half (or, when shrinking, twice) the size, non-nil only when growing
+	nevacuate  uintptr      // progress counter for evacuation (buckets less than this have been evacuated)
+
+	extra *mapextra[K, V] // optional fields
+
+	trace *tracer     // growth events go here when non-nil; see WithTracer
+	probe *prober     // steps of mapassign and mapdelete go here when non-nil; see WithProbe
+	rand  rand.Source // source of fastrand when non-nil; see WithRand
+
+	shrink float64 // fraction of loadFactor below which mapdelete shrinks; 0 never. See WithShrink
+	minB   uint8   // B never shrinks below this, the B chosen for the size hint
+
+	allocs allocStats // see MemStats
+}
+
+// mapextra holds fields that are not present on all maps.
+//
+// The runtime also keeps overflow/oldoverflow here so that buckets of
+// pointer-free maps can be marked noscan. Buckets in this port are plain
+// Go values the GC always traces, so only nextOverflow is needed.
+// (runtime 的 overflow/oldoverflow 只是为了让 GC 不扫描无指针的桶，这里的桶都是普通 Go 对象，只保留 nextOverflow)
+type mapextra[K comparable, V any] struct {
+	// nextOverflow holds a pointer to a free overflow bucket.
+	nextOverflow *bmap[K, V]
+}
+
+// A bucket for a Go map.
+//
+// The runtime stores tophash, then bucketCnt keys, then bucketCnt elems,
+// then the overflow pointer in one block of t.BucketSize bytes. Here the
+// three arrays are slices carved out of one allocation per bucket array
+// (see newarray), which keeps the same "all keys, then all elems" layout.
+// Keys or elems stored indirectly live in ikeys or ielems instead, and
+// the other slice is nil; see indirect.go.
+// (runtime 的桶是一整块内存：tophash|8 个 key|8 个 elem|overflow 指针，这里用三个切片表示同样的布局)
+type bmap[K comparable, V any] struct {
+	tophash  []uint8 // len t.bucketCnt
+	keys     []K     // len t.bucketCnt, unless t.indirectKey
+	elems    []V     // len t.bucketCnt, unless t.indirectElem
+	ikeys    []*K    // len t.bucketCnt if t.indirectKey
+	ielems   []*V    // len t.bucketCnt if t.indirectElem
+	overflow *bmap[K, V]
+}
+
+// A hash iteration structure.
+type hiter[K comparable, V any] struct {
+	key         *K // nil indicates iteration end.
+	elem        *V
+	t           *maptype[K]
+	h           *hmap[K, V]
+	buckets     []bmap[K, V] // bucket ptr at hash_iter initialization time
+	bptr        *bmap[K, V]  // current bucket
+	startBucket uintptr      // bucket iteration started at
+	offset      uint8        // intra-bucket offset to start from during iteration (should be big enough to hold t.bucketCnt-1)
+	wrapped     bool         // already wrapped around from end of bucket array to beginning
+	B           uint8
+	i           uint8
+	bucket      uintptr
+	checkBucket uintptr
+	shrinkNext  *bmap[K, V] // when shrinking, the old bucket to iterate after the current chain
+}
+
+// bucketShift returns 1<<b, optimized for code generation.
+func bucketShift(b uint8) uintptr {
+	// Masking the shift amount allows overflow checks to be elided.
+	return uintptr(1) << (b & (ptrSize*8 - 1))
+}
+
+// bucketMask returns 1<<b - 1, optimized for code generation.
+func bucketMask(b uint8) uintptr {
+	return bucketShift(b) - 1
+}
+
+// tophash calculates the tophash value for hash.
+func tophash(hash uintptr) uint8 {
+	top := uint8(hash >> (ptrSize*8 - 8))
+	if top < minTopHash {
+		top += minTopHash
+	}
+	return top
+}
+
+func evacuated[K comparable, V any](b *bmap[K, V]) bool {
+	h := b.tophash[0]
+	return h > emptyOne && h < minTopHash
+}
+
+// incrnoverflow increments h.noverflow.
+// noverflow counts the number of overflow buckets.
+// This is used to trigger same-size map growth.
+// See also tooManyOverflowBuckets.
+// To keep hmap small, noverflow is a uint16.
+// When there are few buckets, noverflow is an exact count.
+// When there are many buckets, noverflow is an approximate count.
+func (h *hmap[K, V]) incrnoverflow() {
+	// We trigger same-size map growth if there are
+	// as many overflow buckets as buckets.
+	// We need to be able to count to 1<<h.B.
+	if h.B < 16 {
+		h.noverflow++
+		return
+	}
+	// Increment with probability 1/(1<<(h.B-15)).
+	// When we reach 1<<15 - 1, we will have approximately
+	// as many overflow buckets as buckets.
+	mask := uint32(1)<<(h.B-15) - 1
+	// Example: if h.B == 18, then mask == 7,
+	// and fastrand & 7 == 0 with probability 1/8.
+	if h.fastrand()&mask == 0 {
+		h.noverflow++
+	}
+}
+
+func (h *hmap[K, V]) newoverflow(t *maptype[K], b *bmap[K, V]) *bmap[K, V] {
+	var ovf *bmap[K, V]
+	prealloc := h.extra != nil && h.extra.nextOverflow != nil
+	if prealloc {
+		// We have preallocated overflow buckets available.
+		// See makeBucketArray for more details.
+		ovf = h.extra.nextOverflow
+		if ovf.overflow == nil {
+			// We're not at the end of the preallocated overflow buckets. Bump the pointer.
+			h.extra.nextOverflow = (*bmap[K, V])(unsafe.Add(unsafe.Pointer(ovf), unsafe.Sizeof(*ovf)))
+		} else {
+			// This is the last preallocated overflow bucket.
+			// Reset the overflow pointer on this bucket,
+			// which was set to a non-nil sentinel value.
+			ovf.overflow = nil
+			h.extra.nextOverflow = nil
+		}
+	} else {
+		ovf = newobject[K, V](t)
+		h.countOverflow(t)
+	}
+	h.incrnoverflow()
+	if h.trace != nil {
+		h.trace.emit(&OverflowAlloc{FromNextOverflow: prealloc, NOverflow: h.noverflow})
+	}
+	b.overflow = ovf
+	return ovf
+}
+
+// makemap implements Go map creation for make(map[k]v, hint).
+// If h != nil, the map can be created directly in h.
+func makemap[K comparable, V any](t *maptype[K], hint int, h *hmap[K, V]) *hmap[K, V] {
+	hi, mem := bits.Mul64(uint64(hint), uint64(t.bucketSize))
+	if hint < 0 || hi != 0 || mem > maxAlloc {
+		hint = 0
+	}
+
+	// initialize Hmap
+	if h == nil {
+		h = new(hmap[K, V])
+	}
+	h.hash0 = h.fastrand()
+
+	// Find the size parameter B which will hold the requested # of elements.
+	// For hint < 0 overLoadFactor returns false since hint < bucketCnt.
+	B := uint8(0)
+	for overLoadFactor(t, hint, B) {
+		B++
+	}
+	h.B = B
+
+	// allocate initial hash table
+	// if B == 0, the buckets field is allocated lazily later (in mapassign)
+	// If hint is large zeroing this memory could take a while.
+	if h.B != 0 {
+		var nextOverflow *bmap[K, V]
+		h.buckets, nextOverflow = makeBucketArray[K, V](t, h.B, nil)
+		h.countArray(t, len(h.buckets))
+		if nextOverflow != nil {
+			h.extra = new(mapextra[K, V])
+			h.extra.nextOverflow = nextOverflow
+		}
+	}
+
+	return h
+}
+
+// makeBucketArray initializes a backing array for map buckets.
+// 1<<b is the minimum number of buckets to allocate.
+// dirtyalloc should either be nil or a bucket array previously
+// allocated by makeBucketArray with the same t and b parameters.
+// If dirtyalloc is nil a new backing array will be alloced and
+// otherwise dirtyalloc will be cleared and reused as backing array.
+func makeBucketArray[K comparable, V any](t *maptype[K], b uint8, dirtyalloc []bmap[K, V]) (buckets []bmap[K, V], nextOverflow *bmap[K, V]) {
+	base := bucketShift(b)
+	nbuckets := base
+	// For small b, overflow buckets are unlikely.
+	// Avoid the overhead of the calculation.
+	if b >= t.overflowShift {
+		// Add on the estimated number of overflow buckets
+		// required to insert the median number of elements
+		// used with this value of b.
+		nbuckets += bucketShift(b - t.overflowShift)
+		sz := t.bucketSize * nbuckets
+		up := rtalg.RoundupSize(sz)
+		if up != sz {
+			nbuckets = up / t.bucketSize
+		}
+	}
+
+	if dirtyalloc == nil {
+		buckets = newarray[K, V](t, nbuckets)
+	} else {
+		// dirtyalloc was previously generated by
+		// the above newarray(t.Bucket, int(nbuckets))
+		// but may not be empty.
+		buckets = dirtyalloc
+		for i := range buckets {
+			b := &buckets[i]
+			clear(b.tophash)
+			b.clearCells()
+			b.overflow = nil
+		}
+	}
+
+	if base != nbuckets {
+		// We preallocated some overflow buckets.
+		// To keep the overhead of tracking these overflow buckets to a minimum,
+		// we use the convention that if a preallocated overflow bucket's overflow
+		// pointer is nil, then there are more available by bumping the pointer.
+		// We need a safe non-nil pointer for the last overflow bucket; just use buckets.
+		nextOverflow = &buckets[base]
+		last := &buckets[nbuckets-1]
+		last.overflow = &buckets[0]
+	}
+	return buckets, nextOverflow
+}
+
+// newarray allocates n buckets whose tophash, keys and elems share one
+// backing array each, like the single block newarray(t.Bucket, n) returns.
+func newarray[K comparable, V any](t *maptype[K], n uintptr) []bmap[K, V] {
+	if t.arena != nil {
+		return arenaArray[K, V](t, n)
+	}
+	buckets := make([]bmap[K, V], n)
+	tophash := make([]uint8, n*t.bucketCnt)
+	var (
+		keys   []K
+		elems  []V
+		ikeys  []*K
+		ielems []*V
+	)
+	if t.indirectKey {
+		ikeys = make([]*K, n*t.bucketCnt)
+	} else {
+		keys = make([]K, n*t.bucketCnt)
+	}
+	if t.indirectElem {
+		ielems = make([]*V, n*t.bucketCnt)
+	} else {
+		elems = make([]V, n*t.bucketCnt)
+	}
+	for i := range buckets {
+		lo, hi := uintptr(i)*t.bucketCnt, uintptr(i+1)*t.bucketCnt
+		b := &buckets[i]
+		b.tophash = tophash[lo:hi:hi]
+		if ikeys != nil {
+			b.ikeys = ikeys[lo:hi:hi]
+		} else {
+			b.keys = keys[lo:hi:hi]
+		}
+		if ielems != nil {
+			b.ielems = ielems[lo:hi:hi]
+		} else {
+			b.elems = elems[lo:hi:hi]
+		}
+	}
+	return buckets
+}
+
+// newobject allocates a single bucket, like newobject(t.Bucket).
+func newobject[K comparable, V any](t *maptype[K]) *bmap[K, V] {
+	return &newarray[K, V](t, 1)[0]
+}
+
+// mapaccess1 returns a pointer to h[key].  Returns nil, instead of a
+// reference to the zero object, if the key is not in the map.
+func mapaccess1[K comparable, V any](t *maptype[K], h *hmap[K, V], key K) *V {
+	e, _ := mapaccess2(t, h, key)
+	return e
+}
+
+func mapaccess2[K comparable, V any](t *maptype[K], h *hmap[K, V], key K) (*V, bool) {
+	if h == nil || h.count == 0 {
+		if t.hashMightPanic {
+			t.hasher(key, 0) // see issue 23734
+		}
+		return nil, false
+	}
+	if h.flags&hashWriting != 0 {
+		fatal("concurrent map read and map write")
+	}
+	hash := t.hasher(key, uintptr(h.hash0))
+	m := bucketMask(h.B)
+	b := &h.buckets[hash&m]
+	if c := h.oldbuckets; c != nil {
+		if h.shrinking() {
+			// There used to be twice as many buckets; mask in one more bit.
+			m = m<<1 | 1
+		} else if !h.sameSizeGrow() {
+			// There used to be half as many buckets; mask down one more power of two.
+			m >>= 1
+		}
+		oldb := &c[hash&m]
+		if !evacuated(oldb) {
+			b = oldb
+		}
+	}
+	top := tophash(hash)
+bucketloop:
+	for ; b != nil; b = b.overflow {
+		for i := uintptr(0); i < t.bucketCnt; i++ {
+			if b.tophash[i] != top {
+				if b.tophash[i] == emptyRest {
+					break bucketloop
+				}
+				continue
+			}
+			if key == *b.key(i) {
+				return b.elem(i), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// returns both key and elem. Used by map iterator.
+func mapaccessK[K comparable, V any](t *maptype[K], h *hmap[K, V], key *K) (*K, *V) {
+	if h == nil || h.count == 0 {
+		return nil, nil
+	}
+	hash := t.hasher(*key, uintptr(h.hash0))
+	m := bucketMask(h.B)
+	b := &h.buckets[hash&m]
+	if c := h.oldbuckets; c != nil {
+		if h.shrinking() {
+			// There used to be twice as many buckets; mask in one more bit.
+			m = m<<1 | 1
+		} else if !h.sameSizeGrow() {
+			// There used to be half as many buckets; mask down one more power of two.
+			m >>= 1
+		}
+		oldb := &c[hash&m]
+		if !evacuated(oldb) {
+			b = oldb
+		}
+	}
+	top := tophash(hash)
+bucketloop:
+	for ; b != nil; b = b.overflow {
+		for i := uintptr(0); i < t.bucketCnt; i++ {
+			if b.tophash[i] != top {
+				if b.tophash[i] == emptyRest {
+					break bucketloop
+				}
+				continue
+			}
+			if *key == *b.key(i) {
+				return b.key(i), b.elem(i)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// Like mapaccess, but allocates a slot for the key if it is not present in the map.
+func mapassign[K comparable, V any](t *maptype[K], h *hmap[K, V], key K) *V {
+	if h == nil {
+		panic(plainError("assignment to entry in nil map"))
+	}
+	if h.flags&hashWriting != 0 {
+		fatal("concurrent map writes")
+	}
+	hash := t.hasher(key, uintptr(h.hash0))
+
+	// Set hashWriting after calling t.hasher, since t.hasher may panic,
+	// in which case we have not actually done a write.
+	h.flags ^= hashWriting
+	if h.trace != nil {
+		h.trace.begin("mapassign")
+	}
+	if probing {
+		probeBegin(h, "mapassign", key, hash)
+	}
+
+	if h.buckets == nil {
+		h.buckets = newarray[K, V](t, 1)
+		h.countArray(t, 1)
+	}
+
+again:
+	bucket := hash & bucketMask(h.B)
+	if h.growing() {
+		if probing {
+			probeAt(h, StepGrowWork, bucket, nil, -1)
+		}
+		growWork(t, h, bucket)
+	}
+	b := &h.buckets[bucket]
+	top := tophash(hash)
+	if probing {
+		probeAt(h, StepBucket, bucket, b, -1)
+	}
+
+	// The runtime keeps pointers to the free cell's tophash, key and elem;
+	// the bucket and index reach all three, indirect or not.
+	var insertb *bmap[K, V]
+	var inserti uintptr
+	var elem *V
+bucketloop:
+	for {
+		for i := uintptr(0); i < t.bucketCnt; i++ {
+			if probing {
+				probeAt(h, StepCell, bucket, b, int(i))
+			}
+			if b.tophash[i] != top {
+				if isEmpty(b.tophash[i]) && insertb == nil {
+					insertb, inserti = b, i
+					if probing {
+						probeAt(h, StepFreeCell, bucket, b, int(i))
+					}
+				}
+				if b.tophash[i] == emptyRest {
+					break bucketloop
+				}
+				continue
+			}
+			k := b.key(i)
+			if key != *k {
+				continue
+			}
+			// already have a mapping for key. Update it.
+			if t.needKeyUpdate {
+				*k = key
+			}
+			elem = b.elem(i)
+			if probing {
+				probeAt(h, StepFound, bucket, b, int(i))
+			}
+			goto done
+		}
+		ovf := b.overflow
+		if ovf == nil {
+			break
+		}
+		b = ovf
+		if probing {
+			probeAt(h, StepBucket, bucket, b, -1)
+		}
+	}
+
+	// Did not find mapping for key. Allocate new cell & add entry.
+
+	// If we hit the max load factor or we have too many overflow buckets,
+	// and we're not already in the middle of growing, start growing.
+	if !h.growing() && (overLoadFactor(t, h.count+1, h.B) || tooManyOverflowBuckets(h.noverflow, h.B)) {
+		hashGrow(t, h)
+		if probing {
+			probeAt(h, StepHashGrow, hash&bucketMask(h.B), nil, -1)
+		}
+		goto again // Growing the table invalidates everything, so try again
+	}
+
+	if insertb == nil {
+		// The current bucket and all the overflow buckets connected to it are full, allocate a new one.
+		insertb, inserti = h.newoverflow(t, b), 0
+		if probing {
+			probeAt(h, StepNewOverflow, bucket, insertb, 0)
+		}
+	}
+
+	// store new key/elem at insert position
+	if t.indirectKey {
+		insertb.ikeys[inserti] = h.newKey(t)
+	}
+	if t.indirectElem {
+		insertb.ielems[inserti] = h.newElem(t)
+	}
+	*insertb.key(inserti) = key
+	elem = insertb.elem(inserti)
+	insertb.tophash[inserti] = top
+	h.count++
+	if probing {
+		probeAt(h, StepInsert, bucket, insertb, int(inserti))
+	}
+
+done:
+	if probing {
+		probeAt(h, StepDone, bucket, nil, -1)
+	}
+	if h.flags&hashWriting == 0 {
+		fatal("concurrent map writes")
+	}
+	h.flags &^= hashWriting
+	return elem
+}
+
+func mapdelete[K comparable, V any](t *maptype[K], h *hmap[K, V], key K) {
+	if h == nil || h.count == 0 {
+		if t.hashMightPanic {
+			t.hasher(key, 0) // see issue 23734
+		}
+		return
+	}
+	if h.flags&hashWriting != 0 {
+		fatal("concurrent map writes")
+	}
+
+	hash := t.hasher(key, uintptr(h.hash0))
+
+	// Set hashWriting after calling t.hasher, since t.hasher may panic,
+	// in which case we have not actually done a write (delete).
+	h.flags ^= hashWriting
+	if h.trace != nil {
+		h.trace.begin("mapdelete")
+	}
+	if probing {
+		probeBegin(h, "mapdelete", key, hash)
+	}
+
+	bucket := hash & bucketMask(h.B)
+	if h.growing() {
+		if probing {
+			probeAt(h, StepGrowWork, bucket, nil, -1)
+		}
+		growWork(t, h, bucket)
+	}
+	b := &h.buckets[bucket]
+	bOrig := b
+	top := tophash(hash)
+search:
+	for ; b != nil; b = b.overflow {
+		if probing {
+			probeAt(h, StepBucket, bucket, b, -1)
+		}
+		for i := uintptr(0); i < t.bucketCnt; i++ {
+			if probing {
+				probeAt(h, StepCell, bucket, b, int(i))
+			}
+			if b.tophash[i] != top {
+				if b.tophash[i] == emptyRest {
+					break search
+				}
+				continue
+			}
+			if key != *b.key(i) {
+				continue
+			}
+			if probing {
+				probeAt(h, StepFound, bucket, b, int(i))
+			}
+			// Only clear key if there are pointers in it.
+			if t.indirectKey {
+				b.ikeys[i] = nil
+			} else if t.keyPtrs {
+				var zero K
+				b.keys[i] = zero
+			}
+			if t.indirectElem {
+				b.ielems[i] = nil
+			} else {
+				var zero V
+				b.elems[i] = zero
+			}
+			b.tophash[i] = emptyOne
+			if probing {
+				probeAt(h, StepEmptyOne, bucket, b, int(i))
+			}
+			// If the bucket now ends in a bunch of emptyOne states,
+			// change those to emptyRest states.
+			// It would be nice to make this a separate function, but
+			// for loops are not currently inlineable.
+			if i == t.bucketCnt-1 {
+				if b.overflow != nil && b.overflow.tophash[0] != emptyRest {
+					goto notLast
+				}
+			} else {
+				if b.tophash[i+1] != emptyRest {
+					goto notLast
+				}
+			}
+			for {
+				b.tophash[i] = emptyRest
+				if probing {
+					probeAt(h, StepEmptyRest, bucket, b, int(i))
+				}
+				if i == 0 {
+					if b == bOrig {
+						break // beginning of initial bucket, we're done.
+					}
+					// Find previous bucket, continue at its last entry.
+					c := b
+					for b = bOrig; b.overflow != c; b = b.overflow {
+					}
+					i = t.bucketCnt - 1
+				} else {
+					i--
+				}
+				if b.tophash[i] != emptyOne {
+					break
+				}
+			}
+		notLast:
+			h.count--
+			// Reset the hash seed to make it more difficult for attackers to
+			// repeatedly trigger hash collisions. See issue 25237.
+			if h.count == 0 {
+				h.hash0 = h.fastrand()
+			}
+			if h.shouldShrink(t) {
+				hashShrink(t, h)
+				if probing {
+					probeAt(h, StepShrink, bucket, nil, -1)
+				}
+			}
+			break search
+		}
+	}
+
+	if probing {
+		probeAt(h, StepDone, bucket, nil, -1)
+	}
+	if h.flags&hashWriting == 0 {
+		fatal("concurrent map writes")
+	}
+	h.flags &^= hashWriting
+}
+
+// mapiterinit initializes the hiter struct used for ranging over maps.
+func mapiterinit[K comparable, V any](t *maptype[K], h *hmap[K, V], it *hiter[K, V]) {
+	it.t = t
+	if h == nil || h.count == 0 {
+		return
+	}
+
+	it.h = h
+
+	// grab snapshot of bucket state
+	it.B = h.B
+	it.buckets = h.buckets
+
+	// decide where to start
+	var r uintptr
+	if h.B > 31-t.bucketCntBits {
+		r = uintptr(h.fastrand64())
+	} else {
+		r = uintptr(h.fastrand())
+	}
+	it.startBucket = r & bucketMask(h.B)
+	it.offset = uint8(r >> h.B & (t.bucketCnt - 1))
+
+	// iterator state
+	it.bucket = it.startBucket
+
+	// Remember we have an iterator.
+	// The runtime sets these bits with atomic.Or8 so that concurrent
+	// mapiterinit calls do not race; a plain Go map port cannot.
+	if old := h.flags; old&(iterator|oldIterator) != iterator|oldIterator {
+		h.flags |= iterator | oldIterator
+	}
+
+	mapiternext(it)
+}
+
+func mapiternext[K comparable, V any](it *hiter[K, V]) {
+	h := it.h
+	if h.flags&hashWriting != 0 {
+		fatal("concurrent map iteration and map write")
+	}
+	t := it.t
+	bucket := it.bucket
+	b := it.bptr
+	i := it.i
+	checkBucket := it.checkBucket
+	shrinkNext := it.shrinkNext
+
+next:
+	if b == nil {
+		if bucket == it.startBucket && it.wrapped {
+			// end of iteration
+			it.key = nil
+			it.elem = nil
+			return
+		}
+		// Once maps can shrink, B may come back to it.B with a different
+		// array, so compare the arrays as well.
+		if h.growing() && it.B == h.B && unsafe.SliceData(it.buckets) == unsafe.SliceData(h.buckets) {
+			// Iterator was started in the middle of a grow, and the grow isn't done yet.
+			// If the bucket we're looking at hasn't been filled in yet (i.e. the old
+			// bucket hasn't been evacuated) then we need to iterate through the old
+			// bucket and only return the ones that will be migrated to this bucket.
+			oldbucket := bucket & it.h.oldbucketmask()
+			b = &h.oldbuckets[oldbucket]
+			if !evacuated(b) {
+				checkBucket = bucket
+				if h.shrinking() {
+					// Both old buckets merge into this one, so there is
+					// nothing to skip; iterate the high one next.
+					checkBucket = noCheck
+					shrinkNext = &h.oldbuckets[oldbucket+bucketShift(h.B)]
+				}
+			} else {
+				b = &it.buckets[bucket]
+				checkBucket = noCheck
+			}
+		} else {
+			b = &it.buckets[bucket]
+			checkBucket = noCheck
+		}
+		bucket++
+		if bucket == bucketShift(it.B) {
+			bucket = 0
+			it.wrapped = true
+		}
+		i = 0
+	}
+	for ; i < uint8(t.bucketCnt); i++ {
+		offi := (i + it.offset) & uint8(t.bucketCnt-1)
+		if isEmpty(b.tophash[offi]) || b.tophash[offi] == evacuatedEmpty {
+			// TODO: emptyRest is hard to use here, as we start iterating
+			// in the middle of a bucket. It's feasible, just tricky.
+			continue
+		}
+		k := b.key(uintptr(offi))
+		e := b.elem(uintptr(offi))
+		if checkBucket != noCheck && !h.sameSizeGrow() {
+			// Special case: iterator was started during a grow to a larger size
+			// and the grow is not done yet. We're working on a bucket whose
+			// oldbucket has not been evacuated yet. Or at least, it wasn't
+			// evacuated when we started the bucket. So we're iterating
+			// through the oldbucket, skipping any keys that will go
+			// to the other new bucket (each oldbucket expands to two
+			// buckets during a grow).
+			if t.reflexiveKey || *k == *k {
+				// If the item in the oldbucket is not destined for
+				// the current new bucket in the iteration, skip it.
+				hash := t.hasher(*k, uintptr(h.hash0))
+				if hash&bucketMask(it.B) != checkBucket {
+					continue
+				}
+			} else {
+				// Hash isn't repeatable if k != k (NaNs).  We need a
+				// repeatable and randomish choice of which direction
+				// to send NaNs during evacuation. We'll use the low
+				// bit of tophash to decide which way NaNs go.
+				// NOTE: this case is why we need two evacuate tophash
+				// values, evacuatedX and evacuatedY, that differ in
+				// their low bit.
+				if checkBucket>>(it.B-1) != uintptr(b.tophash[offi]&1) {
+					continue
+				}
+			}
+		}
+		if (b.tophash[offi] != evacuatedX && b.tophash[offi] != evacuatedY) ||
+			!(t.reflexiveKey || *k == *k) {
+			// This is the golden data, we can return it.
+			// OR
+			// key!=key, so the entry can't be deleted or updated, so we can just return it.
+			// That's lucky for us because when key!=key we can't look it up successfully.
+			it.key = k
+			it.elem = e
+		} else {
+			// The hash table has grown since the iterator was started.
+			// The golden data for this key is now somewhere else.
+			// Check the current hash table for the data.
+			// This code handles the case where the key
+			// has been deleted, updated, or deleted and reinserted.
+			// NOTE: we need to regrab the key as it has potentially been
+			// updated to an equal() but not identical key (e.g. +0.0 vs -0.0).
+			rk, re := mapaccessK(t, h, k)
+			if rk == nil {
+				continue // key has been deleted
+			}
+			it.key = rk
+			it.elem = re
+		}
+		it.bucket = bucket
+		it.bptr = b
+		it.i = i + 1
+		it.checkBucket = checkBucket
+		it.shrinkNext = shrinkNext
+		return
+	}
+	b = b.overflow
+	if b == nil && shrinkNext != nil {
+		b, shrinkNext = shrinkNext, nil
+	}
+	i = 0
+	goto next
+}
+
+// mapclear deletes all keys from a map.
+func mapclear[K comparable, V any](t *maptype[K], h *hmap[K, V]) {
+	if h == nil || h.count == 0 {
+		return
+	}
+
+	if h.flags&hashWriting != 0 {
+		fatal("concurrent map writes")
+	}
+
+	h.flags ^= hashWriting
+	if h.trace != nil {
+		h.trace.begin("mapclear")
+		if h.growing() {
+			h.trace.emit(&Release{B: h.B, Writes: h.trace.seq - h.trace.growSeq + 1, Clear: true})
+		}
+	}
+
+	// Mark buckets empty, so existing iterators can be terminated, see issue #59411.
+	markBucketsEmpty := func(bucket []bmap[K, V], mask uintptr) {
+		for i := uintptr(0); i <= mask; i++ {
+			for b := &bucket[i]; b != nil; b = b.overflow {
+				for i := uintptr(0); i < t.bucketCnt; i++ {
+					b.tophash[i] = emptyRest
+				}
+			}
+		}
+	}
+	markBucketsEmpty(h.buckets, bucketMask(h.B))
+	if oldBuckets := h.oldbuckets; oldBuckets != nil {
+		markBucketsEmpty(oldBuckets, h.oldbucketmask())
+	}
+
+	h.flags &^= sameSizeGrow | shrinking
+	releaseArray(t, h.oldbuckets)
+	h.oldbuckets = nil
+	h.nevacuate = 0
+	h.noverflow = 0
+	h.count = 0
+
+	// Reset the hash seed to make it more difficult for attackers to
+	// repeatedly trigger hash collisions. See issue 25237.
+	h.hash0 = h.fastrand()
+
+	// Keep the mapextra allocation but clear any extra information.
+	if h.extra != nil {
+		*h.extra = mapextra[K, V]{}
+	}
+
+	// makeBucketArray clears the memory pointed to by h.buckets
+	// and recovers any overflow buckets by generating them
+	// as if h.buckets was newly alloced.
+	// A map that may shrink gives the array up instead and starts over
+	// at the size of its hint.
+	var nextOverflow *bmap[K, V]
+	if h.shrink > 0 && h.B > h.minB {
+		h.B = h.minB
+		releaseArray(t, h.buckets)
+		h.buckets, nextOverflow = makeBucketArray[K, V](t, h.B, nil)
+		h.countArray(t, len(h.buckets))
+	} else {
+		_, nextOverflow = makeBucketArray(t, h.B, h.buckets)
+	}
+	if nextOverflow != nil {
+		// If overflow buckets are created then h.extra
+		// will have been allocated during initial bucket creation.
+		h.extra.nextOverflow = nextOverflow
+	}
+
+	if h.flags&hashWriting == 0 {
+		fatal("concurrent map writes")
+	}
+	h.flags &^= hashWriting
+}
+
+func hashGrow[K comparable, V any](t *maptype[K], h *hmap[K, V]) {
+	// If we've hit the load factor, get bigger.
+	// Otherwise, there are too many overflow buckets,
+	// so keep the same number of buckets and "grow" laterally.
+	bigger := uint8(1)
+	if !overLoadFactor(t, h.count+1, h.B) {
+		bigger = 0
+		h.flags |= sameSizeGrow
+	}
+	oldbuckets := h.buckets
+	noverflow := h.noverflow
+	newbuckets, nextOverflow := makeBucketArray[K, V](t, h.B+bigger, nil)
+	h.countArray(t, len(newbuckets))
+
+	flags := h.flags &^ (iterator | oldIterator)
+	if h.flags&iterator != 0 {
+		flags |= oldIterator
+	}
+	// commit the grow (atomic wrt gc)
+	h.B += bigger
+	h.flags = flags
+	h.oldbuckets = oldbuckets
+	h.buckets = newbuckets
+	h.nevacuate = 0
+	h.noverflow = 0
+
+	if nextOverflow != nil {
+		if h.extra == nil {
+			h.extra = new(mapextra[K, V])
+		}
+		h.extra.nextOverflow = nextOverflow
+	}
+
+	if h.trace != nil {
+		h.trace.growSeq = h.trace.seq
+		h.trace.emit(&GrowStart{OldB: h.B - bigger, B: h.B, SameSize: bigger == 0, Count: h.count, NOverflow: noverflow})
+	}
+
+	// the actual copying of the hash table data is done incrementally
+	// by growWork() and evacuate().
+}
+
+// overLoadFactor reports whether count items placed in 1<<B buckets is over loadFactor.
+// The runtime divides 1<<B by loadFactorDen first; cross-multiplying gives
+// the same answer for its constants and stays exact for any other den.
+func overLoadFactor[K comparable](t *maptype[K], count int, B uint8) bool {
+	return count > int(t.bucketCnt) && uintptr(count)*t.loadFactorDen > t.loadFactorNum*bucketShift(B)
+}
+
+// tooManyOverflowBuckets reports whether noverflow buckets is too many for a map with 1<<B buckets.
+// Note that most of these overflow buckets must be in sparse use;
+// if use was dense, then we'd have already triggered regular map growth.
+func tooManyOverflowBuckets(noverflow uint16, B uint8) bool {
+	// If the threshold is too low, we do extraneous work.
+	// If the threshold is too high, maps that grow and shrink can hold on to lots of unused memory.
+	// "too many" means (approximately) as many overflow buckets as regular buckets.
+	// See incrnoverflow for more details.
+	if B > 15 {
+		B = 15
+	}
+	// The compiler doesn't see here that B < 16; mask B to generate shorter shift code.
+	return noverflow >= uint16(1)<<(B&15)
+}
+
+// growing reports whether h is growing. The growth may be to the same size or bigger.
+func (h *hmap[K, V]) growing() bool {
+	return h.oldbuckets != nil
+}
+
+// sameSizeGrow reports whether the current growth is to a map of the same size.
+func (h *hmap[K, V]) sameSizeGrow() bool {
+	return h.flags&sameSizeGrow != 0
+}
+
+// noldbuckets calculates the number of buckets prior to the current map growth.
+func (h *hmap[K, V]) noldbuckets() uintptr {
+	oldB := h.B
+	if h.shrinking() {
+		oldB++
+	} else if !h.sameSizeGrow() {
+		oldB--
+	}
+	return bucketShift(oldB)
+}
+
+// oldbucketmask provides a mask that can be applied to calculate n % noldbuckets().
+func (h *hmap[K, V]) oldbucketmask() uintptr {
+	return h.noldbuckets() - 1
+}
+
+func growWork[K comparable, V any](t *maptype[K], h *hmap[K, V], bucket uintptr) {
+	// make sure we evacuate the oldbucket corresponding
+	// to the bucket we're about to use
+	evacuate(t, h, bucket&h.oldbucketmask())
+
+	// evacuate one more oldbucket to make progress on growing
+	if h.growing() {
+		evacuate(t, h, h.nevacuate)
+	}
+}
+
+func bucketEvacuated[K comparable, V any](t *maptype[K], h *hmap[K, V], bucket uintptr) bool {
+	return evacuated(&h.oldbuckets[bucket])
+}
+
+// evacDst is an evacuation destination.
+// The runtime also caches pointers to the next key and elem slot; with
+// slices the slot index i is enough.
+type evacDst[K comparable, V any] struct {
+	b *bmap[K, V] // current destination bucket
+	i int         // key/elem index into b
+}
+
+func evacuate[K comparable, V any](t *maptype[K], h *hmap[K, V], oldbucket uintptr) {
+	if h.shrinking() {
+		evacuateShrink(t, h, oldbucket)
+		return
+	}
+	b := &h.oldbuckets[oldbucket]
+	newbit := h.noldbuckets()
+	if !evacuated(b) {
+		// TODO: reuse overflow buckets instead of using new ones, if there
+		// is no iterator using the old buckets.  (If !oldIterator.)
+
+		// xy contains the x and y (low and high) evacuation destinations.
+		var xy [2]evacDst[K, V]
+		var moved [2]int // entries sent to x and y, for the tracer
+		x := &xy[0]
+		x.b = &h.buckets[oldbucket]
+
+		if !h.sameSizeGrow() {
+			// Only calculate y pointers if we're growing bigger.
+			// Otherwise GC can see bad pointers.
+			y := &xy[1]
+			y.b = &h.buckets[oldbucket+newbit]
+		}
+
+		for ; b != nil; b = b.overflow {
+			for i := 0; i < int(t.bucketCnt); i++ {
+				top := b.tophash[i]
+				if isEmpty(top) {
+					b.tophash[i] = evacuatedEmpty
+					continue
+				}
+				if top < minTopHash {
+					throw("bad map state")
+				}
+				k := b.key(uintptr(i))
+				var useY uint8
+				if !h.sameSizeGrow() {
+					// Compute hash to make our evacuation decision (whether we need
+					// to send this key/elem to bucket x or bucket y).
+					hash := t.hasher(*k, uintptr(h.hash0))
+					if h.flags&iterator != 0 && !t.reflexiveKey && *k != *k {
+						// If key != key (NaNs), then the hash could be (and probably
+						// will be) entirely different from the old hash. Moreover,
+						// it isn't reproducible. Reproducibility is required in the
+						// presence of iterators, as our evacuation decision must
+						// match whatever decision the iterator made.
+						// Fortunately, we have the freedom to send these keys either
+						// way. Also, tophash is meaningless for these kinds of keys.
+						// We let the low bit of tophash drive the evacuation decision.
+						// We recompute a new random tophash for the next level so
+						// these keys will get evenly distributed across all buckets
+						// after multiple grows.
+						useY = top & 1
+						top = tophash(hash)
+					} else {
+						if hash&newbit != 0 {
+							useY = 1
+						}
+					}
+				}
+
+				if evacuatedX+1 != evacuatedY || evacuatedX^1 != evacuatedY {
+					throw("bad evacuatedN")
+				}
+
+				b.tophash[i] = evacuatedX + useY // evacuatedX + 1 == evacuatedY
+				dst := &xy[useY]                 // evacuation destination
+
+				if dst.i == int(t.bucketCnt) {
+					dst.b = h.newoverflow(t, dst.b)
+					dst.i = 0
+				}
+				dst.b.tophash[dst.i] = top
+				dst.b.moveCell(uintptr(dst.i), b, uintptr(i))
+				dst.i++
+				moved[useY]++
+			}
+		}
+		if h.trace != nil {
+			h.trace.emit(&Evacuate{Bucket: oldbucket, X: moved[0], Y: moved[1]})
+		}
+		// Unlink the overflow buckets & clear key/elem to help GC.
+		if h.flags&oldIterator == 0 && t.bucketPtrs {
+			b := &h.oldbuckets[oldbucket]
+			// Preserve b.tophash because the evacuation
+			// state is maintained there.
+			b.clearCells()
+			b.overflow = nil
+		}
+	}
+
+	if oldbucket == h.nevacuate {
+		advanceEvacuationMark(h, t, newbit)
+	}
+}
+
+func advanceEvacuationMark[K comparable, V any](h *hmap[K, V], t *maptype[K], newbit uintptr) {
+	from := h.nevacuate
+	h.nevacuate++
+	// Experiments suggest that 1024 is overkill by at least an order of magnitude.
+	// Put it in there as a safeguard anyway, to ensure O(1) behavior.
+	stop := h.nevacuate + 1024
+	if stop > newbit {
+		stop = newbit
+	}
+	for h.nevacuate != stop && bucketEvacuated(t, h, h.nevacuate) {
+		h.nevacuate++
+	}
+	if h.trace != nil {
+		h.trace.emit(&EvacuationMark{From: from, To: h.nevacuate, Of: newbit})
+	}
+	if h.nevacuate == newbit { // newbit == # of oldbuckets
+		// Growing is all done. Free old main bucket array.
+		releaseArray(t, h.oldbuckets)
+		h.oldbuckets = nil
+		h.flags &^= sameSizeGrow | shrinking
+		if h.trace != nil {
+			h.trace.emit(&Release{B: h.B, Writes: h.trace.seq - h.trace.growSeq + 1})
+		}
+	}
+}
+
+// fastrand and fastrand64 stand in for the runtime's per-M generator.
+// A map with its own source (WithRand, WithSeed or HMAPSEED) draws from
+// it instead, so its hash seeds, iteration order and noverflow sampling
+// repeat from run to run.
+// (设置了随机源的 map 从自己的随机源取数，hash0、遍历起点等每次运行都一样)
+func (h *hmap[K, V]) fastrand() uint32 {
+	if h.rand != nil {
+		return uint32(h.rand.Uint64())
+	}
+	return rtalg.Fastrand()
+}
+
+func (h *hmap[K, V]) fastrand64() uint64 {
+	if h.rand != nil {
+		return h.rand.Uint64()
+	}
+	return rtalg.Fastrand64()
+}
+
+// A plainError is the panic value for misuse of a map, like runtime.plainError.
+type plainError string
+
+func (e plainError) Error() string { return string(e) }
+
+// fatal reports a map misuse the runtime would treat as unrecoverable
+// ("concurrent map writes"). Here it is a panic so that tests can observe it.
+func fatal(s string) {
+	panic(plainError(s))
+}
+
+// throw reports a broken internal invariant.
+func throw(s string) {
+	panic(plainError("hmap: " + s))
+}
@@ -0,0 +1,200 @@
+package hmap
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// entries returns the entries of a range loop over m, keyed by the bits
+// of the key so that -0 and NaN count, with how often each came up.
+func entries(m *Map[float64, int]) map[[2]uint64]int {
+	got := make(map[[2]uint64]int)
+	m.Range(func(k float64, v int) bool {
+		got[[2]uint64{math.Float64bits(k), uint64(v)}]++
+		return true
+	})
+	return got
+}
+
+func builtinEntries(model map[float64]int) map[[2]uint64]int {
+	want := make(map[[2]uint64]int)
+	for k, v := range model {
+		want[[2]uint64{math.Float64bits(k), uint64(v)}]++
+	}
+	return want
+}
+
+func checkSame(t *testing.T, step int, m *Map[float64, int], model map[float64]int) {
+	t.Helper()
+	if m.Len() != len(model) {
+		t.Fatalf("step %d: Len() = %d, builtin len %d", step, m.Len(), len(model))
+	}
+	got, want := entries(m), builtinEntries(model)
+	if len(got) != len(want) {
+		t.Fatalf("step %d: range produced %d distinct entries, builtin %d", step, len(got), len(want))
+	}
+	for e, n := range want {
+		if got[e] != n {
+			k := math.Float64frombits(e[0])
+			t.Fatalf("step %d: range produced %v: %d %d times, builtin %d", step, k, e[1], got[e], n)
+		}
+	}
+	if err := m.Validate(); err != nil {
+		t.Fatalf("step %d: %v", step, err)
+	}
+}
+
+// TestDifferential runs random writes against a Map and a builtin map
+// and compares them after each one, in the middle of incremental growth
+// as much as outside it.
+func TestDifferential(t *testing.T) {
+	configs := []struct {
+		name string
+		opts []Option
+	}{
+		{"default", nil},
+		{"bucketcnt4", []Option{WithBucketCnt(4)}},
+		{"shrink", []Option{WithShrink(0.25)}},
+		{"indirect", []Option{WithIndirect(0, 0)}},
+	}
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			r := rand.New(rand.NewPCG(1, 2))
+			m := New[float64, int](0, append(c.opts, WithSeed(3))...)
+			model := make(map[float64]int)
+			grew := 0
+			for i := range 20000 {
+				k := float64(r.IntN(600))
+				switch x := r.IntN(1000); {
+				case x < 5:
+					k = math.NaN()
+				case x < 15:
+					k = math.Copysign(0, -1)
+				}
+				switch x := r.IntN(100); {
+				case x < 55:
+					m.Put(k, i)
+					model[k] = i
+				case x < 85:
+					m.Delete(k)
+					delete(model, k)
+				case x < 99:
+					got, ok := m.Lookup(k)
+					want, wok := model[k]
+					if got != want || ok != wok {
+						t.Fatalf("step %d: m[%v] = %d, %v; builtin %d, %v", i, k, got, ok, want, wok)
+					}
+				case i%3 == 0:
+					m.Clear()
+					clear(model)
+				default:
+					cm := m.Clone()
+					checkSame(t, i, cm, model)
+					m = cm
+				}
+				if m.Growing() {
+					grew++
+				}
+				if i%50 == 0 || m.Growing() {
+					checkSame(t, i, m, model)
+				}
+			}
+			if grew == 0 {
+				t.Error("the map never grew incrementally")
+			}
+		})
+	}
+}
+
+// TestNegativeZero checks that putting -0 over +0 replaces the key, as
+// it does in the builtin map (needKeyUpdate), also across an evacuation.
+func TestNegativeZero(t *testing.T) {
+	m := New[float64, int](0, WithBucketCnt(4))
+	m.Put(0, 1)
+	m.Put(math.Copysign(0, -1), 2)
+	for i := 1; !m.Growing(); i++ {
+		m.Put(float64(i), i)
+	}
+	for i := 1000; m.Growing(); i++ {
+		m.Put(float64(i), i)
+	}
+	n := 0
+	m.Range(func(k float64, v int) bool {
+		if k == 0 {
+			n++
+			if !math.Signbit(k) || v != 2 {
+				t.Errorf("key %v: %d, want -0: 2", k, v)
+			}
+		}
+		return true
+	})
+	if n != 1 {
+		t.Errorf("%d zero keys, want 1", n)
+	}
+}
+
+// TestNaN checks that every NaN is a new key that no lookup finds and
+// no delete removes, and that Clear drops them all.
+func TestNaN(t *testing.T) {
+	m := New[float64, int](0)
+	for i := range 100 {
+		m.Put(math.NaN(), i)
+	}
+	m.Delete(math.NaN())
+	if m.Len() != 100 {
+		t.Fatalf("Len() = %d, want 100", m.Len())
+	}
+	if _, ok := m.Lookup(math.NaN()); ok {
+		t.Error("Lookup(NaN) found an entry")
+	}
+	seen := make(map[int]bool)
+	m.Range(func(k float64, v int) bool {
+		if !math.IsNaN(k) || seen[v] {
+			t.Errorf("range produced %v: %d", k, v)
+		}
+		seen[v] = true
+		return true
+	})
+	if len(seen) != 100 {
+		t.Errorf("range produced %d entries, want 100", len(seen))
+	}
+	m.Clear()
+	if m.Len() != 0 {
+		t.Errorf("Len() after Clear = %d", m.Len())
+	}
+}
+
+// TestRangeWhileGrowing deletes and inserts during a range loop that
+// starts in the middle of a growth: every entry present throughout must
+// come up exactly once, and no deleted entry may.
+func TestRangeWhileGrowing(t *testing.T) {
+	m := New[float64, int](0, WithSeed(5))
+	for i := 0; !m.Growing(); i++ {
+		m.Put(float64(i), i)
+	}
+	n := m.Len()
+	seen := make(map[float64]int)
+	m.Range(func(k float64, v int) bool {
+		seen[k]++
+		if len(seen) > 1 && int(k) < n && int(k)%2 == 1 {
+			t.Errorf("range produced %v after it was deleted", k)
+		}
+		if len(seen) == 1 {
+			for i := 1; i < n; i += 2 {
+				if float64(i) != k {
+					m.Delete(float64(i))
+				}
+			}
+			for i := range 100 {
+				m.Put(float64(n+i), 0)
+			}
+		}
+		return true
+	})
+	for i := 0; i < n; i += 2 {
+		if seen[float64(i)] != 1 {
+			t.Errorf("key %d produced %d times", i, seen[float64(i)])
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package hmap
+
+import (
+	"math/rand/v2"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hasher"
+	"github.com/ProsperousLi/golang-deep-learn/map/internal/mapopt"
+)
+
+// An Option configures a Map created by New.
+type Option func(*config)
+
+// config collects the options passed to New.
+type config struct {
+	tracer Tracer
+	probe  Probe
+	rand   rand.Source
+	hasher hasher.Hasher
+	shrink float64
+
+	bucketCnt                    int // 0 for the default
+	loadFactorNum, loadFactorDen int // 0/0 for the default
+
+	indirect                bool // WithIndirect was given
+	maxKeySize, maxElemSize int
+
+	arena    bool // WithArena was given
+	slabSize int
+}
+
+func newConfig(opts []Option) *config {
+	c := new(config)
+	if seed, ok := mapopt.EnvSeed(); ok {
+		c.rand = rand.NewPCG(seed, 0)
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// WithTracer reports the growth events of the map to t. See Tracer.
+// Clones of the map are not traced.
+func WithTracer(t Tracer) Option {
+	return func(c *config) { c.tracer = t }
+}
+
+// WithShrink lets the map give memory back: when a delete leaves fewer
+// than frac*loadFactor*2^B entries, the bucket array is halved, merging
+// buckets incrementally on later writes as a grow does. The map never
+// shrinks below the size of its hint, and Clear drops back to that size.
+//
+// frac must be in (0, 0.25]. The bound keeps a halved map at most half
+// full when the shrink starts, with room for the writes that complete it.
+func WithShrink(frac float64) Option {
+	if !(frac > 0 && frac <= 0.25) {
+		panic(plainError("hmap: WithShrink fraction out of range (0, 0.25]"))
+	}
+	return func(c *config) { c.shrink = frac }
+}
+
+// WithBucketCnt sets the number of key/elem pairs a bucket holds, the
+// runtime's bucketCnt: 4, 8 (the default), 16 or 32. Smaller buckets scan
+// fewer tophash bytes per probe; larger ones need fewer overflow buckets
+// at the same load. Unless WithLoadFactor is given too, the load factor
+// stays at 13/16 of a bucket, rounded down as the runtime's constant is.
+func WithBucketCnt(n int) Option {
+	switch n {
+	case 4, 8, 16, 32:
+	default:
+		panic(plainError("hmap: WithBucketCnt must be 4, 8, 16 or 32"))
+	}
+	return func(c *config) { c.bucketCnt = n }
+}
+
+// WithLoadFactor sets the average number of entries per bucket, num/den,
+// above which the map doubles: the runtime's loadFactorNum/loadFactorDen,
+// 12/2 by default. A lower load factor trades memory for shorter probe
+// sequences, a higher one the other way round. num/den must not exceed
+// the bucket count; New panics otherwise.
+func WithLoadFactor(num, den int) Option {
+	if num <= 0 || den <= 0 {
+		panic(plainError("hmap: WithLoadFactor needs a positive num and den"))
+	}
+	return func(c *config) { c.loadFactorNum, c.loadFactorDen = num, den }
+}
+
+// WithIndirect sets the sizes above which keys and elems are stored out
+// of line, the runtime's maxKeySize and maxElemSize (128 bytes each). A
+// bucket then holds a pointer per cell, and every insert of a new key
+// allocates the key and the elem separately. Zero stores every non-empty
+// type indirectly; a size at least as large as the type never does. See
+// MemStats for what either choice costs.
+func WithIndirect(maxKeySize, maxElemSize int) Option {
+	if maxKeySize < 0 || maxElemSize < 0 {
+		panic(plainError("hmap: WithIndirect needs non-negative sizes"))
+	}
+	return func(c *config) {
+		c.indirect = true
+		c.maxKeySize, c.maxElemSize = maxKeySize, maxElemSize
+	}
+}
+
+// WithArena allocates the map's buckets, overflow buckets and cells from
+// slabs of slabSize bytes (1 MiB if slabSize is 0) that the GC does not
+// scan, instead of from the GC heap; see Map.Free and Map.ArenaStats.
+// Bucket arrays of half a slab or more get a slab each, returned to the
+// GC when the grow that replaced them is done. Everything else stays in
+// the arena until Free. K and V must not contain pointers; New panics
+// otherwise, and NewOrdered always does. A clone gets an arena of its
+// own.
+func WithArena(slabSize int) Option {
+	return func(c *config) { c.arena, c.slabSize = true, slabSize }
+}
+
+// WithHasher replaces memhash with h as the hash of the key's memory.
+// Strings hash their bytes, floats keep the +0 == -0 and random-NaN
+// rules, and interfaces, arrays and structs chain h over their parts,
+// so every key type goes through h while the bucket logic stays the same.
+func WithHasher(h hasher.Hasher) Option {
+	return func(c *config) { c.hasher = h }
+}
+
+// WithRand makes src the map's only source of randomness: hash0 at
+// creation and whenever mapclear or the last mapdelete reseeds it, the
+// starting bucket and offset of every iteration, the sampling in
+// incrnoverflow and the hashes of NaN keys. With a deterministic src the
+// same sequence of operations produces the same bucket layout and the
+// same iteration order on every run. Clones share src.
+//
+// The map calls src without locking; do not share one src between maps
+// used from different goroutines.
+func WithRand(src rand.Source) Option {
+	return func(c *config) { c.rand = src }
+}
+
+// WithSeed is WithRand with a PCG generator seeded by seed.
+func WithSeed(seed uint64) Option {
+	return WithRand(rand.NewPCG(seed, 0))
+}
+
+// SeedEnv names the environment variable that puts every map created
+// without WithRand or WithSeed into deterministic mode: HMAPSEED=n acts
+// like WithSeed(n) on each New, so a program can be rerun bit-for-bit
+// without changing its code. It is read once, when the first map of this
+// package or of package swiss is created; an unparsable value panics
+// then rather than silently running with random seeds.
+const SeedEnv = mapopt.SeedEnv
@@ -0,0 +1,85 @@
+package hmap
+
+import (
+	"math"
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+// seeded runs the same writes, NaN keys, a Clear and a delete of the
+// last entry among them, on m and returns what they leave behind: the
+// Layout and the order of a range loop, as key bits, after each phase.
+func seeded(m *Map[float64, int]) (layouts []*Layout, orders [][]uint64) {
+	snap := func() {
+		layouts = append(layouts, m.Layout())
+		var order []uint64
+		m.Range(func(k float64, v int) bool {
+			order = append(order, math.Float64bits(k))
+			return true
+		})
+		orders = append(orders, order)
+	}
+	for i := range 300 {
+		m.Put(float64(i), i)
+		if i%7 == 0 {
+			m.Put(math.NaN(), i)
+		}
+		if i%3 == 0 {
+			m.Delete(float64(i / 2))
+		}
+		if i%50 == 0 {
+			snap()
+		}
+	}
+	m.Clear()
+	m.Put(1, 1)
+	snap()
+	m.Delete(1)
+	m.Put(2, 2)
+	snap()
+	return layouts, orders
+}
+
+// TestSeedRepeats checks that two maps with the same seed go through the
+// same layouts, bit for bit, and iterate in the same order, and that
+// another seed changes them.
+func TestSeedRepeats(t *testing.T) {
+	la, oa := seeded(New[float64, int](0, WithSeed(1)))
+	lb, ob := seeded(New[float64, int](0, WithSeed(1)))
+	for i := range la {
+		if !reflect.DeepEqual(la[i], lb[i]) {
+			t.Errorf("phase %d: layouts differ:\n%+v\n%+v", i, la[i], lb[i])
+		}
+		if !reflect.DeepEqual(oa[i], ob[i]) {
+			t.Errorf("phase %d: range orders differ:\n%v\n%v", i, oa[i], ob[i])
+		}
+	}
+	lc, _ := seeded(New[float64, int](0, WithSeed(2)))
+	if lc[0].Hash0 == la[0].Hash0 || reflect.DeepEqual(lc[0], la[0]) {
+		t.Error("seeds 1 and 2 give the same layout")
+	}
+}
+
+// TestSeedEnv runs itself with HMAPSEED=7 and checks there that every
+// New acts like WithSeed(7), even after the variable changes: it is read
+// once.
+func TestSeedEnv(t *testing.T) {
+	if os.Getenv("HMAP_TEST_SEEDENV") != "" {
+		want, _ := seeded(New[float64, int](0, WithSeed(7)))
+		for i := range 2 {
+			got, _ := seeded(New[float64, int](0))
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("map %d with %s=%s: layouts differ from WithSeed(7)", i, SeedEnv, os.Getenv(SeedEnv))
+			}
+			os.Setenv(SeedEnv, "8")
+		}
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSeedEnv$")
+	cmd.Env = append(os.Environ(), "HMAP_TEST_SEEDENV=1", SeedEnv+"=7")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+}
@@ -0,0 +1,269 @@
+package hmap
+
+import (
+	"github.com/ProsperousLi/golang-deep-learn/map/internal/mapopt"
+	"github.com/ProsperousLi/golang-deep-learn/map/internal/rtalg"
+	"github.com/ProsperousLi/golang-deep-learn/map/mapapi"
+)
+
+// OrderedMap is a Map that remembers the order in which keys were first
+// inserted. Lookups go through the same buckets and tophash bytes as Map;
+// the element stored in each cell is a pointer to an entry, and the
+// entries form a doubly linked list in insertion order. Evacuation moves
+// the pointers, never the entries, so the order survives hashGrow (and
+// shrinking) unchanged, and Range, AppendKeys and AppendValues return
+// entries in that order instead of the randomized order of mapiternext.
+/*
+	OrderedMap 保持插入顺序：查找仍走 bmap/tophash 的路径，桶里存的是指向 entry 的指针，
+	entry 之间用双向链表按插入顺序串起来。搬迁（evacuate）只移动指针，链表不受扩容/收缩影响，
+	遍历按链表顺序进行，适合需要稳定输出顺序的场景（配置渲染、按插入顺序输出 JSON）。
+	删除和 MoveToFront/MoveToBack 都是 O(1)。
+*/
+type OrderedMap[K comparable, V any] struct {
+	t    *maptype[K]
+	h    *hmap[K, *entry[K, V]]
+	root entry[K, V] // sentinel: root.next is the oldest entry, root.prev the newest
+}
+
+// entry is one key/elem pair of an OrderedMap.
+type entry[K comparable, V any] struct {
+	next, prev *entry[K, V]
+	key        K
+	elem       V
+
+	// removed is set when the entry leaves the list. A removed entry
+	// keeps its next pointer, so that a Range which has saved it as the
+	// next entry to visit can still find its way back into the list.
+	removed bool
+}
+
+// NewOrdered returns an empty OrderedMap with room for about hint
+// elements, configured by opts as New is. WithArena is the exception:
+// every cell holds a pointer to its entry, which the GC must see, so
+// NewOrdered panics if it is given.
+func NewOrdered[K comparable, V any](hint int, opts ...Option) *OrderedMap[K, V] {
+	c := newConfig(opts)
+	h := &hmap[K, *entry[K, V]]{rand: c.rand, shrink: c.shrink}
+	t := newMaptype[K, *entry[K, V]](rtalg.Alg{Mem: mapopt.Memhash(c.hasher), Rand: h.fastrand}, c.geometry())
+	if c.arena {
+		panic(plainError("hmap: WithArena does not work with NewOrdered, whose cells point to the entries"))
+	}
+	makemap(t, hint, h)
+	h.minB = h.B
+	if c.tracer != nil {
+		h.trace = &tracer{t: c.tracer}
+	}
+	if c.probe != nil {
+		h.probe = &prober{p: c.probe}
+	}
+	m := &OrderedMap[K, V]{t: t, h: h}
+	m.root.next, m.root.prev = &m.root, &m.root
+	return m
+}
+
+// insertBefore links e in front of at.
+func (m *OrderedMap[K, V]) insertBefore(e, at *entry[K, V]) {
+	e.prev, e.next = at.prev, at
+	at.prev.next = e
+	at.prev = e
+}
+
+// unlink removes e from the list, keeping e.next for Range.
+func (m *OrderedMap[K, V]) unlink(e *entry[K, V]) {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	e.prev = nil
+}
+
+// Len returns the number of elements.
+func (m *OrderedMap[K, V]) Len() int {
+	return m.h.count
+}
+
+// Growing reports whether an incremental grow or shrink is in progress.
+func (m *OrderedMap[K, V]) Growing() bool {
+	return m.h.growing()
+}
+
+// Get returns the element for key, or the zero value.
+func (m *OrderedMap[K, V]) Get(key K) V {
+	v, _ := m.Lookup(key)
+	return v
+}
+
+// Lookup is the comma-ok form of Get.
+func (m *OrderedMap[K, V]) Lookup(key K) (V, bool) {
+	if p := mapaccess1(m.t, m.h, key); p != nil {
+		return (*p).elem, true
+	}
+	var zero V
+	return zero, false
+}
+
+// Put sets the element for key. A new key goes to the back of the order;
+// an existing key keeps its position. As mapassign does for the key in
+// the bucket, Put overwrites the key of the entry when t.needKeyUpdate,
+// so that putting -0 over +0 leaves -0 in the order too.
+func (m *OrderedMap[K, V]) Put(key K, elem V) {
+	p := mapassign(m.t, m.h, key)
+	if *p == nil {
+		*p = &entry[K, V]{key: key}
+		m.insertBefore(*p, &m.root)
+	} else if m.t.needKeyUpdate {
+		(*p).key = key
+	}
+	(*p).elem = elem
+	m.debugCheck()
+}
+
+// Delete removes key.
+func (m *OrderedMap[K, V]) Delete(key K) {
+	p := mapaccess1(m.t, m.h, key)
+	if p == nil {
+		return
+	}
+	e := *p
+	mapdelete(m.t, m.h, key)
+	m.unlink(e)
+	e.removed = true
+	m.debugCheck()
+}
+
+// MoveToFront makes key the oldest entry. It reports whether key is in m.
+func (m *OrderedMap[K, V]) MoveToFront(key K) bool {
+	return m.move(key, m.root.next)
+}
+
+// MoveToBack makes key the newest entry, as if it had been deleted and
+// inserted again. It reports whether key is in m.
+func (m *OrderedMap[K, V]) MoveToBack(key K) bool {
+	return m.move(key, &m.root)
+}
+
+// move relinks the entry of key in front of at.
+func (m *OrderedMap[K, V]) move(key K, at *entry[K, V]) bool {
+	p := mapaccess1(m.t, m.h, key)
+	if p == nil {
+		return false
+	}
+	if e := *p; e != at {
+		m.unlink(e)
+		m.insertBefore(e, at)
+	}
+	m.debugCheck()
+	return true
+}
+
+// Clear removes every element but keeps the bucket array.
+func (m *OrderedMap[K, V]) Clear() {
+	for e := m.root.next; e != &m.root; e = e.next {
+		e.removed = true
+	}
+	m.root.next, m.root.prev = &m.root, &m.root
+	mapclear(m.t, m.h)
+	m.debugCheck()
+}
+
+// Clone returns a copy of m with the same order. Unlike Map.Clone it
+// rebuilds the buckets with mapassign, since every entry must be copied
+// anyway; the clone is not traced.
+func (m *OrderedMap[K, V]) Clone() *OrderedMap[K, V] {
+	src := m.h
+	h := &hmap[K, *entry[K, V]]{rand: src.rand, shrink: src.shrink, minB: src.minB}
+	makemap(m.t, src.count, h)
+	c := &OrderedMap[K, V]{t: m.t, h: h}
+	c.root.next, c.root.prev = &c.root, &c.root
+	for e := m.root.next; e != &m.root; e = e.next {
+		n := &entry[K, V]{key: e.key, elem: e.elem}
+		*mapassign(c.t, c.h, e.key) = n
+		c.insertBefore(n, &c.root)
+	}
+	c.debugCheck()
+	return c
+}
+
+// Validate checks the buckets as Map.Validate does, and that the order
+// list holds exactly the entries stored in them.
+func (m *OrderedMap[K, V]) Validate() error {
+	if err := validate(m.t, m.h); err != nil {
+		return err
+	}
+	n := 0
+	for e := m.root.next; e != &m.root; e = e.next {
+		if e.removed || e.next.prev != e {
+			return validateError("order list is broken at key %v", e.key)
+		}
+		if p := mapaccess1(m.t, m.h, e.key); e.key == e.key && (p == nil || *p != e) {
+			return validateError("key %v is in the order list but not stored", e.key)
+		}
+		if n++; n > m.h.count {
+			return validateError("order list is longer than count %d", m.h.count)
+		}
+	}
+	if n != m.h.count {
+		return validateError("order list has %d entries, count is %d", n, m.h.count)
+	}
+	return nil
+}
+
+// debugCheck is debugCheck for the buckets and the order list.
+func (m *OrderedMap[K, V]) debugCheck() {
+	if debugValidate {
+		if err := m.Validate(); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Range calls f for each key and element in insertion order until f
+// returns false. As with a range loop over a builtin map, f may insert
+// and delete entries: an entry deleted before it is reached is not
+// visited, and entries inserted during Range are, since they go to the
+// back. An entry that f moves with MoveToFront or MoveToBack may be
+// skipped or visited twice.
+func (m *OrderedMap[K, V]) Range(f func(key K, elem V) bool) {
+	for e := m.root.next; e != &m.root; {
+		next := e.next
+		if !f(e.key, e.elem) {
+			return
+		}
+		for next.removed {
+			next = next.next
+		}
+		e = next
+	}
+}
+
+// AppendKeys appends the keys of m to s in insertion order.
+func (m *OrderedMap[K, V]) AppendKeys(s []K) []K {
+	for e := m.root.next; e != &m.root; e = e.next {
+		s = append(s, e.key)
+	}
+	return s
+}
+
+// AppendValues appends the elements of m to s in insertion order.
+func (m *OrderedMap[K, V]) AppendValues(s []V) []V {
+	for e := m.root.next; e != &m.root; e = e.next {
+		s = append(s, e.elem)
+	}
+	return s
+}
+
+// Oldest returns the first key in insertion order and its element.
+func (m *OrderedMap[K, V]) Oldest() (key K, elem V, ok bool) {
+	if e := m.root.next; e != &m.root {
+		return e.key, e.elem, true
+	}
+	return key, elem, false
+}
+
+// Newest returns the last key in insertion order and its element.
+func (m *OrderedMap[K, V]) Newest() (key K, elem V, ok bool) {
+	if e := m.root.prev; e != &m.root {
+		return e.key, e.elem, true
+	}
+	return key, elem, false
+}
+
+var _ mapapi.Map[int, int] = (*OrderedMap[int, int])(nil)
@@ -0,0 +1,227 @@
+package hmap
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// checkOrder compares every way of reading m in order with keys, the
+// expected insertion order, and with elems.
+func checkOrder(t *testing.T, step int, m *OrderedMap[int, int], keys []int, elems map[int]int) {
+	t.Helper()
+	if m.Len() != len(keys) {
+		t.Fatalf("step %d: Len() = %d, want %d", step, m.Len(), len(keys))
+	}
+	var got []int
+	m.Range(func(k, v int) bool {
+		got = append(got, k)
+		if v != elems[k] {
+			t.Fatalf("step %d: range produced %d: %d, want %d", step, k, v, elems[k])
+		}
+		return true
+	})
+	if !slices.Equal(got, keys) {
+		t.Fatalf("step %d: range order\n%v\nwant\n%v", step, got, keys)
+	}
+	if ks := m.AppendKeys(nil); !slices.Equal(ks, keys) {
+		t.Fatalf("step %d: AppendKeys\n%v\nwant\n%v", step, ks, keys)
+	}
+	vs := m.AppendValues(nil)
+	for i, k := range keys {
+		if vs[i] != elems[k] {
+			t.Fatalf("step %d: AppendValues[%d] = %d, want %d", step, i, vs[i], elems[k])
+		}
+	}
+	ok, nk, nv := false, 0, 0
+	if len(keys) > 0 {
+		ok, nk, nv = true, keys[len(keys)-1], elems[keys[len(keys)-1]]
+	}
+	if k, v, o := m.Newest(); o != ok || k != nk || v != nv {
+		t.Fatalf("step %d: Newest() = %d, %d, %v; want %d, %d, %v", step, k, v, o, nk, nv, ok)
+	}
+	if len(keys) > 0 {
+		if k, v, _ := m.Oldest(); k != keys[0] || v != elems[keys[0]] {
+			t.Fatalf("step %d: Oldest() = %d, %d; want %d, %d", step, k, v, keys[0], elems[keys[0]])
+		}
+	}
+	if err := m.Validate(); err != nil {
+		t.Fatalf("step %d: %v", step, err)
+	}
+}
+
+// TestOrderedGrowShrink keeps the insertion order through growths and
+// shrinks, with deleted keys reinserted at the back.
+func TestOrderedGrowShrink(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	m := NewOrdered[int, int](0, WithShrink(0.25))
+	var keys []int
+	elems := make(map[int]int)
+	for i := range 3000 {
+		m.Put(i, i)
+		keys = append(keys, i)
+		elems[i] = i
+		if m.Growing() && i%3 == 0 {
+			checkOrder(t, i, m, keys, elems)
+		}
+	}
+	top := m.h.B
+	for step := 0; len(keys) > 50; step++ {
+		k := keys[r.IntN(len(keys))]
+		if step%5 == 0 {
+			// Overwrite: the key keeps its place.
+			m.Put(k, -k)
+			elems[k] = -k
+			continue
+		}
+		m.Delete(k)
+		delete(elems, k)
+		keys = slices.DeleteFunc(keys, func(x int) bool { return x == k })
+		if step%7 == 0 {
+			m.Put(k, step)
+			keys = append(keys, k)
+			elems[k] = step
+		}
+		if m.Growing() || step%100 == 0 {
+			checkOrder(t, step, m, keys, elems)
+		}
+	}
+	checkOrder(t, -1, m, keys, elems)
+	if m.h.B >= top {
+		t.Errorf("B=%d after deleting down to %d entries, was %d", m.h.B, m.Len(), top)
+	}
+}
+
+// TestOrderedMove moves keys to either end.
+func TestOrderedMove(t *testing.T) {
+	m := NewOrdered[int, int](0)
+	elems := make(map[int]int)
+	for i := range 5 {
+		m.Put(i, i)
+		elems[i] = i
+	}
+	if m.MoveToFront(9) || m.MoveToBack(9) {
+		t.Error("moved a key that is not in the map")
+	}
+	m.MoveToFront(3)
+	checkOrder(t, 0, m, []int{3, 0, 1, 2, 4}, elems)
+	m.MoveToBack(0)
+	checkOrder(t, 1, m, []int{3, 1, 2, 4, 0}, elems)
+	m.MoveToFront(3) // already there
+	m.MoveToBack(0)
+	checkOrder(t, 2, m, []int{3, 1, 2, 4, 0}, elems)
+	m.Delete(1)
+	m.Put(1, 1)
+	checkOrder(t, 3, m, []int{3, 2, 4, 0, 1}, elems)
+}
+
+// TestOrderedRangeWrites deletes and inserts during Range: a key deleted
+// before Range reaches it is not visited, and a key inserted during
+// Range is, at the back.
+func TestOrderedRangeWrites(t *testing.T) {
+	m := NewOrdered[int, int](0)
+	for i := range 100 {
+		m.Put(i, i)
+	}
+	var got []int
+	m.Range(func(k, v int) bool {
+		got = append(got, k)
+		switch {
+		case k < 100 && k%10 == 0:
+			m.Delete(k + 1) // the next entry
+			m.Delete(k + 3)
+			m.Delete(k) // the current one
+		case k < 50:
+			m.Put(1000+k, k) // grows the map under the loop
+		}
+		return true
+	})
+	var want []int
+	for i := range 100 {
+		if i%10 != 1 && i%10 != 3 {
+			want = append(want, i)
+		}
+	}
+	for i := range 50 {
+		if i%10 != 0 && i%10 != 1 && i%10 != 3 {
+			want = append(want, 1000+i)
+		}
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("range visited\n%v\nwant\n%v", got, want)
+	}
+}
+
+// TestOrderedClearInRange clears the map at the first entry of a Range:
+// no entry from before the Clear may be visited after it.
+func TestOrderedClearInRange(t *testing.T) {
+	m := NewOrdered[int, int](0)
+	for i := range 100 {
+		m.Put(i, i)
+	}
+	n := 0
+	m.Range(func(k, v int) bool {
+		if n++; n == 1 {
+			m.Clear()
+			for i := range 10 {
+				m.Put(200+i, i)
+			}
+		} else if k < 200 {
+			t.Errorf("range visited %d after Clear", k)
+		}
+		return n < 1000
+	})
+	if m.Len() != 10 {
+		t.Errorf("Len() = %d after Clear and 10 puts", m.Len())
+	}
+}
+
+// TestOrderedClone checks that a clone has the same order and that
+// writes to either leave the other alone.
+func TestOrderedClone(t *testing.T) {
+	m := NewOrdered[int, int](0)
+	var keys []int
+	elems := make(map[int]int)
+	for i := range 200 {
+		m.Put(i*7%200, i)
+		keys = append(keys, i*7%200)
+		elems[i*7%200] = i
+	}
+	c := m.Clone()
+	checkOrder(t, 0, c, keys, elems)
+	c.Put(1000, 1)
+	c.Delete(keys[0])
+	c.MoveToFront(keys[5])
+	c.Put(keys[1], -1)
+	checkOrder(t, 1, m, keys, elems)
+	m.Clear()
+	if c.Len() != 200 {
+		t.Errorf("clone has %d entries after clearing the original", c.Len())
+	}
+}
+
+// TestOrderedNegativeZero puts -0 over +0: the key of the entry must be
+// -0 wherever the order shows it, as in the builtin map.
+func TestOrderedNegativeZero(t *testing.T) {
+	m := NewOrdered[float64, int](0)
+	m.Put(0, 1)
+	m.Put(1, 1)
+	m.Put(math.Copysign(0, -1), 2)
+	if k, v, _ := m.Oldest(); !math.Signbit(k) || v != 2 {
+		t.Errorf("Oldest() = %v, %d; want -0, 2", k, v)
+	}
+	m.Range(func(k float64, v int) bool {
+		if k == 0 && !math.Signbit(k) {
+			t.Errorf("range produced +0: %d", v)
+		}
+		return true
+	})
+	if ks := m.AppendKeys(nil); len(ks) != 2 || !math.Signbit(ks[0]) {
+		t.Errorf("AppendKeys = %v, want [-0 1]", ks)
+	}
+	m.MoveToBack(0)
+	if k, _, _ := m.Newest(); !math.Signbit(k) {
+		t.Errorf("Newest() = %v, want -0", k)
+	}
+}
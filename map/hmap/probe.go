@@ -0,0 +1,149 @@
+package hmap
+
+import "fmt"
+
+// Probing reports whether the package was built with -tags hmapstep.
+// Only then do mapassign and mapdelete call the Probe of a map created
+// with WithProbe; otherwise the calls are compiled out and WithProbe
+// panics.
+const Probing = probing
+
+// A Probe is called at each step of mapassign and mapdelete, before the
+// write carries on: while the hash is computed, as each bucket of the
+// chain is entered and each cell looked at, when a free cell is
+// recorded or the key found, when hashGrow sends mapassign back to
+// again, and at each cell the emptyRest loop of mapdelete marks. The
+// map is in the middle of the write and must not be written, but it may
+// be read, with Layout for instance, to show where the step is.
+//
+// A Probe that does not return holds the write up there, which is how
+// map/cmd/mapstep steps through one.
+/*
+	Probe 在 mapassign、mapdelete 的每一步被调用：计算 hash、进入链上的每个桶、查看每个 cell、记下第一个空位、
+	找到 key、hashGrow 之后 goto again、mapdelete 向前回填 emptyRest 的每个 cell 等等。
+	回调时写操作进行到一半，只能读（比如调用 Layout 显示当前位置），不能写。
+	只有用 -tags hmapstep 编译时这些调用才存在，否则被编译器整个删掉，不影响正常的性能。
+*/
+type Probe interface {
+	Step(s *Step)
+}
+
+// WithProbe reports the steps of mapassign and mapdelete to p. It needs
+// a build with -tags hmapstep (see Probing). Clones of the map are not
+// probed.
+func WithProbe(p Probe) Option {
+	if !probing {
+		panic(plainError("hmap: WithProbe needs a build with -tags hmapstep"))
+	}
+	return func(c *config) { c.probe = p }
+}
+
+// StepKind is the kind of a Step.
+type StepKind uint8
+
+const (
+	StepHash        StepKind = iota // the key is hashed; Bucket is the bucket it selects
+	StepGrowWork                    // growWork is about to evacuate for Bucket
+	StepBucket                      // the scan enters bucket Overflow of the chain
+	StepCell                        // the scan looks at Cell, comparing its tophash with Top
+	StepFreeCell                    // mapassign records Cell as insertb/inserti, the first free one
+	StepFound                       // the key is in Cell
+	StepHashGrow                    // mapassign started a growth and goes back to again
+	StepNewOverflow                 // mapassign found no free cell and linked a new overflow bucket
+	StepInsert                      // mapassign stored the key in Cell
+	StepEmptyOne                    // mapdelete marked Cell emptyOne
+	StepEmptyRest                   // mapdelete marked Cell emptyRest, walking back
+	StepShrink                      // mapdelete started a shrink
+	StepDone                        // the write is about to return
+)
+
+var stepNames = [...]string{
+	StepHash:        "hash",
+	StepGrowWork:    "growwork",
+	StepBucket:      "bucket",
+	StepCell:        "cell",
+	StepFreeCell:    "freecell",
+	StepFound:       "found",
+	StepHashGrow:    "hashgrow",
+	StepNewOverflow: "newoverflow",
+	StepInsert:      "insert",
+	StepEmptyOne:    "emptyone",
+	StepEmptyRest:   "emptyrest",
+	StepShrink:      "shrink",
+	StepDone:        "done",
+}
+
+func (k StepKind) String() string {
+	if int(k) < len(stepNames) {
+		return stepNames[k]
+	}
+	return fmt.Sprintf("StepKind(%d)", uint8(k))
+}
+
+// StepKinds returns every StepKind in the order a write can reach them.
+func StepKinds() []StepKind {
+	ks := make([]StepKind, len(stepNames))
+	for i := range ks {
+		ks[i] = StepKind(i)
+	}
+	return ks
+}
+
+// A Step is where mapassign or mapdelete has got to.
+type Step struct {
+	Kind StepKind
+	Op   string // "mapassign" or "mapdelete"
+	Key  string // fmt.Sprint of the key
+	Hash uintptr
+	Top  uint8 // tophash(Hash)
+
+	// Bucket is the index of the chain in h.buckets, and Overflow the
+	// position of the bucket in it, 0 for the bucket itself; both are
+	// valid from the StepBucket on. Cell is the index of the cell in that
+	// bucket, -1 for the kinds that are not about a cell, and Tophash
+	// what the cell holds now.
+	Bucket   uintptr
+	Overflow int
+	Cell     int
+	Tophash  uint8
+
+	B     uint8 // h.B, which changes at StepHashGrow and StepShrink
+	Count int   // h.count
+}
+
+// prober is the per-map probing state hung off hmap.probe.
+type prober struct {
+	p Probe
+	s Step
+}
+
+// probeBegin starts the steps of a write of key. It reports StepHash.
+func probeBegin[K comparable, V any](h *hmap[K, V], op string, key K, hash uintptr) {
+	if h.probe == nil {
+		return
+	}
+	h.probe.s = Step{Op: op, Key: fmt.Sprint(key), Hash: hash, Top: tophash(hash)}
+	probeAt(h, StepHash, hash&bucketMask(h.B), nil, -1)
+}
+
+// probeAt reports a step at cell of b, which is in the chain of
+// bucket. b is nil, and cell -1, for the steps that are not about a
+// bucket or a cell.
+func probeAt[K comparable, V any](h *hmap[K, V], kind StepKind, bucket uintptr, b *bmap[K, V], cell int) {
+	if h.probe == nil {
+		return
+	}
+	s := &h.probe.s
+	s.Kind, s.Bucket, s.Cell, s.Tophash = kind, bucket, cell, 0
+	s.B, s.Count = h.B, h.count
+	if b != nil {
+		s.Overflow = 0
+		for c := &h.buckets[bucket]; c != b && c != nil; c = c.overflow {
+			s.Overflow++
+		}
+		if cell >= 0 {
+			s.Tophash = b.tophash[cell]
+		}
+	}
+	h.probe.p.Step(s)
+}
@@ -0,0 +1,5 @@
+//go:build !hmapstep
+
+package hmap
+
+const probing = false
@@ -0,0 +1,6 @@
+//go:build hmapstep
+
+package hmap
+
+// probing compiles the Probe calls into mapassign and mapdelete.
+const probing = true
@@ -0,0 +1,55 @@
+package hmap
+
+import "iter"
+
+// All, Keys and Values are the range-over-func form of a range loop over
+// the map. Each range over the returned sequence is a new iteration: it
+// calls mapiterinit, which picks a random startBucket and offset, and
+// then mapiternext, which walks oldbuckets while a grow is in progress
+// (skipping with checkBucket the entries that will go to the other half)
+// and looks up entries that moved since the iteration started. A break
+// out of the loop body makes yield return false, and the iteration stops
+// there without touching the map again.
+/*
+	All/Keys/Values 让 Map 可以直接用 for range 遍历：每次 range 都重新调用 mapiterinit，
+	随机选择起始桶和桶内偏移，遍历过程中的扩容、搬迁判断（checkBucket）都和 Range 一样由 mapiternext 处理；
+	循环体里 break 时 yield 返回 false，迭代立即结束。
+*/
+
+// All returns an iterator over the key/element pairs of m, in the
+// randomized order of a range loop over a builtin map.
+func (m *Map[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		var it hiter[K, V]
+		for mapiterinit(m.t, m.h, &it); it.key != nil; mapiternext(&it) {
+			if !yield(*it.key, *it.elem) {
+				return
+			}
+		}
+	}
+}
+
+// Keys returns an iterator over the keys of m, in the same order as All.
+func (m *Map[K, V]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		var it hiter[K, V]
+		for mapiterinit(m.t, m.h, &it); it.key != nil; mapiternext(&it) {
+			if !yield(*it.key) {
+				return
+			}
+		}
+	}
+}
+
+// Values returns an iterator over the elements of m, in the same order
+// as All.
+func (m *Map[K, V]) Values() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		var it hiter[K, V]
+		for mapiterinit(m.t, m.h, &it); it.key != nil; mapiternext(&it) {
+			if !yield(*it.elem) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package hmap
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+// TestSeqBreak breaks out of loops over All, Keys and Values after a few
+// entries: the body must not run again, and the map must stay usable.
+func TestSeqBreak(t *testing.T) {
+	m := New[int, int](0)
+	for i := range 100 {
+		m.Put(i, i)
+	}
+	for _, stop := range []int{1, 5, 99} {
+		n := 0
+		for k, v := range m.All() {
+			if k != v {
+				t.Fatalf("All produced %d: %d", k, v)
+			}
+			if n++; n == stop {
+				break
+			}
+		}
+		if n != stop {
+			t.Errorf("All: body ran %d times, broke at %d", n, stop)
+		}
+		n = 0
+		for range m.Keys() {
+			if n++; n == stop {
+				break
+			}
+		}
+		if n != stop {
+			t.Errorf("Keys: body ran %d times, broke at %d", n, stop)
+		}
+		n = 0
+		for range m.Values() {
+			if n++; n == stop {
+				break
+			}
+		}
+		if n != stop {
+			t.Errorf("Values: body ran %d times, broke at %d", n, stop)
+		}
+	}
+	// A return from inside the loop is a break too.
+	first := func() int {
+		for k := range m.Keys() {
+			return k
+		}
+		return -1
+	}
+	if k := first(); k < 0 || k >= 100 {
+		t.Errorf("first key %d", k)
+	}
+	m.Put(100, 100)
+	n := 0
+	for range m.All() {
+		n++
+	}
+	if n != 101 {
+		t.Errorf("a full loop after the breaks produced %d entries, want 101", n)
+	}
+}
+
+// TestSeqWrites deletes and inserts in the body of a loop over All that
+// starts in the middle of a growth, and keeps writing until several
+// more growths have happened under it. Every key must come up at most
+// once, no key deleted before the loop reached it may come up, and
+// every key present throughout must come up.
+func TestSeqWrites(t *testing.T) {
+	for seed := range uint64(10) {
+		r := rand.New(rand.NewPCG(seed, 0))
+		m := New[int, int](0)
+		n := 0
+		for ; !m.Growing() || n < 100; n++ {
+			m.Put(n, n)
+		}
+		seen := make(map[int]bool)
+		deleted := make(map[int]bool)
+		next := n
+		for k, v := range m.All() {
+			if seen[k] || deleted[k] || v != k {
+				t.Fatalf("seed %d: loop produced %d: %d again, after it was deleted or wrong", seed, k, v)
+			}
+			seen[k] = true
+			if d := r.IntN(n); !seen[d] {
+				m.Delete(d)
+				deleted[d] = true
+			}
+			for range 4 {
+				m.Put(next, next)
+				next++
+			}
+		}
+		for i := range n {
+			if !deleted[i] && !seen[i] {
+				t.Fatalf("seed %d: loop missed %d", seed, i)
+			}
+		}
+		if m.h.B < 3 {
+			t.Fatalf("seed %d: the map only grew to B=%d", seed, m.h.B)
+		}
+		if err := m.Validate(); err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+	}
+}
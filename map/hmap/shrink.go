@@ -0,0 +1,116 @@
+package hmap
+
+// Shrinking is not in the runtime: a Go map keeps 2^B buckets for good,
+// however few entries are left. A map created with WithShrink reverses
+// growth when it becomes sparse. hashShrink halves B and, like a
+// doubling grow in reverse, keeps the old array in oldbuckets while
+// writes merge old buckets i and i+2^B into new bucket i; nevacuate
+// counts the new buckets that have been filled.
+/*
+	收缩是本移植新增的（runtime 的 map 只会变大）。用 WithShrink 创建的 map，在 mapdelete 后
+	count 低于 frac × loadFactor × 2^B 时调用 hashShrink：B 减一，旧数组留在 oldbuckets，
+	之后每次写入把旧桶 i 与 i+2^B 合并进新桶 i，nevacuate 记录已经合并好的新桶数。
+	与翻倍扩容相比有两点简化：
+	  - 旧桶 i 和 i+2^B 中的 key 一定都属于新桶 i，合并时不需要重新计算 hash（NaN 也一样），
+	    迭代器遍历未搬迁的旧桶时也不需要 checkBucket 过滤；
+	  - 两个旧桶总是一起搬迁，所以 evacuated(&oldbuckets[i]) 就代表新桶 i 已经完整。
+*/
+
+// shrinking reports whether the current growth halves the bucket count.
+func (h *hmap[K, V]) shrinking() bool {
+	return h.flags&shrinking != 0
+}
+
+// shouldShrink reports whether mapdelete should start a shrink: the map
+// may shrink, is not already growing, is above its minimum size, and
+// holds fewer than h.shrink*loadFactor*2^B entries.
+func (h *hmap[K, V]) shouldShrink(t *maptype[K]) bool {
+	if h.shrink == 0 || h.growing() || h.B <= h.minB {
+		return false
+	}
+	loadFactor := float64(t.loadFactorNum) / float64(t.loadFactorDen)
+	return float64(h.count) < h.shrink*loadFactor*float64(bucketShift(h.B))
+}
+
+// hashShrink starts halving the bucket array. The merging is done
+// incrementally by growWork and evacuateShrink.
+func hashShrink[K comparable, V any](t *maptype[K], h *hmap[K, V]) {
+	oldbuckets := h.buckets
+	noverflow := h.noverflow
+	newbuckets, nextOverflow := makeBucketArray[K, V](t, h.B-1, nil)
+	h.countArray(t, len(newbuckets))
+
+	flags := h.flags &^ (iterator | oldIterator)
+	if h.flags&iterator != 0 {
+		flags |= oldIterator
+	}
+	h.B--
+	h.flags = flags | shrinking
+	h.oldbuckets = oldbuckets
+	h.buckets = newbuckets
+	h.nevacuate = 0
+	h.noverflow = 0
+
+	if h.extra != nil {
+		h.extra.nextOverflow = nil
+	}
+	if nextOverflow != nil {
+		if h.extra == nil {
+			h.extra = new(mapextra[K, V])
+		}
+		h.extra.nextOverflow = nextOverflow
+	}
+
+	if h.trace != nil {
+		h.trace.growSeq = h.trace.seq
+		h.trace.emit(&GrowStart{OldB: h.B + 1, B: h.B, Shrink: true, Count: h.count, NOverflow: noverflow})
+	}
+}
+
+// evacuateShrink fills new bucket bucket from old buckets bucket and
+// bucket+2^B. Every key of those two chains belongs in it, so nothing is
+// rehashed and every cell is marked evacuatedX.
+func evacuateShrink[K comparable, V any](t *maptype[K], h *hmap[K, V], bucket uintptr) {
+	newbit := bucketShift(h.B) // # of new buckets; the old array has twice as many
+	if !evacuated(&h.oldbuckets[bucket]) {
+		dst := evacDst[K, V]{b: &h.buckets[bucket]}
+		var moved [2]int // entries from the low and the high old bucket
+		for half := range uintptr(2) {
+			oldbucket := bucket + half*newbit
+			for b := &h.oldbuckets[oldbucket]; b != nil; b = b.overflow {
+				for i := 0; i < int(t.bucketCnt); i++ {
+					top := b.tophash[i]
+					if isEmpty(top) {
+						b.tophash[i] = evacuatedEmpty
+						continue
+					}
+					if top < minTopHash {
+						throw("bad map state")
+					}
+					b.tophash[i] = evacuatedX
+					if dst.i == int(t.bucketCnt) {
+						dst.b = h.newoverflow(t, dst.b)
+						dst.i = 0
+					}
+					dst.b.tophash[dst.i] = top
+					dst.b.moveCell(uintptr(dst.i), b, uintptr(i))
+					dst.i++
+					moved[half]++
+				}
+			}
+			// Unlink the overflow buckets & clear key/elem to help GC.
+			if h.flags&oldIterator == 0 && t.bucketPtrs {
+				b := &h.oldbuckets[oldbucket]
+				b.clearCells()
+				b.overflow = nil
+			}
+		}
+		if h.trace != nil {
+			h.trace.emit(&Evacuate{Bucket: bucket, X: moved[0], Y: moved[1]})
+		}
+	}
+
+	if bucket == h.nevacuate {
+		advanceEvacuationMark(h, t, newbit)
+	}
+}
@@ -0,0 +1,476 @@
+package hmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"unsafe"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/internal/mapopt"
+	"github.com/ProsperousLi/golang-deep-learn/map/internal/rtalg"
+)
+
+// Snapshots store a Map as it sits in memory: the hmap header, every
+// bucket array (oldbuckets too, when a grow is in progress), the overflow
+// buckets allocated one by one, and the overflow links between them.
+// Keys and elems are written as raw memory, so both types must be free
+// of pointers; in exchange Load fills the arrays with a few large reads
+// and never hashes a key. A key's bucket and tophash only stay valid
+// under the same hash function and hash0, so the snapshot carries hash0
+// and the hash of the zero key, which Load compares against its own
+// hasher before trusting the layout.
+/*
+	快照把 map 在内存中的样子原样写出：hmap 头部（hash0、B、count、nevacuate 等）、桶数组
+	（扩容中还包括 oldbuckets）、单独分配的溢出桶以及它们之间的 overflow 链接。key/elem 按原始内存写入，
+	所以要求 K、V 都不含指针；Load 只需几次大块读取就能还原，不用重新计算任何 key 的 hash。
+	桶的位置和 tophash 只在同一个 hash 函数、同一个 hash0 下有效，所以快照里带上 hash0 和零值 key 的 hash，
+	Load 用自己的 hasher 先核对这一个值，对不上就报错，不会构造出一张查不到 key 的表。
+*/
+const (
+	snapshotMagic   = "HMAPSNAP"
+	snapshotVersion = 1
+	byteOrderMark   = 0x01020304 // written in native order
+)
+
+// snapshotHeader is the fixed part of a snapshot, little-endian after
+// the magic. The bucket table follows: len(buckets) + len(oldbuckets) +
+// Loose buckets, each stored as its tophash, keys and elems, grouped per
+// array, then one overflow link per bucket (its index in the table plus
+// one, 0 for nil).
+type snapshotHeader struct {
+	Version   uint32
+	ByteOrder uint32
+	PtrSize   uint32
+	KeySize   uint64
+	ElemSize  uint64
+
+	BucketCntBits uint8
+	IndirectKey   bool
+	IndirectElem  bool
+	Flags         uint8 // sameSizeGrow and shrinking
+	B             uint8
+	MinB          uint8
+	NOverflow     uint16
+	Hash0         uint32
+	ZeroHash      uint64 // t.hasher of the zero key under Hash0
+	LoadFactorNum uint64
+	LoadFactorDen uint64
+	Shrink        float64
+	Count         uint64
+	NEvacuate     uint64
+
+	Buckets      uint64 // len(h.buckets), preallocated overflow buckets included
+	OldBuckets   uint64 // len(h.oldbuckets)
+	Loose        uint64 // overflow buckets from newobject
+	NextOverflow uint64 // table index of h.extra.nextOverflow plus one, or 0
+}
+
+var errSnapshot = errors.New("hmap: malformed snapshot")
+
+// Save writes a snapshot of m to w. K and V must not contain pointers
+// (no strings, slices, maps, interfaces or pointers); Save returns an
+// error without writing anything otherwise. Save does not modify m, and
+// a snapshot taken in the middle of a grow is reloaded in the same state.
+func (m *Map[K, V]) Save(w io.Writer) error {
+	t, h := m.t, m.h
+	if err := checkSnapshotTypes[K, V](); err != nil {
+		return err
+	}
+	if h.flags&hashWriting != 0 {
+		fatal("concurrent map read and map write")
+	}
+
+	// Number every bucket: the arrays first, then the overflow buckets
+	// that newoverflow got from newobject, in the order they are found.
+	loose := map[*bmap[K, V]]uint64{}
+	var looseList []*bmap[K, V]
+	index := func(b *bmap[K, V]) uint64 {
+		if i := indexIn(h.buckets, b); i >= 0 {
+			return uint64(i)
+		}
+		if i := indexIn(h.oldbuckets, b); i >= 0 {
+			return uint64(len(h.buckets) + i)
+		}
+		return uint64(len(h.buckets)+len(h.oldbuckets)) + loose[b]
+	}
+	for _, array := range [2][]bmap[K, V]{h.buckets, h.oldbuckets} {
+		for i := range array {
+			for b := array[i].overflow; b != nil && indexIn(h.buckets, b) < 0 && indexIn(h.oldbuckets, b) < 0; b = b.overflow {
+				if _, ok := loose[b]; ok {
+					break
+				}
+				loose[b] = uint64(len(looseList))
+				looseList = append(looseList, b)
+			}
+		}
+	}
+
+	hdr := snapshotHeader{
+		Version:       snapshotVersion,
+		ByteOrder:     nativeOrderMark(),
+		PtrSize:       ptrSize,
+		KeySize:       uint64(t.keySize),
+		ElemSize:      uint64(t.elemSize),
+		BucketCntBits: t.bucketCntBits,
+		IndirectKey:   t.indirectKey,
+		IndirectElem:  t.indirectElem,
+		Flags:         h.flags & (sameSizeGrow | shrinking),
+		B:             h.B,
+		MinB:          h.minB,
+		NOverflow:     h.noverflow,
+		Hash0:         h.hash0,
+		ZeroHash:      uint64(zeroHash(t, h.hash0)),
+		LoadFactorNum: uint64(t.loadFactorNum),
+		LoadFactorDen: uint64(t.loadFactorDen),
+		Shrink:        h.shrink,
+		Count:         uint64(h.count),
+		NEvacuate:     uint64(h.nevacuate),
+		Buckets:       uint64(len(h.buckets)),
+		OldBuckets:    uint64(len(h.oldbuckets)),
+		Loose:         uint64(len(looseList)),
+	}
+	if h.extra != nil && h.extra.nextOverflow != nil {
+		hdr.NextOverflow = index(h.extra.nextOverflow) + 1
+	}
+
+	var err error
+	write := func(p []byte) {
+		if err == nil {
+			_, err = w.Write(p)
+		}
+	}
+	var buf bytes.Buffer
+	buf.WriteString(snapshotMagic)
+	binary.Write(&buf, binary.LittleEndian, &hdr)
+	write(buf.Bytes())
+
+	for _, array := range [2][]bmap[K, V]{h.buckets, h.oldbuckets} {
+		if len(array) > 0 {
+			writeCells(t, array, write)
+		}
+	}
+	for _, b := range looseList {
+		writeCells(t, unsafe.Slice(b, 1), write)
+	}
+
+	links := make([]byte, 0, 8*(len(h.buckets)+len(h.oldbuckets)+len(looseList)))
+	link := func(b *bmap[K, V]) {
+		var v uint64
+		if b.overflow != nil {
+			v = index(b.overflow) + 1
+		}
+		links = binary.LittleEndian.AppendUint64(links, v)
+	}
+	for _, array := range [2][]bmap[K, V]{h.buckets, h.oldbuckets} {
+		for i := range array {
+			link(&array[i])
+		}
+	}
+	for _, b := range looseList {
+		link(b)
+	}
+	write(links)
+	return err
+}
+
+// Load reads a snapshot written by Save and rebuilds the map it was taken
+// from: same hash0, same buckets, same overflow chains, same progress of
+// an unfinished grow. The bucket geometry (WithBucketCnt, WithLoadFactor,
+// WithIndirect) and WithShrink come from the snapshot; opts supply the
+// rest. The hash function must be the one the map was saved with (the
+// same WithHasher, or none), or Load returns an error.
+func Load[K comparable, V any](r io.Reader, opts ...Option) (*Map[K, V], error) {
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != snapshotMagic {
+		return nil, errors.New("hmap: not a map snapshot")
+	}
+	var hdr snapshotHeader
+	if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+		return nil, err
+	}
+	if hdr.Version != snapshotVersion {
+		return nil, fmt.Errorf("hmap: snapshot version %d, want %d", hdr.Version, snapshotVersion)
+	}
+	if hdr.ByteOrder != nativeOrderMark() || hdr.PtrSize != ptrSize {
+		return nil, errors.New("hmap: snapshot was written on a different architecture")
+	}
+
+	g := geometry{
+		bucketCntBits: hdr.BucketCntBits,
+		loadFactorNum: uintptr(hdr.LoadFactorNum),
+		loadFactorDen: uintptr(hdr.LoadFactorDen),
+		maxKeySize:    math.MaxInt,
+		maxElemSize:   math.MaxInt,
+	}
+	if hdr.IndirectKey {
+		g.maxKeySize = 0
+	}
+	if hdr.IndirectElem {
+		g.maxElemSize = 0
+	}
+	if g.bucketCntBits < 2 || g.bucketCntBits > 5 || g.loadFactorDen == 0 || g.loadFactorNum == 0 ||
+		g.loadFactorNum > g.loadFactorDen<<g.bucketCntBits {
+		return nil, errSnapshot
+	}
+
+	c := newConfig(opts)
+	h := &hmap[K, V]{rand: c.rand, shrink: hdr.Shrink, minB: hdr.MinB}
+	t := newMaptype[K, V](rtalg.Alg{Mem: mapopt.Memhash(c.hasher), Rand: h.fastrand}, g)
+	if c.arena {
+		t.arena = newArena(c.slabSize)
+	}
+	if err := checkSnapshotTypes[K, V](); err != nil {
+		return nil, err
+	}
+	if hdr.KeySize != uint64(t.keySize) || hdr.ElemSize != uint64(t.elemSize) || hdr.IndirectKey != t.indirectKey ||
+		hdr.IndirectElem != t.indirectElem {
+		return nil, errors.New("hmap: snapshot key or elem type does not match")
+	}
+	if uint64(zeroHash(t, hdr.Hash0)) != hdr.ZeroHash {
+		return nil, errors.New("hmap: snapshot was written with a different hash function")
+	}
+
+	// Validate the sizes before allocating anything.
+	if hdr.B >= 8*ptrSize-8 || hdr.Flags&^(sameSizeGrow|shrinking) != 0 || hdr.Flags == sameSizeGrow|shrinking {
+		return nil, errSnapshot
+	}
+	nb, nold := uint64(bucketShift(hdr.B)), uint64(0)
+	if hdr.OldBuckets != 0 {
+		switch {
+		case hdr.Flags&shrinking != 0:
+			nold = nb << 1
+		case hdr.Flags&sameSizeGrow != 0:
+			nold = nb
+		default:
+			nold = nb >> 1
+		}
+	}
+	total := hdr.Buckets + hdr.OldBuckets + hdr.Loose
+	switch {
+	case hdr.Buckets == 0 && (hdr.B != 0 || hdr.Count != 0 || hdr.OldBuckets != 0),
+		hdr.Buckets != 0 && hdr.Buckets < nb,
+		hdr.OldBuckets == 0 && hdr.Flags != 0,
+		hdr.OldBuckets != 0 && (nold == 0 || hdr.OldBuckets < nold),
+		hdr.OldBuckets != 0 && hdr.NEvacuate > nold,
+		total > maxAlloc/uint64(t.bucketSize),
+		hdr.NextOverflow > total,
+		hdr.Count > total<<t.bucketCntBits:
+		return nil, errSnapshot
+	}
+
+	h.count = int(hdr.Count)
+	h.flags = hdr.Flags
+	h.B = hdr.B
+	h.noverflow = hdr.NOverflow
+	h.hash0 = hdr.Hash0
+	h.nevacuate = uintptr(hdr.NEvacuate)
+	if c.tracer != nil {
+		h.trace = &tracer{t: c.tracer}
+	}
+	if c.probe != nil {
+		h.probe = &prober{p: c.probe}
+	}
+
+	table := make([]*bmap[K, V], 0, total)
+	read := func(n uint64) ([]bmap[K, V], error) {
+		if n == 0 {
+			return nil, nil
+		}
+		array := newarray[K, V](t, uintptr(n))
+		h.countArray(t, len(array))
+		if err := readCells(t, h, array, r); err != nil {
+			return nil, err
+		}
+		for i := range array {
+			table = append(table, &array[i])
+		}
+		return array, nil
+	}
+	var err error
+	if h.buckets, err = read(hdr.Buckets); err != nil {
+		return nil, err
+	}
+	if h.oldbuckets, err = read(hdr.OldBuckets); err != nil {
+		return nil, err
+	}
+	for range hdr.Loose {
+		b := newobject[K, V](t)
+		h.countOverflow(t)
+		if err := readCells(t, h, unsafe.Slice(b, 1), r); err != nil {
+			return nil, err
+		}
+		table = append(table, b)
+	}
+
+	links := make([]byte, 8*total)
+	if _, err := io.ReadFull(r, links); err != nil {
+		return nil, err
+	}
+	// A link may only point at a bucket that heads no chain, and no bucket
+	// may be linked twice. Every chain is then a path from its head that
+	// ends, without a cycle, and no two chains share a bucket. The one
+	// exception is the sentinel makeBucketArray leaves in the last
+	// preallocated overflow bucket of an array, a link to the first bucket
+	// of that array; it is followed by no lookup as long as no chain
+	// reaches the bucket holding it.
+	head := func(i uint64) bool {
+		return hdr.Buckets != 0 && i < nb || hdr.OldBuckets != 0 && i >= hdr.Buckets && i < hdr.Buckets+nold
+	}
+	sentinel := func(i, to uint64) bool {
+		return hdr.Buckets > nb && i == hdr.Buckets-1 && to == 0 ||
+			hdr.OldBuckets > nold && i == hdr.Buckets+hdr.OldBuckets-1 && to == hdr.Buckets
+	}
+	linked := make([]bool, total)
+	var sentinels []uint64
+	for i, b := range table {
+		v := binary.LittleEndian.Uint64(links[8*i:])
+		switch {
+		case v == 0:
+			continue
+		case v > total:
+			return nil, errSnapshot
+		case sentinel(uint64(i), v-1):
+			sentinels = append(sentinels, uint64(i))
+		case head(v-1) || linked[v-1]:
+			return nil, errSnapshot
+		default:
+			linked[v-1] = true
+		}
+		b.overflow = table[v-1]
+	}
+	for _, i := range sentinels {
+		if linked[i] {
+			return nil, errSnapshot
+		}
+	}
+	// nextOverflow must point into the preallocated tail of the current
+	// array, at a bucket newoverflow may hand out: from there to the end
+	// of the array every bucket is unused, with no cell filled and no
+	// link but the sentinel in the last one, where newoverflow's pointer
+	// bump stops.
+	if hdr.NextOverflow != 0 {
+		next := hdr.NextOverflow - 1
+		if next < nb || next >= hdr.Buckets || table[hdr.Buckets-1].overflow != table[0] {
+			return nil, errSnapshot
+		}
+		for i := next; i < hdr.Buckets; i++ {
+			b := table[i]
+			if linked[i] || i < hdr.Buckets-1 && b.overflow != nil {
+				return nil, errSnapshot
+			}
+			for _, top := range b.tophash {
+				if top != emptyRest {
+					return nil, errSnapshot
+				}
+			}
+		}
+		h.extra = &mapextra[K, V]{nextOverflow: table[next]}
+	}
+	return &Map[K, V]{t: t, h: h}, nil
+}
+
+// checkSnapshotTypes reports whether K and V can be written as raw memory.
+func checkSnapshotTypes[K comparable, V any]() error {
+	if rtalg.HasPointers(reflect.TypeFor[K]()) || rtalg.HasPointers(reflect.TypeFor[V]()) {
+		return errors.New("hmap: snapshots need key and elem types without pointers")
+	}
+	return nil
+}
+
+// zeroHash is the hash of the zero key, the snapshot's check that Load
+// hashes keys the way Save's map did.
+func zeroHash[K comparable](t *maptype[K], hash0 uint32) uintptr {
+	var zero K
+	return t.hasher(zero, uintptr(hash0))
+}
+
+func nativeOrderMark() uint32 {
+	var b [4]byte
+	*(*uint32)(unsafe.Pointer(&b)) = byteOrderMark
+	return binary.LittleEndian.Uint32(b[:])
+}
+
+// writeCells writes the tophash bytes, keys and elems of the buckets in
+// array, which newarray allocated together.
+func writeCells[K comparable, V any](t *maptype[K], array []bmap[K, V], write func([]byte)) {
+	n := uintptr(len(array)) * t.bucketCnt
+	write(unsafe.Slice(unsafe.SliceData(array[0].tophash), n))
+	if t.indirectKey {
+		keys := make([]K, n)
+		for i := range keys {
+			if p := array[uintptr(i)/t.bucketCnt].ikeys[uintptr(i)%t.bucketCnt]; p != nil {
+				keys[i] = *p
+			}
+		}
+		write(rawBytes(keys))
+	} else {
+		write(rawBytes(unsafe.Slice(unsafe.SliceData(array[0].keys), n)))
+	}
+	if t.indirectElem {
+		elems := make([]V, n)
+		for i := range elems {
+			if p := array[uintptr(i)/t.bucketCnt].ielems[uintptr(i)%t.bucketCnt]; p != nil {
+				elems[i] = *p
+			}
+		}
+		write(rawBytes(elems))
+	} else {
+		write(rawBytes(unsafe.Slice(unsafe.SliceData(array[0].elems), n)))
+	}
+}
+
+// readCells is the inverse of writeCells. Indirect keys and elems are
+// allocated for the cells that hold an entry.
+func readCells[K comparable, V any](t *maptype[K], h *hmap[K, V], array []bmap[K, V], r io.Reader) error {
+	n := uintptr(len(array)) * t.bucketCnt
+	tophash := unsafe.Slice(unsafe.SliceData(array[0].tophash), n)
+	if _, err := io.ReadFull(r, tophash); err != nil {
+		return err
+	}
+	if t.indirectKey {
+		keys := make([]K, n)
+		if _, err := io.ReadFull(r, rawBytes(keys)); err != nil {
+			return err
+		}
+		for i := range keys {
+			if tophash[i] >= minTopHash {
+				p := h.newKey(t)
+				*p = keys[i]
+				array[uintptr(i)/t.bucketCnt].ikeys[uintptr(i)%t.bucketCnt] = p
+			}
+		}
+	} else if _, err := io.ReadFull(r, rawBytes(unsafe.Slice(unsafe.SliceData(array[0].keys), n))); err != nil {
+		return err
+	}
+	if t.indirectElem {
+		elems := make([]V, n)
+		if _, err := io.ReadFull(r, rawBytes(elems)); err != nil {
+			return err
+		}
+		for i := range elems {
+			if tophash[i] >= minTopHash {
+				p := h.newElem(t)
+				*p = elems[i]
+				array[uintptr(i)/t.bucketCnt].ielems[uintptr(i)%t.bucketCnt] = p
+			}
+		}
+	} else if _, err := io.ReadFull(r, rawBytes(unsafe.Slice(unsafe.SliceData(array[0].elems), n))); err != nil {
+		return err
+	}
+	return nil
+}
+
+// rawBytes returns the memory of s.
+func rawBytes[T any](s []T) []byte {
+	if len(s) == 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(s))), uintptr(len(s))*unsafe.Sizeof(s[0]))
+}
@@ -0,0 +1,134 @@
+package hmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// save returns the snapshot of m and the offset of its overflow links,
+// the last 8 bytes per bucket.
+func save(t *testing.T, m *Map[int, int]) ([]byte, int) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := m.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	total := len(m.h.buckets) + len(m.h.oldbuckets)
+	return buf.Bytes(), buf.Len() - 8*total
+}
+
+// TestSnapshotRoundTrip saves and loads a map after a mix of writes,
+// and one in the middle of a growth whose old array has preallocated
+// overflow buckets.
+func TestSnapshotRoundTrip(t *testing.T) {
+	mixed := New[int, int](0, WithSeed(1))
+	for i := range 1000 {
+		mixed.Put(i, -i)
+		if i%3 == 0 {
+			mixed.Delete(i / 2)
+		}
+	}
+	growing := New[int, int](200, WithSeed(1))
+	for i := 0; !growing.Growing(); i++ {
+		growing.Put(i, -i)
+	}
+	for _, m := range []*Map[int, int]{mixed, growing} {
+		var buf bytes.Buffer
+		if err := m.Save(&buf); err != nil {
+			t.Fatal(err)
+		}
+		l, err := Load[int, int](&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		if l.Len() != m.Len() || l.Growing() != m.Growing() {
+			t.Fatalf("Len() = %d, Growing() = %v, want %d, %v", l.Len(), l.Growing(), m.Len(), m.Growing())
+		}
+		m.Range(func(k, v int) bool {
+			if got, ok := l.Lookup(k); !ok || got != v {
+				t.Errorf("m[%d] = %d, %v after Load, want %d", k, got, ok, v)
+			}
+			return true
+		})
+	}
+}
+
+// TestSnapshotBadLinks checks that Load rejects overflow links that
+// would make a chain loop or two chains share a bucket, and a
+// nextOverflow that newoverflow could not safely hand out.
+func TestSnapshotBadLinks(t *testing.T) {
+	link := func(b []byte, off, from int, to uint64) []byte {
+		b = bytes.Clone(b)
+		binary.LittleEndian.PutUint64(b[off+8*from:], to)
+		return b
+	}
+	// next sets the NextOverflow field, the last of the header.
+	next := func(b []byte, to uint64) []byte {
+		b = bytes.Clone(b)
+		off := len(snapshotMagic) + binary.Size(snapshotHeader{}) - 8
+		binary.LittleEndian.PutUint64(b[off:], to)
+		return b
+	}
+
+	small := New[int, int](0)
+	for i := range 8 {
+		small.Put(i, i)
+	}
+	// big has preallocated overflow buckets nb to last after its nb
+	// chain heads; last holds the sentinel link to bucket 0, and
+	// nextOverflow points at nb.
+	big := New[int, int](200)
+	nb, last := uint64(1)<<big.h.B, uint64(len(big.h.buckets)-1)
+	if last <= nb {
+		t.Fatalf("%d buckets for B=%d, want two preallocated overflow buckets", last+1, big.h.B)
+	}
+	sb, soff := save(t, small)
+	bb, boff := save(t, big)
+
+	for _, tt := range []struct {
+		name string
+		snap []byte
+	}{
+		{"bucket linked to itself", link(sb, soff, 0, 1)},
+		{"bucket linked to another chain head", link(bb, boff, 0, 2)},
+		{"overflow bucket linked twice", link(link(next(bb, last+1), boff, 0, nb+1), boff, 1, nb+1)},
+		{"overflow bucket linked to itself", link(link(next(bb, last+1), boff, 0, nb+1), boff, int(nb), nb+1)},
+		{"link past the end", link(sb, soff, 0, 2)},
+		{"chain reaching the sentinel", link(bb, boff, 0, last+1)},
+		{"nextOverflow at a chain head", next(bb, 1)},
+		{"nextOverflow past the end", next(bb, last+2)},
+		{"nextOverflow without preallocated buckets", next(sb, 1)},
+		{"nextOverflow before a linked bucket", link(bb, boff, 0, nb+2)},
+		{"nextOverflow with no sentinel", link(bb, boff, int(last), 0)},
+	} {
+		if _, err := Load[int, int](bytes.NewReader(tt.snap)); err != errSnapshot {
+			t.Errorf("%s: Load returned %v, want %v", tt.name, err, errSnapshot)
+		}
+	}
+
+	// A link to an overflow bucket that nextOverflow has gone past is
+	// fine: the map works on, and newoverflow hands out the last
+	// preallocated bucket and then allocates.
+	l, err := Load[int, int](bytes.NewReader(link(next(bb, last+1), boff, 0, nb+1)))
+	if err != nil {
+		t.Fatalf("one link to a used overflow bucket: %v", err)
+	}
+	if _, ok := l.Lookup(-1); ok {
+		t.Error("Lookup(-1) found an entry")
+	}
+	for i := range 2000 {
+		l.Put(i, i)
+	}
+	for i := range 2000 {
+		if v, ok := l.Lookup(i); !ok || v != i {
+			t.Fatalf("m[%d] = %d, %v after 2000 puts", i, v, ok)
+		}
+	}
+	if l.Len() != 2000 {
+		t.Errorf("Len() = %d after 2000 puts", l.Len())
+	}
+}
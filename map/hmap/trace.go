@@ -0,0 +1,150 @@
+package hmap
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// A Tracer receives the growth events of a map created with WithTracer.
+// Trace is called synchronously from inside the map operation that caused
+// the event, so it must not use the map.
+/*
+	Tracer 接收扩容过程中的事件：开始扩容（hashGrow）、搬迁一个旧桶（evacuate，附带搬到 X/Y 的数量）、
+	分配溢出桶（来自预分配的 nextOverflow 还是 newobject）、nevacuate 前进（advanceEvacuationMark）、
+	释放 oldbuckets。每个事件都带有触发它的写操作的序号和名字，可以看出是哪次写入为扩容买了单。
+*/
+type Tracer interface {
+	Trace(e Event)
+}
+
+// An Event is one of *GrowStart, *Evacuate, *OverflowAlloc,
+// *EvacuationMark and *Release, or an event of another map in this
+// repository (package swiss) that embeds EventHeader.
+type Event interface {
+	Kind() string
+	Header() *EventHeader
+}
+
+// EventHeader identifies the write operation an event happened in.
+type EventHeader struct {
+	// Seq numbers the writes (mapassign, mapdelete, mapclear, mapgrow)
+	// made to the map, starting at 1.
+	Seq uint64 `json:"seq"`
+	Op  string `json:"op"`
+}
+
+// Header returns h, so that every event type embedding EventHeader
+// implements Event.
+func (h *EventHeader) Header() *EventHeader { return h }
+
+// GrowStart is emitted by hashGrow, and by hashShrink with Shrink set.
+type GrowStart struct {
+	EventHeader
+	OldB      uint8  `json:"oldB"`
+	B         uint8  `json:"B"`
+	SameSize  bool   `json:"sameSizeGrow"`
+	Shrink    bool   `json:"shrink,omitempty"`
+	Count     int    `json:"count"`
+	NOverflow uint16 `json:"noverflow"` // the count that triggered a same-size grow
+}
+
+// Evacuate is emitted when evacuate moves an old bucket, with the number
+// of entries sent to each half of the new array. During a shrink Bucket
+// is the new bucket filled, and X and Y count the entries merged into it
+// from the low and the high old bucket.
+type Evacuate struct {
+	EventHeader
+	Bucket uintptr `json:"bucket"`
+	X      int     `json:"x"`
+	Y      int     `json:"y"`
+}
+
+// OverflowAlloc is emitted by newoverflow. FromNextOverflow tells whether
+// the bucket was one preallocated by makeBucketArray or a fresh newobject.
+type OverflowAlloc struct {
+	EventHeader
+	FromNextOverflow bool   `json:"fromNextOverflow"`
+	NOverflow        uint16 `json:"noverflow"`
+}
+
+// EvacuationMark is emitted when advanceEvacuationMark moves nevacuate.
+type EvacuationMark struct {
+	EventHeader
+	From uintptr `json:"from"`
+	To   uintptr `json:"to"`
+	Of   uintptr `json:"of"` // number of old buckets
+}
+
+// Release is emitted when oldbuckets is dropped, either because every
+// old bucket has been evacuated or because mapclear threw them away.
+type Release struct {
+	EventHeader
+	B      uint8  `json:"B"`
+	Writes uint64 `json:"writes"` // writes since the matching GrowStart, that one included
+	Clear  bool   `json:"clear"`
+}
+
+func (*GrowStart) Kind() string      { return "grow" }
+func (*Evacuate) Kind() string       { return "evacuate" }
+func (*OverflowAlloc) Kind() string  { return "overflow" }
+func (*EvacuationMark) Kind() string { return "nevacuate" }
+func (*Release) Kind() string        { return "release" }
+
+// tracer is the per-map tracing state hung off hmap.trace.
+type tracer struct {
+	t       Tracer
+	seq     uint64
+	op      string
+	growSeq uint64
+}
+
+// begin records the start of a write operation.
+func (tr *tracer) begin(op string) {
+	tr.seq++
+	tr.op = op
+}
+
+func (tr *tracer) emit(e Event) {
+	*e.Header() = EventHeader{Seq: tr.seq, Op: tr.op}
+	tr.t.Trace(e)
+}
+
+// JSONTracer writes each event as one JSON object per line, with the
+// event kind in the "event" field:
+//
+//	{"event":"grow","seq":53,"op":"mapassign","oldB":3,"B":4,...}
+//
+// It is safe to share between maps.
+type JSONTracer struct {
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+}
+
+// NewJSONTracer returns a JSONTracer writing to w.
+func NewJSONTracer(w io.Writer) *JSONTracer {
+	return &JSONTracer{w: w}
+}
+
+func (j *JSONTracer) Trace(e Event) {
+	b, err := json.Marshal(e)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.err != nil {
+		return
+	}
+	if err != nil {
+		j.err = err
+		return
+	}
+	line := append([]byte(`{"event":"`+e.Kind()+`",`), b[1:]...)
+	_, j.err = j.w.Write(append(line, '\n'))
+}
+
+// Err returns the first error encountered while encoding or writing.
+func (j *JSONTracer) Err() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.err
+}
@@ -0,0 +1,182 @@
+package hmap
+
+import "testing"
+
+// recorder is a Tracer that keeps every event.
+type recorder struct {
+	events []Event
+}
+
+func (r *recorder) Trace(e Event) {
+	r.events = append(r.events, e)
+}
+
+// checkGrowths checks the events of every growth in events, from its
+// GrowStart to its Release: each bucket to evacuate is evacuated exactly
+// once, nevacuate moves up from 0 without gaps to the number of such
+// buckets, and the Release counts the writes since the GrowStart. It
+// returns the GrowStarts; the last growth may still be in progress.
+func checkGrowths(t *testing.T, events []Event) []*GrowStart {
+	t.Helper()
+	var (
+		grows []*GrowStart
+		g     *GrowStart       // the growth in progress
+		n     uintptr          // buckets it evacuates
+		mark  uintptr          // nevacuate
+		seen  map[uintptr]bool // buckets evacuated
+	)
+	for i, e := range events {
+		switch e := e.(type) {
+		case *GrowStart:
+			if g != nil {
+				t.Fatalf("event %d: %+v while growing", i, e)
+			}
+			g, mark, seen = e, 0, make(map[uintptr]bool)
+			n = bucketShift(e.OldB)
+			switch {
+			case e.Shrink:
+				if e.OldB != e.B+1 {
+					t.Fatalf("event %d: shrink from B=%d to B=%d", i, e.OldB, e.B)
+				}
+				n = bucketShift(e.B)
+			case e.SameSize:
+				if e.OldB != e.B {
+					t.Fatalf("event %d: same-size grow from B=%d to B=%d", i, e.OldB, e.B)
+				}
+			default:
+				if e.OldB+1 != e.B {
+					t.Fatalf("event %d: grow from B=%d to B=%d", i, e.OldB, e.B)
+				}
+			}
+			grows = append(grows, e)
+		case *Evacuate:
+			switch {
+			case g == nil:
+				t.Fatalf("event %d: %+v outside a growth", i, e)
+			case e.Bucket >= n || seen[e.Bucket]:
+				t.Fatalf("event %d: %+v, with %d buckets to evacuate, %d done", i, e, n, len(seen))
+			case g.SameSize && e.Y != 0:
+				t.Fatalf("event %d: %+v in a same-size grow", i, e)
+			}
+			seen[e.Bucket] = true
+		case *EvacuationMark:
+			if g == nil || e.From != mark || e.To <= e.From || e.Of != n {
+				t.Fatalf("event %d: %+v, with nevacuate at %d of %d", i, e, mark, n)
+			}
+			mark = e.To
+		case *Release:
+			switch {
+			case g == nil || e.Clear:
+				t.Fatalf("event %d: %+v", i, e)
+			case mark != n || uintptr(len(seen)) != n:
+				t.Fatalf("event %d: released with nevacuate %d and %d buckets evacuated of %d", i, mark, len(seen), n)
+			case e.B != g.B || e.Writes != e.Seq-g.Seq+1:
+				t.Fatalf("event %d: %+v after %+v", i, e, g)
+			}
+			g = nil
+		}
+	}
+	return grows
+}
+
+// TestTraceGrow checks the events of the doublings that inserts cause:
+// B goes up one at a time, each growth starts at the write that would
+// pass the load factor, and every entry is evacuated to X or Y.
+func TestTraceGrow(t *testing.T) {
+	r := new(recorder)
+	m := New[int, int](0, WithTracer(r))
+	for i := range 1000 {
+		m.Put(i, i)
+	}
+	grows := checkGrowths(t, r.events)
+	if len(grows) != int(m.h.B) {
+		t.Fatalf("%d growths to reach B=%d", len(grows), m.h.B)
+	}
+	for i, g := range grows {
+		// 12/2 entries per bucket, and never less than one full bucket.
+		want := max(6*int(bucketShift(g.OldB)), 8)
+		if g.SameSize || g.OldB != uint8(i) || g.Op != "mapassign" || g.Count != want {
+			t.Errorf("growth %d: %+v, want a doubling at %d entries", i, g, want)
+		}
+		if g.Seq != uint64(g.Count)+1 {
+			t.Errorf("growth %d started by write %d, with %d entries", i, g.Seq, g.Count)
+		}
+	}
+	moved := make(map[uint64]int)
+	var seq uint64
+	for _, e := range r.events {
+		switch e := e.(type) {
+		case *GrowStart:
+			seq = e.Seq
+		case *Evacuate:
+			moved[seq] += e.X + e.Y
+		}
+	}
+	for i, g := range grows[:len(grows)-1] {
+		if moved[g.Seq] != g.Count {
+			t.Errorf("growth %d: %d entries evacuated of %d", i, moved[g.Seq], g.Count)
+		}
+	}
+}
+
+// TestTraceSameSizeGrow churns a map at a fixed size until its overflow
+// buckets pile up to 2^B, which starts a same-size grow.
+func TestTraceSameSizeGrow(t *testing.T) {
+	r := new(recorder)
+	m := New[int, int](0, WithTracer(r))
+	const live = 45 // B=3, just under the load factor of 48
+	for i := range live {
+		m.Put(i, i)
+	}
+	same := func() bool {
+		for _, e := range r.events {
+			if g, ok := e.(*GrowStart); ok && g.SameSize {
+				return true
+			}
+		}
+		return false
+	}
+	for i := live; !same() || m.Growing(); i++ {
+		if i == 1000000 {
+			t.Fatal("no same-size grow after a million writes")
+		}
+		m.Put(i, i)
+		m.Delete(i - live)
+	}
+	for _, g := range checkGrowths(t, r.events)[3:] {
+		if !g.SameSize || g.B != 3 || g.NOverflow < 8 {
+			t.Errorf("%+v, want a same-size grow at B=3 with 8 overflow buckets", g)
+		}
+	}
+}
+
+// TestTraceShrink deletes most of a map made with WithShrink and checks
+// that it halves one B at a time, each new bucket filled once.
+func TestTraceShrink(t *testing.T) {
+	r := new(recorder)
+	m := New[int, int](0, WithTracer(r), WithShrink(0.25))
+	for i := range 1000 {
+		m.Put(i, i)
+	}
+	top := m.h.B
+	r.events = nil
+	for i := range 990 {
+		m.Delete(i)
+	}
+	for m.Growing() {
+		m.Put(0, 0)
+		m.Delete(0)
+	}
+	grows := checkGrowths(t, r.events)
+	if len(grows) == 0 {
+		t.Fatal("no shrink")
+	}
+	for i, g := range grows {
+		if !g.Shrink || g.OldB != top-uint8(i) || g.Op != "mapdelete" {
+			t.Errorf("shrink %d: %+v", i, g)
+		}
+	}
+	if last := grows[len(grows)-1]; m.h.B != last.B {
+		t.Errorf("B=%d after shrinking to %d", m.h.B, last.B)
+	}
+}
@@ -0,0 +1,202 @@
+package hmap
+
+import "fmt"
+
+// Validate checks the invariants that the code of map/map.go relies on
+// and returns an error describing the first one broken, or nil:
+//
+//   - in every chain of buckets and unevacuated old buckets, no cell
+//     after an emptyRest cell is filled, and a filled cell has a tophash
+//     of at least minTopHash;
+//   - every key is in the chain its hash selects, under the tophash of
+//     its hash (NaN keys, whose hash is random, are exempt);
+//   - count equals the filled cells of the buckets plus those of the old
+//     buckets not yet evacuated;
+//   - an evacuated old bucket holds only evacuated* marks, in every cell
+//     of what is left of its chain, and every old bucket below nevacuate
+//     is evacuated;
+//   - a new bucket is empty until the old bucket feeding it is evacuated,
+//     since lookups and iterators do not look at it before then;
+//   - when B < 16, noverflow is the number of overflow buckets linked
+//     into the current array, which incrnoverflow counts exactly there.
+//
+// Validate walks the whole map and is meant for tests and debug builds.
+// Built with -tags hmapdebug, the package calls it after every write
+// and panics on an error.
+/*
+	Validate 遍历所有桶、溢出链以及尚未搬迁的旧桶，检查 map/map.go 依赖的不变量：emptyRest 之后没有非空格子；
+	非空格子的 tophash >= minTopHash；每个 key 都在它的 hash 对应的桶里、tophash 也对得上；count 等于新桶与未搬迁旧桶中的
+	非空格子数；已搬迁的旧桶只含 evacuated* 标记，nevacuate 之前的旧桶都已搬迁；旧桶搬迁之前对应的新桶是空的；
+	B < 16 时 noverflow 与实际链接的溢出桶数一致。用 -tags hmapdebug 编译时每次写操作之后都会调用它。
+*/
+func (m *Map[K, V]) Validate() error {
+	return validate(m.t, m.h)
+}
+
+func validate[K comparable, V any](t *maptype[K], h *hmap[K, V]) error {
+	if h.flags&hashWriting != 0 {
+		return validateError("hashWriting is set")
+	}
+	if h.count < 0 {
+		return validateError("count %d", h.count)
+	}
+	if h.buckets == nil {
+		if h.count != 0 || h.oldbuckets != nil {
+			return validateError("no buckets, count %d", h.count)
+		}
+		return nil
+	}
+	if uintptr(len(h.buckets)) < bucketShift(h.B) {
+		return validateError("%d buckets for B=%d", len(h.buckets), h.B)
+	}
+	if !h.growing() && h.flags&(sameSizeGrow|shrinking) != 0 {
+		return validateError("flags %#x while not growing", h.flags)
+	}
+	if h.sameSizeGrow() && h.shrinking() {
+		return validateError("flags %#x: sameSizeGrow and shrinking", h.flags)
+	}
+
+	seen := make(map[*bmap[K, V]]bool)
+	live := 0
+	// chain checks the chain of buckets starting at b, bucket index of
+	// an array of 1<<B: walked in full, cell by cell, as a lookup would.
+	chain := func(which string, b *bmap[K, V], index uintptr, B uint8) (overflow int, err error) {
+		rest := false
+		for n := 0; b != nil; b, n = b.overflow, n+1 {
+			if seen[b] {
+				return 0, validateError("%s %d: overflow bucket %d of the chain is linked twice", which, index, n)
+			}
+			seen[b] = true
+			if n > 0 {
+				overflow++
+			}
+			for i := range t.bucketCnt {
+				top := b.tophash[i]
+				switch {
+				case top == emptyRest:
+					rest = true
+					continue
+				case top == emptyOne:
+					continue
+				case rest:
+					return 0, validateError("%s %d: cell %d of bucket %d of the chain is filled after emptyRest", which, index, i, n)
+				case top < minTopHash:
+					return 0, validateError("%s %d: cell %d of bucket %d of the chain has tophash %d", which, index, i, n, top)
+				}
+				live++
+				k := b.key(i)
+				if !t.reflexiveKey && *k != *k {
+					continue
+				}
+				hash := t.hasher(*k, uintptr(h.hash0))
+				if hash&bucketMask(B) != index || tophash(hash) != top {
+					return 0, validateError("%s %d: key %v (hash %#x) in the wrong bucket or under tophash %d",
+						which, index, *k, hash, top)
+				}
+			}
+		}
+		return overflow, nil
+	}
+
+	nold := uintptr(0)
+	oldB := h.B
+	if h.growing() {
+		nold = h.noldbuckets()
+		if uintptr(len(h.oldbuckets)) < nold {
+			return validateError("%d old buckets, want %d", len(h.oldbuckets), nold)
+		}
+		switch {
+		case h.shrinking():
+			oldB++
+		case !h.sameSizeGrow():
+			oldB--
+		}
+	}
+	// fed reports whether new bucket i has received the entries of its
+	// old bucket, or whether there is nothing to receive.
+	fed := func(i uintptr) bool {
+		switch {
+		case !h.growing():
+			return true
+		case h.shrinking():
+			return evacuated(&h.oldbuckets[i])
+		}
+		return evacuated(&h.oldbuckets[i&(nold-1)])
+	}
+
+	noverflow := 0
+	for i := range bucketShift(h.B) {
+		b := &h.buckets[i]
+		if !fed(i) {
+			for c := b; c != nil; c = c.overflow {
+				for j := range t.bucketCnt {
+					if !isEmpty(c.tophash[j]) {
+						return validateError("bucket %d is filled before its old bucket is evacuated", i)
+					}
+				}
+			}
+		}
+		n, err := chain("bucket", b, i, h.B)
+		if err != nil {
+			return err
+		}
+		noverflow += n
+	}
+	if h.B < 16 && uint16(noverflow) != h.noverflow {
+		return validateError("noverflow %d, but %d overflow buckets are linked", h.noverflow, noverflow)
+	}
+
+	if h.growing() {
+		// While shrinking, nevacuate counts new buckets, each fed by old
+		// buckets i and i+2^B, which are evacuated together.
+		marks := nold
+		if h.shrinking() {
+			marks = bucketShift(h.B)
+		}
+		if h.nevacuate > marks {
+			return validateError("nevacuate %d past the %d buckets to evacuate", h.nevacuate, marks)
+		}
+		for i := range nold {
+			b := &h.oldbuckets[i]
+			done := evacuated(b)
+			if h.shrinking() && done != evacuated(&h.oldbuckets[i&(marks-1)]) {
+				return validateError("old buckets %d and %d evacuated separately", i&(marks-1), i)
+			}
+			if !done {
+				if i&(marks-1) < h.nevacuate {
+					return validateError("old bucket %d is not evacuated, but nevacuate is %d", i, h.nevacuate)
+				}
+				if _, err := chain("old bucket", b, i, oldB); err != nil {
+					return err
+				}
+				continue
+			}
+			for n := 0; b != nil; b, n = b.overflow, n+1 {
+				for j := range t.bucketCnt {
+					if top := b.tophash[j]; top < evacuatedX || top > evacuatedEmpty {
+						return validateError("old bucket %d: cell %d of bucket %d of the chain has tophash %d after evacuation", i, j, n, top)
+					}
+				}
+			}
+		}
+	}
+
+	if live != h.count {
+		return validateError("count %d, but %d cells are filled", h.count, live)
+	}
+	return nil
+}
+
+// debugCheck panics if the map is invalid, in a build with -tags
+// hmapdebug. It is called after every write made through the API.
+func debugCheck[K comparable, V any](t *maptype[K], h *hmap[K, V]) {
+	if debugValidate {
+		if err := validate(t, h); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func validateError(format string, args ...any) error {
+	return fmt.Errorf("hmap: invalid map: "+format, args...)
+}
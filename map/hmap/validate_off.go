@@ -0,0 +1,5 @@
+//go:build !hmapdebug
+
+package hmap
+
+const debugValidate = false
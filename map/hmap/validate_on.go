@@ -0,0 +1,6 @@
+//go:build hmapdebug
+
+package hmap
+
+// debugValidate makes every write through the API validate the map.
+const debugValidate = true
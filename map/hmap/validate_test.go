@@ -0,0 +1,67 @@
+package hmap
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestValidateCatches damages valid maps in the ways Validate is there
+// to notice and checks that it reports each.
+func TestValidateCatches(t *testing.T) {
+	// small is one bucket holding keys 0, 1 and 2 in cells 0 to 2.
+	small := func() *Map[int, int] {
+		m := New[int, int](0)
+		for i := range 3 {
+			m.Put(i, i)
+		}
+		return m
+	}
+	// growing is in the middle of a doubling, with old buckets left to
+	// evacuate both before and after nevacuate.
+	growing := func() *Map[int, int] {
+		m := New[int, int](0, WithSeed(1))
+		for i := 0; m.h.B < 4 || !m.Growing(); i++ {
+			m.Put(i, i)
+		}
+		return m
+	}
+	tests := []struct {
+		name   string
+		damage func() *Map[int, int]
+		want   string
+	}{
+		{"filled cell after emptyRest", func() *Map[int, int] {
+			m := small()
+			m.h.buckets[0].tophash[1] = emptyRest
+			m.h.count--
+			return m
+		}, "filled after emptyRest"},
+		{"count too high", func() *Map[int, int] {
+			m := small()
+			m.h.count++
+			return m
+		}, "count 4, but 3 cells are filled"},
+		{"nevacuate past an unevacuated bucket", func() *Map[int, int] {
+			m := growing()
+			m.h.nevacuate = m.h.noldbuckets()
+			return m
+		}, "is not evacuated, but nevacuate is"},
+		{"noverflow off by one", func() *Map[int, int] {
+			m := small()
+			m.h.noverflow++
+			return m
+		}, "noverflow 1, but 0 overflow buckets are linked"},
+	}
+	for _, tt := range tests {
+		m := tt.damage()
+		err := m.Validate()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Validate() = %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+	for _, m := range []*Map[int, int]{small(), growing()} {
+		if err := m.Validate(); err != nil {
+			t.Errorf("undamaged map: %v", err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package hmapviz
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hmap"
+)
+
+// WriteDOT writes l as a Graphviz digraph. Render it with, for example,
+// dot -Tsvg. Each bmap is one node; overflow pointers, X/Y evacuation
+// targets and extra.nextOverflow are edges.
+func WriteDOT(w io.Writer, l *hmap.Layout) error {
+	bw := bufio.NewWriter(w)
+	p := func(format string, args ...any) { fmt.Fprintf(bw, format, args...) }
+
+	p("digraph hmap {\n")
+	p("\trankdir=LR;\n\tnewrank=true;\n")
+	p("\tnode [shape=plaintext fontname=\"monospace\" fontsize=10];\n")
+	p("\tedge [fontname=\"monospace\" fontsize=9];\n")
+	p("\tlabel=%q;\n\tlabelloc=t;\n", summary(l)+"\n"+prealloc(l))
+
+	p("\tlegend [label=<<table border=\"0\" cellspacing=\"2\"><tr>")
+	for _, c := range legend {
+		p("<td bgcolor=%q border=\"1\">%v</td>", Colors[c], c)
+	}
+	p("</tr></table>>];\n")
+
+	if l.OldBuckets != nil {
+		p("\tsubgraph cluster_old {\n\t\tlabel=\"oldbuckets\";\n")
+		writeChains(p, "o", "oldbuckets", l.OldBuckets)
+		if rows := markRows(l); rows != nil {
+			p("\t\tnevacuate [label=\"nevacuate=%d\" fontcolor=%q];\n", l.NEvacuate, colorMark)
+			for _, n := range rows {
+				p("\t\tnevacuate -> o%d_0 [color=%q];\n", n, colorMark)
+			}
+		}
+		p("\t}\n")
+	}
+	p("\tsubgraph cluster_new {\n\t\tlabel=\"buckets\";\n")
+	writeChains(p, "b", "buckets", l.Buckets)
+	if l.NextOverflow >= 0 {
+		p("\t\tnextOverflow [label=\"extra.nextOverflow\\n&buckets[%d]\\n%d free\" shape=box];\n",
+			l.NextOverflow, l.NBuckets-l.NextOverflow)
+	}
+	p("\t}\n")
+
+	for i, c := range l.OldBuckets {
+		style := "dashed"
+		if c.Evacuated() {
+			style = "solid"
+		}
+		x, y := targets(l, i)
+		edge := func(to int, label, color string) {
+			if !c.Evacuated() {
+				color = colorPending
+			}
+			p("\to%d_0 -> b%d_0 [label=%q color=%q fontcolor=%q style=%s];\n", i, to, label, color, color, style)
+		}
+		edge(x, "X", colorX)
+		if y >= 0 {
+			edge(y, "Y", colorY)
+		}
+	}
+	p("}\n")
+	return bw.Flush()
+}
+
+// writeChains emits one node per bucket of chains and the overflow edges
+// between them. Node names are prefix+chain+"_"+position.
+func writeChains(p func(string, ...any), prefix, array string, chains []hmap.Chain) {
+	for i, c := range chains {
+		for j, b := range c {
+			p("\t\t%s%d_%d [label=<<table border=\"0\" cellborder=\"1\" cellspacing=\"0\"><tr>", prefix, i, j)
+			p("<td>%s</td>", escape(caption(array, i, j, b)))
+			for k, top := range b.Tophash {
+				p("<td bgcolor=%q title=\"%s\">%02x</td>", Colors[hmap.CellOf(top)], escape(cellTitle(b, k)), top)
+			}
+			p("<td port=\"ovf\"> </td></tr></table>>];\n")
+			if j > 0 {
+				p("\t\t%s%d_%d:ovf -> %s%d_%d;\n", prefix, i, j-1, prefix, i, j)
+			}
+		}
+	}
+}
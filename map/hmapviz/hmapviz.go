@@ -0,0 +1,147 @@
+// Package hmapviz draws a hmap.Layout: the bucket array, the old bucket
+// array while growing, every overflow chain, the preallocated overflow
+// buckets extra.nextOverflow hands out, and how far nevacuate has got.
+//
+// Each cell is coloured by what its tophash byte says:
+//
+//	filled          a live key/elem (tophash >= minTopHash)
+//	emptyRest       empty, and so is everything after it in the chain
+//	emptyOne        empty, but later cells may be filled
+//	evacuatedX      moved to the same index in the new array
+//	evacuatedY      moved to index + len(oldbuckets) in the new array
+//	evacuatedEmpty  empty, in a bucket that has been evacuated
+//
+// WriteDOT emits Graphviz input; WriteSVG lays the picture out itself and
+// needs no external tools; WriteText prints the cells as plain text, for
+// terminals and reports. WriteSwissDOT and WriteSwissSVG draw a
+// swiss.Layout the same way: the directory, each table and its groups,
+// with the control bytes coloured full, empty or deleted.
+/*
+	hmapviz 把 hmap.Layout 画出来：新旧两个桶数组、每条溢出链、预分配溢出桶（nextOverflow）
+	以及 nevacuate 的搬迁进度。cell 按 tophash 的含义着色，旧桶到新桶 X/Y 两半的去向用连线标出，
+	已搬迁的是实线，还没搬迁的是虚线。讲解或调试扩容时比直接读 evacuate() 直观得多。
+	WriteDOT 输出 Graphviz 源文件，WriteSVG 自己排版，不依赖任何外部工具。
+*/
+package hmapviz
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hmap"
+)
+
+// Colors maps each cell state to the fill colour used for it.
+var Colors = map[hmap.Cell]string{
+	hmap.CellFilled:         "#f4a261",
+	hmap.CellEmptyRest:      "#ffffff",
+	hmap.CellEmptyOne:       "#fff3b0",
+	hmap.CellEvacuatedX:     "#8ecae6",
+	hmap.CellEvacuatedY:     "#95d5b2",
+	hmap.CellEvacuatedEmpty: "#d3d3d3",
+}
+
+const (
+	colorX       = "#219ebc"
+	colorY       = "#2d6a4f"
+	colorPending = "#999999"
+	colorMark    = "#d62828"
+)
+
+// legend lists the cell states in the order they are explained.
+var legend = []hmap.Cell{
+	hmap.CellFilled, hmap.CellEmptyOne, hmap.CellEmptyRest,
+	hmap.CellEvacuatedX, hmap.CellEvacuatedY, hmap.CellEvacuatedEmpty,
+}
+
+// summary is the one-line description of the hmap header.
+func summary(l *hmap.Layout) string {
+	s := fmt.Sprintf("count=%d B=%d noverflow=%d hash0=%#x flags=%#x", l.Count, l.B, l.NOverflow, l.Hash0, l.Flags)
+	if l.OldBuckets != nil {
+		kind := "doubling"
+		switch {
+		case l.SameSizeGrow:
+			kind = "sameSizeGrow"
+		case l.Shrinking:
+			kind = "shrinking"
+		}
+		s += fmt.Sprintf(" growing(%s) nevacuate=%d/%d", kind, l.NEvacuate, nevacuateEnd(l))
+	}
+	return s
+}
+
+// prealloc describes the preallocated overflow buckets of the current array.
+func prealloc(l *hmap.Layout) string {
+	base := len(l.Buckets)
+	if l.NBuckets <= base {
+		return "no preallocated overflow buckets"
+	}
+	s := fmt.Sprintf("preallocated overflow buckets[%d:%d]", base, l.NBuckets)
+	if l.NextOverflow < 0 {
+		return s + ", all used (nextOverflow=nil)"
+	}
+	return s + fmt.Sprintf(", %d free, nextOverflow=&buckets[%d]", l.NBuckets-l.NextOverflow, l.NextOverflow)
+}
+
+// caption names a bucket the way the code reaches it.
+func caption(array string, chain, pos int, b hmap.Bucket) string {
+	switch {
+	case pos == 0:
+		return fmt.Sprintf("%s[%d]", array, chain)
+	case b.Index >= 0:
+		return fmt.Sprintf("ovf [%d]", b.Index)
+	default:
+		return "ovf new"
+	}
+}
+
+// targets returns the new buckets old bucket i evacuates into: X, and Y
+// unless the growth keeps the size. A shrink merges old buckets i and
+// i+len(Buckets) into X.
+func targets(l *hmap.Layout, i int) (x, y int) {
+	switch {
+	case l.SameSizeGrow:
+		return i, -1
+	case l.Shrinking:
+		return i % len(l.Buckets), -1
+	}
+	return i, i + len(l.OldBuckets)
+}
+
+// nevacuateEnd is the value of nevacuate at which the growth is done.
+func nevacuateEnd(l *hmap.Layout) int {
+	if l.Shrinking {
+		return len(l.Buckets)
+	}
+	return len(l.OldBuckets)
+}
+
+// markRows returns the old buckets the nevacuate mark sits in front of:
+// one, or two when shrinking, since the high half is evacuated together
+// with the low one.
+func markRows(l *hmap.Layout) []int {
+	n := int(l.NEvacuate)
+	if n >= nevacuateEnd(l) {
+		return nil
+	}
+	if l.Shrinking {
+		return []int{n, n + len(l.Buckets)}
+	}
+	return []int{n}
+}
+
+// cellTitle is the hover text of a cell.
+func cellTitle(b hmap.Bucket, i int) string {
+	top := b.Tophash[i]
+	s := fmt.Sprintf("cell %d: tophash %#02x (%v)", i, top, hmap.CellOf(top))
+	if b.Keys[i] != "" {
+		s += " key " + b.Keys[i]
+	}
+	return s
+}
+
+var xmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&#39;")
+
+func escape(s string) string {
+	return xmlEscaper.Replace(s)
+}
@@ -0,0 +1,132 @@
+package hmapviz
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hmap"
+)
+
+// Geometry of the SVG picture, in pixels.
+const (
+	cellW    = 22
+	cellH    = 20
+	captionW = 64
+	ptrW     = 14
+	chainGap = 22 // between a bucket and its overflow bucket
+	colGap   = 90 // between oldbuckets and buckets, where X/Y lines run
+	rowH     = cellH + 10
+	margin   = 16
+	headerH  = 80
+)
+
+// WriteSVG writes l as a standalone SVG document. Old buckets are drawn
+// on the left, each in the row of its X target, and the new buckets on
+// the right; lines between them show where each old bucket goes.
+func WriteSVG(w io.Writer, l *hmap.Layout) error {
+	bw := bufio.NewWriter(w)
+	p := func(format string, args ...any) { fmt.Fprintf(bw, format, args...) }
+
+	oldW := 0
+	if l.OldBuckets != nil {
+		oldW = chainsWidth(l.OldBuckets) + colGap
+	}
+	newX := margin + oldW
+	width := max(newX+chainsWidth(l.Buckets)+margin, 720)
+	rows := max(len(l.Buckets), len(l.OldBuckets))
+	height := headerH + rows*rowH + 2*margin
+
+	p("<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" font-family=\"monospace\" font-size=\"10\">\n", width, height)
+	p("<rect width=\"100%%\" height=\"100%%\" fill=\"white\"/>\n")
+	p("<text x=\"%d\" y=\"%d\" font-size=\"12\">%s</text>\n", margin, margin+4, escape(summary(l)))
+	p("<text x=\"%d\" y=\"%d\">%s</text>\n", margin, margin+22, escape(prealloc(l)))
+	x := margin
+	for _, c := range legend {
+		p("<rect x=\"%d\" y=\"%d\" width=\"12\" height=\"12\" fill=%q stroke=\"black\"/>", x, margin+32, Colors[c])
+		p("<text x=\"%d\" y=\"%d\">%v</text>\n", x+16, margin+42, c)
+		x += 16 + 8*len(c.String()) + 16
+	}
+
+	top := headerH + margin
+	rowY := func(i int) int { return top + i*rowH }
+
+	if l.OldBuckets != nil {
+		p("<text x=\"%d\" y=\"%d\" font-weight=\"bold\">oldbuckets</text>\n", margin, top-6)
+		writeSVGChains(p, margin, rowY, "oldbuckets", l.OldBuckets)
+
+		// X/Y lines run through the gap between the two columns.
+		x0, x1 := newX-colGap+4, newX-4
+		for i, c := range l.OldBuckets {
+			x, y := targets(l, i)
+			dash := " stroke-dasharray=\"4 3\""
+			cx, cy := colorX, colorY
+			if c.Evacuated() {
+				dash = ""
+			} else {
+				cx, cy = colorPending, colorPending
+			}
+			mid := rowY(i) + cellH/2
+			p("<line x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" stroke=%q%s><title>oldbuckets[%d] X -&gt; buckets[%d]</title></line>\n",
+				x0, mid, x1, rowY(x)+cellH/2, cx, dash, i, x)
+			if y >= 0 {
+				p("<line x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" stroke=%q%s><title>oldbuckets[%d] Y -&gt; buckets[%d]</title></line>\n",
+					x0, mid, x1, rowY(y)+cellH/2, cy, dash, i, y)
+			}
+		}
+
+		// nevacuate: every old bucket above the line has been evacuated
+		// (when shrinking, above each of the two lines in its half).
+		for _, n := range markRows(l) {
+			y := rowY(n) - (rowH-cellH)/2
+			p("<line x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" stroke=%q stroke-width=\"2\"/>\n", margin, y, newX-colGap, y, colorMark)
+			p("<text x=\"%d\" y=\"%d\" fill=%q>nevacuate=%d</text>\n", newX-colGap-70, y-2, colorMark, l.NEvacuate)
+		}
+	}
+
+	p("<text x=\"%d\" y=\"%d\" font-weight=\"bold\">buckets</text>\n", newX, top-6)
+	writeSVGChains(p, newX, rowY, "buckets", l.Buckets)
+
+	p("</svg>\n")
+	return bw.Flush()
+}
+
+func bucketW(b hmap.Bucket) int {
+	return captionW + len(b.Tophash)*cellW + ptrW
+}
+
+// chainsWidth is the width of the longest chain in chains.
+func chainsWidth(chains []hmap.Chain) int {
+	w := 0
+	for _, c := range chains {
+		cw := 0
+		for _, b := range c {
+			cw += bucketW(b) + chainGap
+		}
+		w = max(w, cw-chainGap)
+	}
+	return w
+}
+
+func writeSVGChains(p func(string, ...any), x0 int, rowY func(int) int, array string, chains []hmap.Chain) {
+	for i, c := range chains {
+		x, y := x0, rowY(i)
+		for j, b := range c {
+			if j > 0 {
+				// the overflow pointer of the previous bucket
+				p("<line x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" stroke=\"black\"/>", x-chainGap-ptrW/2, y+cellH/2, x-2, y+cellH/2)
+				p("<polygon points=\"%d,%d %d,%d %d,%d\"/>\n", x, y+cellH/2, x-5, y+cellH/2-3, x-5, y+cellH/2+3)
+			}
+			p("<text x=\"%d\" y=\"%d\">%s</text>", x+2, y+cellH-6, escape(caption(array, i, j, b)))
+			cx := x + captionW
+			for k, top := range b.Tophash {
+				p("<g><title>%s</title>", escape(cellTitle(b, k)))
+				p("<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=%q stroke=\"black\"/>", cx, y, cellW, cellH, Colors[hmap.CellOf(top)])
+				p("<text x=\"%d\" y=\"%d\" text-anchor=\"middle\">%02x</text></g>", cx+cellW/2, y+cellH-6, top)
+				cx += cellW
+			}
+			p("<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"#eeeeee\" stroke=\"black\"/>\n", cx, y, ptrW, cellH)
+			x += bucketW(b) + chainGap
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package hmapviz
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/swiss"
+)
+
+// SwissColors maps each control byte state to the fill colour used for it.
+var SwissColors = map[swiss.Ctrl]string{
+	swiss.CtrlFull:    "#f4a261",
+	swiss.CtrlEmpty:   "#ffffff",
+	swiss.CtrlDeleted: "#e5989b",
+}
+
+var swissLegend = []swiss.Ctrl{swiss.CtrlFull, swiss.CtrlEmpty, swiss.CtrlDeleted}
+
+// swissSummary is the one-line description of the map header.
+func swissSummary(l *swiss.Layout) string {
+	if l.Small != nil || l.Directory == nil {
+		return fmt.Sprintf("used=%d seed=%#x small map (one group, no directory)", l.Used, l.Seed)
+	}
+	return fmt.Sprintf("used=%d seed=%#x globalDepth=%d directory=%d entries, %d tables",
+		l.Used, l.Seed, l.GlobalDepth, len(l.Directory), len(l.Tables))
+}
+
+// tableCaption describes a table header.
+func tableCaption(i int, t swiss.Table) string {
+	return fmt.Sprintf("table %d: index=%d localDepth=%d capacity=%d used=%d growthLeft=%d tombstones=%d",
+		i, t.Index, t.LocalDepth, t.Capacity, t.Used, t.GrowthLeft, t.Tombstones)
+}
+
+// slotTitle is the hover text of a slot.
+func slotTitle(g swiss.Group, i int) string {
+	c := g.Ctrl[i]
+	s := fmt.Sprintf("slot %d: ctrl %#02x (%v)", i, c, swiss.CtrlOf(c))
+	if g.Keys[i] != "" {
+		s += fmt.Sprintf(" h2=%#02x key %s", c, g.Keys[i])
+	}
+	return s
+}
+
+// Geometry of the directory column of the Swiss SVG picture.
+const (
+	dirW   = 64
+	dirGap = 110 // between the directory and the tables, where pointers run
+)
+
+// WriteSwissSVG writes l as a standalone SVG document: the directory on
+// the left, with a pointer from every entry to its table, and each table
+// as a column of groups, one control byte per cell.
+func WriteSwissSVG(w io.Writer, l *swiss.Layout) error {
+	bw := bufio.NewWriter(w)
+	p := func(format string, args ...any) { fmt.Fprintf(bw, format, args...) }
+
+	tabX := margin + dirW + dirGap
+	if l.Directory == nil {
+		tabX = margin
+	}
+	rows := 0
+	if l.Small != nil {
+		rows = 2
+	}
+	for _, t := range l.Tables {
+		rows += 1 + len(t.Groups)
+	}
+	rows = max(rows, len(l.Directory))
+	width := max(tabX+captionW+8*cellW+margin, 720)
+	height := headerH + rows*rowH + 2*margin
+
+	p("<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" font-family=\"monospace\" font-size=\"10\">\n", width, height)
+	p("<rect width=\"100%%\" height=\"100%%\" fill=\"white\"/>\n")
+	p("<text x=\"%d\" y=\"%d\" font-size=\"12\">%s</text>\n", margin, margin+4, escape(swissSummary(l)))
+	x := margin
+	for _, c := range swissLegend {
+		p("<rect x=\"%d\" y=\"%d\" width=\"12\" height=\"12\" fill=%q stroke=\"black\"/>", x, margin+32, SwissColors[c])
+		p("<text x=\"%d\" y=\"%d\">%v</text>\n", x+16, margin+42, c)
+		x += 16 + 8*len(c.String()) + 16
+	}
+
+	top := headerH + margin
+	rowY := func(i int) int { return top + i*rowH }
+
+	row := 0
+	if l.Small != nil {
+		p("<text x=\"%d\" y=\"%d\" font-weight=\"bold\">small group</text>\n", tabX, rowY(row)+cellH-6)
+		writeSVGGroup(p, tabX, rowY(row+1), "group", *l.Small)
+	}
+
+	// Table i starts at row tabRow[i], with its caption.
+	tabRow := make([]int, len(l.Tables))
+	for i, t := range l.Tables {
+		tabRow[i] = row
+		p("<text x=\"%d\" y=\"%d\" font-weight=\"bold\">%s</text>\n", tabX, rowY(row)+cellH-6, escape(tableCaption(i, t)))
+		for j, g := range t.Groups {
+			writeSVGGroup(p, tabX, rowY(row+1+j), fmt.Sprintf("group[%d]", j), g)
+		}
+		row += 1 + len(t.Groups)
+	}
+
+	if l.Directory != nil {
+		p("<text x=\"%d\" y=\"%d\" font-weight=\"bold\">directory</text>\n", margin, top-6)
+		for i, t := range l.Directory {
+			y := rowY(i)
+			p("<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"#eeeeee\" stroke=\"black\"/>", margin, y, dirW, cellH)
+			p("<text x=\"%d\" y=\"%d\">dir[%d]</text>\n", margin+4, y+cellH-6, i)
+			ty := rowY(tabRow[t]) + cellH/2
+			p("<line x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" stroke=%q><title>dir[%d] -&gt; table %d</title></line>",
+				margin+dirW, y+cellH/2, tabX-6, ty, colorX, i, t)
+			p("<polygon points=\"%d,%d %d,%d %d,%d\" fill=%q/>\n", tabX-2, ty, tabX-7, ty-3, tabX-7, ty+3, colorX)
+		}
+	}
+
+	p("</svg>\n")
+	return bw.Flush()
+}
+
+func writeSVGGroup(p func(string, ...any), x, y int, caption string, g swiss.Group) {
+	p("<text x=\"%d\" y=\"%d\">%s</text>", x+2, y+cellH-6, escape(caption))
+	cx := x + captionW
+	for k, c := range g.Ctrl {
+		p("<g><title>%s</title>", escape(slotTitle(g, k)))
+		p("<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=%q stroke=\"black\"/>", cx, y, cellW, cellH, SwissColors[swiss.CtrlOf(c)])
+		p("<text x=\"%d\" y=\"%d\" text-anchor=\"middle\">%02x</text></g>", cx+cellW/2, y+cellH-6, c)
+		cx += cellW
+	}
+	p("\n")
+}
+
+// WriteSwissDOT writes l as a Graphviz digraph: one node for the
+// directory, one per table with a row per group, and an edge from every
+// directory entry to its table.
+func WriteSwissDOT(w io.Writer, l *swiss.Layout) error {
+	bw := bufio.NewWriter(w)
+	p := func(format string, args ...any) { fmt.Fprintf(bw, format, args...) }
+
+	p("digraph swiss {\n")
+	p("\trankdir=LR;\n")
+	p("\tnode [shape=plaintext fontname=\"monospace\" fontsize=10];\n")
+	p("\tlabel=%q;\n\tlabelloc=t;\n", swissSummary(l))
+
+	p("\tlegend [label=<<table border=\"0\" cellspacing=\"2\"><tr>")
+	for _, c := range swissLegend {
+		p("<td bgcolor=%q border=\"1\">%v</td>", SwissColors[c], c)
+	}
+	p("</tr></table>>];\n")
+
+	if l.Small != nil {
+		p("\tsmall [label=<<table border=\"0\" cellborder=\"1\" cellspacing=\"0\"><tr><td>small group</td>")
+		writeDOTGroup(p, *l.Small)
+		p("</tr></table>>];\n")
+	}
+	for i, t := range l.Tables {
+		p("\tt%d [label=<<table border=\"0\" cellborder=\"1\" cellspacing=\"0\">", i)
+		p("<tr><td colspan=\"9\" port=\"h\">%s</td></tr>", escape(tableCaption(i, t)))
+		for j, g := range t.Groups {
+			p("<tr><td>group[%d]</td>", j)
+			writeDOTGroup(p, g)
+			p("</tr>")
+		}
+		p("</table>>];\n")
+	}
+	if l.Directory != nil {
+		p("\tdirectory [label=<<table border=\"0\" cellborder=\"1\" cellspacing=\"0\">")
+		for i := range l.Directory {
+			p("<tr><td port=\"d%d\" bgcolor=\"#eeeeee\">dir[%d]</td></tr>", i, i)
+		}
+		p("</table>>];\n")
+		for i, t := range l.Directory {
+			p("\tdirectory:d%d -> t%d:h [color=%q];\n", i, t, colorX)
+		}
+	}
+	p("}\n")
+	return bw.Flush()
+}
+
+func writeDOTGroup(p func(string, ...any), g swiss.Group) {
+	for k, c := range g.Ctrl {
+		p("<td bgcolor=%q title=\"%s\">%02x</td>", SwissColors[swiss.CtrlOf(c)], escape(slotTitle(g, k)), c)
+	}
+}
@@ -0,0 +1,78 @@
+package hmapviz
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hmap"
+)
+
+// WriteText writes l as text: the hmap header, then one line per chain
+// of buckets and of old buckets. A cell shows as tophash:key when filled,
+// and otherwise as _ (emptyRest), - (emptyOne), x, y (evacuatedX, Y) or
+// e (evacuatedEmpty). Overflow buckets are marked + if they were
+// preallocated at the end of the array and * if allocated on their own.
+// A nil layout is written as "(unavailable)".
+func WriteText(out io.Writer, l *hmap.Layout) error {
+	w := bufio.NewWriter(out)
+	if l == nil {
+		fmt.Fprintln(w, "(unavailable)")
+		return w.Flush()
+	}
+	fmt.Fprintf(w, "count %d, B %d, noverflow %d, hash0 %#x, flags %#x, nevacuate %d",
+		l.Count, l.B, l.NOverflow, l.Hash0, l.Flags, l.NEvacuate)
+	switch {
+	case l.SameSizeGrow:
+		fmt.Fprint(w, ", same-size grow")
+	case l.Shrinking:
+		fmt.Fprint(w, ", shrinking")
+	case l.OldBuckets != nil:
+		fmt.Fprint(w, ", growing")
+	}
+	fmt.Fprintln(w)
+	writeTextChains(w, "bucket", l.Buckets, 1<<l.B)
+	writeTextChains(w, "old", l.OldBuckets, len(l.OldBuckets))
+	return w.Flush()
+}
+
+func writeTextChains(w io.Writer, name string, chains []hmap.Chain, nmain int) {
+	for i, c := range chains {
+		fmt.Fprintf(w, "  %s %d:", name, i)
+		for j, b := range c {
+			if j > 0 {
+				mark := "*"
+				if b.Index >= nmain {
+					mark = "+"
+				}
+				fmt.Fprintf(w, " ->%s", mark)
+			}
+			fmt.Fprint(w, " [")
+			for k, top := range b.Tophash {
+				if k > 0 {
+					fmt.Fprint(w, " ")
+				}
+				fmt.Fprint(w, CellText(top, b.Keys[k]))
+			}
+			fmt.Fprint(w, "]")
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// CellText is how WriteText shows a cell with tophash top holding key.
+func CellText(top uint8, key string) string {
+	switch hmap.CellOf(top) {
+	case hmap.CellEmptyRest:
+		return "_"
+	case hmap.CellEmptyOne:
+		return "-"
+	case hmap.CellEvacuatedX:
+		return "x"
+	case hmap.CellEvacuatedY:
+		return "y"
+	case hmap.CellEvacuatedEmpty:
+		return "e"
+	}
+	return fmt.Sprintf("%d:%s", top, key)
+}
@@ -0,0 +1,52 @@
+// Package mapopt holds what the options of packages hmap and swiss share:
+// the HMAPSEED environment variable and the adapter that puts a
+// hasher.Hasher in the place of memhash.
+/*
+	mapopt 是 hmap 与 swiss 两个包的选项共用的部分：环境变量 HMAPSEED，
+	以及把 hasher.Hasher 包装成 memhash 形式的适配函数。两个包各写一份容易改了一处忘了另一处。
+*/
+package mapopt
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"unsafe"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hasher"
+)
+
+// SeedEnv names the environment variable that puts every map created
+// without a source of its own into deterministic mode; see hmap.SeedEnv.
+const SeedEnv = "HMAPSEED"
+
+// A plainError is a panic value without the "runtime error: " prefix.
+type plainError string
+
+func (e plainError) Error() string { return string(e) }
+
+// EnvSeed reads SeedEnv once, for every map of every package. An
+// unparsable value panics on the first call rather than silently running
+// with random seeds.
+var EnvSeed = sync.OnceValues(func() (uint64, bool) {
+	s := os.Getenv(SeedEnv)
+	if s == "" {
+		return 0, false
+	}
+	seed, err := strconv.ParseUint(s, 0, 64)
+	if err != nil {
+		panic(plainError("bad " + SeedEnv + "=" + s + ": " + err.Error()))
+	}
+	return seed, true
+})
+
+// Memhash adapts h to the shape of memhash, the Mem of an rtalg.Alg, or
+// returns nil, the default, for a nil h.
+func Memhash(h hasher.Hasher) func(p unsafe.Pointer, seed, s uintptr) uintptr {
+	if h == nil {
+		return nil
+	}
+	return func(p unsafe.Pointer, seed, s uintptr) uintptr {
+		return uintptr(h.Hash(unsafe.Slice((*byte)(p), s), uint64(seed)))
+	}
+}
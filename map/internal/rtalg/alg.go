@@ -0,0 +1,305 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package rtalg ports the hash helpers that map.go reaches through
+// t.Hasher: memhash/strhash/f32hash/f64hash/typehash from runtime/alg.go
+// and the wyhash based fallback from runtime/hash64.go.
+/*
+	rtalg 是 runtime/alg.go 与 runtime/hash64.go 的用户态移植。
+	map.go 中所有 t.Hasher(key, uintptr(h.hash0)) 的调用最终都会落到这里的函数上：
+	  - 普通内存类型（int、指针、无填充的结构体等）走 memhash；
+	  - string 走 strhash；
+	  - 浮点数需要保证 +0 == -0 时 hash 相同，NaN 每次 hash 都随机；
+	  - interface、含 string/浮点的结构体与数组走 typehash，逐字段组合 hash。
+	runtime 在 amd64 上优先使用 AES 指令（aeshash），这里只移植了没有 AES 时的 wyhash 回退实现。
+*/
+package rtalg
+
+import (
+	"math/bits"
+	"math/rand/v2"
+	"reflect"
+	"unsafe"
+)
+
+// PtrSize is goarch.PtrSize.
+const PtrSize = 4 << (^uintptr(0) >> 63)
+
+const (
+	c0 = uintptr((8-PtrSize)/4*2860486313 + (PtrSize-4)/4*33054211828000289)
+	c1 = uintptr((8-PtrSize)/4*3267000013 + (PtrSize-4)/4*23344194077549503)
+)
+
+const (
+	m1 = 0xa0761d6478bd642f
+	m2 = 0xe7037ed1a0b428db
+	m3 = 0x8ebc6af09c88c6e3
+	m4 = 0x589965cc75374cc3
+	m5 = 0x1d8e4e27c47d124f
+)
+
+// hashkey is the per-process key the runtime fills in alginit.
+// The port leaves it zero: hash0 is the only seed, so that a map
+// built from a known hash0 has the same layout in every process.
+// (runtime 在 alginit 中随机初始化 hashkey，这里固定为 0，只保留 hash0 作为种子)
+var hashkey [4]uintptr
+
+// Fastrand is runtime.fastrand.
+func Fastrand() uint32 {
+	return rand.Uint32()
+}
+
+// Fastrand64 is runtime.fastrand64.
+func Fastrand64() uint64 {
+	return rand.Uint64()
+}
+
+func add(p unsafe.Pointer, x uintptr) unsafe.Pointer {
+	return unsafe.Add(p, x)
+}
+
+// Memhash hashes the s bytes at p. It is memhashFallback from runtime/hash64.go.
+func Memhash(p unsafe.Pointer, seed, s uintptr) uintptr {
+	var a, b uintptr
+	seed ^= hashkey[0] ^ m1
+	switch {
+	case s == 0:
+		return seed
+	case s < 4:
+		a = uintptr(*(*byte)(p))
+		a |= uintptr(*(*byte)(add(p, s>>1))) << 8
+		a |= uintptr(*(*byte)(add(p, s-1))) << 16
+	case s == 4:
+		a = r4(p)
+		b = a
+	case s < 8:
+		a = r4(p)
+		b = r4(add(p, s-4))
+	case s == 8:
+		a = r8(p)
+		b = a
+	case s <= 16:
+		a = r8(p)
+		b = r8(add(p, s-8))
+	default:
+		l := s
+		if l > 48 {
+			seed1 := seed
+			seed2 := seed
+			for ; l > 48; l -= 48 {
+				seed = mix(r8(p)^m2, r8(add(p, 8))^seed)
+				seed1 = mix(r8(add(p, 16))^m3, r8(add(p, 24))^seed1)
+				seed2 = mix(r8(add(p, 32))^m4, r8(add(p, 40))^seed2)
+				p = add(p, 48)
+			}
+			seed ^= seed1 ^ seed2
+		}
+		for ; l > 16; l -= 16 {
+			seed = mix(r8(p)^m2, r8(add(p, 8))^seed)
+			p = add(p, 16)
+		}
+		a = r8(add(p, l-16))
+		b = r8(add(p, l-8))
+	}
+
+	return mix(m5^s, mix(a^m2, b^seed))
+}
+
+// Memhash32 is memhash32Fallback: Memhash specialised for 4-byte keys.
+func Memhash32(p unsafe.Pointer, seed uintptr) uintptr {
+	a := r4(p)
+	return mix(m5^4, mix(a^m2, a^seed^hashkey[0]^m1))
+}
+
+// Memhash64 is memhash64Fallback: Memhash specialised for 8-byte keys.
+func Memhash64(p unsafe.Pointer, seed uintptr) uintptr {
+	a := r8(p)
+	return mix(m5^8, mix(a^m2, a^seed^hashkey[0]^m1))
+}
+
+func mix(a, b uintptr) uintptr {
+	hi, lo := bits.Mul64(uint64(a), uint64(b))
+	return uintptr(hi ^ lo)
+}
+
+func r4(p unsafe.Pointer) uintptr {
+	return uintptr(*(*uint32)(p))
+}
+
+func r8(p unsafe.Pointer) uintptr {
+	return uintptr(*(*uint64)(p))
+}
+
+// Strhash hashes the bytes of s.
+func Strhash(s string, h uintptr) uintptr {
+	return Memhash(unsafe.Pointer(unsafe.StringData(s)), h, uintptr(len(s)))
+}
+
+// Alg is the set of primitives the composite hashes are built from: Mem
+// hashes a run of plain memory and Rand supplies the random hash of a
+// NaN. A nil field means the runtime's choice, Memhash or Fastrand.
+// Replacing Mem changes the hash function of every key type at once,
+// since strings, floats, interfaces, arrays and structs all bottom out
+// in memory hashes chained through the seed.
+/*
+	Alg 把组合 hash（string、浮点、interface、数组、结构体）依赖的两个原语抽出来：
+	Mem 对一段内存求 hash，Rand 给 NaN 提供随机 hash。替换 Mem 就等于替换了所有 key 类型的 hash 函数，
+	因为复合类型最终都是逐段调用内存 hash、并把上一段的结果作为下一段的 seed 串起来的。
+*/
+type Alg struct {
+	Mem  func(p unsafe.Pointer, seed, s uintptr) uintptr
+	Rand func() uint32
+}
+
+func (a Alg) mem(p unsafe.Pointer, seed, s uintptr) uintptr {
+	if a.Mem == nil {
+		return Memhash(p, seed, s)
+	}
+	return a.Mem(p, seed, s)
+}
+
+func (a Alg) rand() uint32 {
+	if a.Rand == nil {
+		return Fastrand()
+	}
+	return a.Rand()
+}
+
+// Strhash hashes the bytes of s.
+func (a Alg) Strhash(s string, h uintptr) uintptr {
+	return a.mem(unsafe.Pointer(unsafe.StringData(s)), h, uintptr(len(s)))
+}
+
+// F32hash hashes a float32 so that +0 and -0 collide and every NaN
+// gets a fresh random hash.
+func F32hash(f float32, h uintptr) uintptr {
+	return Alg{}.F32hash(f, h)
+}
+
+// F32hash is the package-level F32hash built on a.
+func (a Alg) F32hash(f float32, h uintptr) uintptr {
+	switch {
+	case f == 0:
+		return c1 * (c0 ^ h) // +0, -0
+	case f != f:
+		return c1 * (c0 ^ h ^ uintptr(a.rand())) // any kind of NaN
+	default:
+		return a.mem(unsafe.Pointer(&f), h, 4)
+	}
+}
+
+// F64hash is F32hash for float64.
+func F64hash(f float64, h uintptr) uintptr {
+	return Alg{}.F64hash(f, h)
+}
+
+// F64hash is the package-level F64hash built on a.
+func (a Alg) F64hash(f float64, h uintptr) uintptr {
+	switch {
+	case f == 0:
+		return c1 * (c0 ^ h) // +0, -0
+	case f != f:
+		return c1 * (c0 ^ h ^ uintptr(a.rand())) // any kind of NaN
+	default:
+		return a.mem(unsafe.Pointer(&f), h, 8)
+	}
+}
+
+// C64hash hashes the real then the imaginary part.
+func C64hash(c complex64, h uintptr) uintptr {
+	return F32hash(imag(c), F32hash(real(c), h))
+}
+
+// C128hash hashes the real then the imaginary part.
+func C128hash(c complex128, h uintptr) uintptr {
+	return F64hash(imag(c), F64hash(real(c), h))
+}
+
+// Typehash computes the hash of the object of type t at address p.
+// h is the seed. Like the runtime version it panics with
+// "hash of unhashable type" for interfaces holding uncomparable values.
+func Typehash(t reflect.Type, p unsafe.Pointer, h uintptr) uintptr {
+	return Alg{}.Typehash(t, p, h)
+}
+
+// Typehash is the package-level Typehash built on a.
+func (a Alg) Typehash(t reflect.Type, p unsafe.Pointer, h uintptr) uintptr {
+	if IsRegularMemory(t) {
+		if a.Mem != nil {
+			return a.Mem(p, h, t.Size())
+		}
+		// Handle ptr sizes specially, see issue 37086.
+		switch t.Size() {
+		case 4:
+			return Memhash32(p, h)
+		case 8:
+			return Memhash64(p, h)
+		default:
+			return Memhash(p, h, t.Size())
+		}
+	}
+	switch t.Kind() {
+	case reflect.Float32:
+		return a.F32hash(*(*float32)(p), h)
+	case reflect.Float64:
+		return a.F64hash(*(*float64)(p), h)
+	case reflect.Complex64:
+		c := *(*complex64)(p)
+		return a.F32hash(imag(c), a.F32hash(real(c), h))
+	case reflect.Complex128:
+		c := *(*complex128)(p)
+		return a.F64hash(imag(c), a.F64hash(real(c), h))
+	case reflect.String:
+		return a.Strhash(*(*string)(p), h)
+	case reflect.Interface:
+		return a.interhash(t, p, h)
+	case reflect.Array:
+		e := t.Elem()
+		for i := 0; i < t.Len(); i++ {
+			h = a.Typehash(e, add(p, uintptr(i)*e.Size()), h)
+		}
+		return h
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Name == "_" {
+				continue
+			}
+			h = a.Typehash(f.Type, add(p, f.Offset), h)
+		}
+		return h
+	default:
+		// Should never happen, as typehash should only be called
+		// with comparable types.
+		panic(unhashable(t))
+	}
+}
+
+// interhash is nilinterhash/interhash: hash the dynamic type's value.
+func (a Alg) interhash(t reflect.Type, p unsafe.Pointer, h uintptr) uintptr {
+	v := reflect.NewAt(t, p).Elem()
+	if v.IsNil() {
+		return h
+	}
+	dv := v.Elem()
+	dt := dv.Type()
+	if !dt.Comparable() {
+		panic(unhashable(dt))
+	}
+	cp := reflect.New(dt)
+	cp.Elem().Set(dv)
+	return c1 * a.Typehash(dt, cp.UnsafePointer(), h^c0)
+}
+
+// HashError is the runtime.Error-like value Typehash panics with.
+type HashError string
+
+func (e HashError) Error() string { return "runtime error: " + string(e) }
+
+// RuntimeError marks HashError as a runtime error, like runtime.errorString.
+func (e HashError) RuntimeError() {}
+
+func unhashable(t reflect.Type) HashError {
+	return HashError("hash of unhashable type " + t.String())
+}
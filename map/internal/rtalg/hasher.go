@@ -0,0 +1,54 @@
+package rtalg
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// HasherFor builds t.Hasher for K. It picks the same specialisations the
+// compiler picks for map keys: memhash32/memhash64 for 4 and 8 byte plain
+// memory, memhash for other plain memory, strhash for strings, f32hash and
+// f64hash for floats and typehash for everything else.
+// With a user hasher in alg.Mem, plain memory of any size goes to it.
+func HasherFor[K comparable](alg Alg) func(key K, seed uintptr) uintptr {
+	kt := reflect.TypeFor[K]()
+	if IsRegularMemory(kt) {
+		size := kt.Size()
+		if alg.Mem != nil {
+			return func(key K, seed uintptr) uintptr {
+				return alg.Mem(unsafe.Pointer(&key), seed, size)
+			}
+		}
+		switch size {
+		case 4:
+			return func(key K, seed uintptr) uintptr {
+				return Memhash32(unsafe.Pointer(&key), seed)
+			}
+		case 8:
+			return func(key K, seed uintptr) uintptr {
+				return Memhash64(unsafe.Pointer(&key), seed)
+			}
+		default:
+			return func(key K, seed uintptr) uintptr {
+				return Memhash(unsafe.Pointer(&key), seed, size)
+			}
+		}
+	}
+	switch kt.Kind() {
+	case reflect.String:
+		return func(key K, seed uintptr) uintptr {
+			return alg.Strhash(*(*string)(unsafe.Pointer(&key)), seed)
+		}
+	case reflect.Float32:
+		return func(key K, seed uintptr) uintptr {
+			return alg.F32hash(*(*float32)(unsafe.Pointer(&key)), seed)
+		}
+	case reflect.Float64:
+		return func(key K, seed uintptr) uintptr {
+			return alg.F64hash(*(*float64)(unsafe.Pointer(&key)), seed)
+		}
+	}
+	return func(key K, seed uintptr) uintptr {
+		return alg.Typehash(kt, unsafe.Pointer(&key), seed)
+	}
+}
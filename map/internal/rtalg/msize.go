@@ -0,0 +1,31 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rtalg
+
+const (
+	maxSmallSize = 32768
+	pageSize     = 8192
+)
+
+// classToSize is class_to_size from runtime/sizeclasses.go (go1.21).
+var classToSize = [...]uint16{0, 8, 16, 24, 32, 48, 64, 80, 96, 112, 128, 144, 160, 176, 192, 208, 224, 240, 256, 288, 320, 352, 384, 416, 448, 480, 512, 576, 640, 704, 768, 896, 1024, 1152, 1280, 1408, 1536, 1792, 2048, 2304, 2688, 3072, 3200, 3456, 4096, 4864, 5376, 6144, 6528, 6784, 6912, 8192, 9472, 9728, 10240, 10880, 12288, 13568, 14336, 16384, 18432, 19072, 20480, 21760, 24576, 27264, 28672, 32768}
+
+// RoundupSize returns the size of the memory block that mallocgc will
+// allocate if you ask for the size. makeBucketArray uses it to turn the
+// slack of a size class into extra preallocated overflow buckets.
+// (申请 size 字节时 mallocgc 实际会分配的大小：小对象按 size class 向上取整，大对象按页取整)
+func RoundupSize(size uintptr) uintptr {
+	if size < maxSmallSize {
+		for _, c := range classToSize[1:] {
+			if uintptr(c) >= size {
+				return uintptr(c)
+			}
+		}
+	}
+	if size+pageSize < size {
+		return size
+	}
+	return (size + pageSize - 1) &^ (pageSize - 1)
+}
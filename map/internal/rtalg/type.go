@@ -0,0 +1,115 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rtalg
+
+import "reflect"
+
+// The predicates below recompute, with reflect, the bits the compiler
+// stores in abi.Type.TFlag and abi.MapType.Flags (see reflect.MapOf and
+// cmd/compile/internal/reflectdata/reflect.go).
+/*
+	下面这些判断函数对应编译器写进 abi.MapType.Flags 的标记位：
+	  IsReflexive   -> t.ReflexiveKey()   (k == k 是否恒成立，浮点 NaN 不满足)
+	  NeedKeyUpdate -> t.NeedKeyUpdate()  (覆盖写入时是否需要把 key 也重新拷贝一遍，比如 +0.0 / -0.0)
+	  HashMightPanic-> t.HashMightPanic() (interface 里装了不可比较类型时 hash 会 panic)
+*/
+
+// IsRegularMemory reports whether values of t can be hashed and compared
+// as plain bytes (abi.TFlagRegularMemory).
+func IsRegularMemory(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Chan, reflect.Pointer, reflect.UnsafePointer:
+		return true
+	case reflect.Array:
+		return IsRegularMemory(t.Elem())
+	case reflect.Struct:
+		var end uintptr
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Name == "_" || !IsRegularMemory(f.Type) || f.Offset != end {
+				return false
+			}
+			end = f.Offset + f.Type.Size()
+		}
+		return end == t.Size()
+	}
+	return false
+}
+
+// IsReflexive reports whether the == operation on t is reflexive,
+// that is, x == x for all values x of type t.
+func IsReflexive(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128, reflect.Interface:
+		return false
+	case reflect.Array:
+		return IsReflexive(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if !IsReflexive(t.Field(i).Type) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// NeedKeyUpdate reports whether map overwrites require the key to be copied.
+func NeedKeyUpdate(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128, reflect.Interface, reflect.String:
+		// Float keys can be updated from +0 to -0.
+		// String keys can be updated to use a smaller backing store.
+		// Interfaces might have floats or strings in them.
+		return true
+	case reflect.Array:
+		return NeedKeyUpdate(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if NeedKeyUpdate(t.Field(i).Type) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HashMightPanic reports whether the hash of a map key of type t might panic.
+func HashMightPanic(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Array:
+		return HashMightPanic(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if HashMightPanic(t.Field(i).Type) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasPointers reports whether values of t contain pointers the GC has to
+// scan (abi.Type.PtrBytes != 0).
+func HasPointers(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.UnsafePointer, reflect.Chan, reflect.Map, reflect.Func,
+		reflect.Interface, reflect.Slice, reflect.String:
+		return true
+	case reflect.Array:
+		return t.Len() > 0 && HasPointers(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if HasPointers(t.Field(i).Type) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package itercheck
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+)
+
+// Generate returns a random program with n ops. Keys are drawn from
+// [0, max(8, n)) so that deletes and overwrites hit existing keys and
+// deleted keys come back.
+func Generate(r *rand.Rand, n int) Program {
+	space := max(8, n)
+	p := Program{Seed: r.Uint64(), Shrink: r.IntN(2) == 0, Seq: r.IntN(2) == 0, Init: make([]int, r.IntN(space+1))}
+	if r.IntN(4) == 0 {
+		p.Hint = r.IntN(2 * space)
+	}
+	for i := range p.Init {
+		p.Init[i] = r.IntN(space)
+	}
+	p.Ops = make([]Op, n)
+	for i := range p.Ops {
+		switch x := r.IntN(20); {
+		case x < 8:
+			p.Ops[i] = Op{Kind: Next}
+		case x < 14:
+			p.Ops[i] = Op{Kind: Put, Key: r.IntN(space)}
+		case x < 18:
+			p.Ops[i] = Op{Kind: Delete, Key: r.IntN(space)}
+		case x == 18 && p.Shrink:
+			p.Ops[i] = Op{Kind: Prune}
+		default:
+			p.Ops[i] = Op{Kind: Grow}
+		}
+	}
+	return p
+}
+
+// Config controls Check.
+type Config struct {
+	Programs int    // random programs to run
+	Size     int    // ops per program
+	Seed     uint64 // seed for program generation
+}
+
+// A Failure is a program that broke a guarantee, shrunk as far as it
+// would go while still failing.
+type Failure struct {
+	Program   Program    // the shrunk program
+	Violation *Violation // what it broke
+	Original  Program    // the program as generated
+}
+
+func (f *Failure) Error() string {
+	return fmt.Sprintf("itercheck: %v\n%v", f.Violation, f.Program)
+}
+
+// Check runs c.Programs random programs and returns the first failure,
+// shrunk, or nil if every program passed.
+func Check(c Config) *Failure {
+	r := rand.New(rand.NewPCG(c.Seed, 0))
+	for range c.Programs {
+		p := Generate(r, c.Size)
+		if Run(p) == nil {
+			continue
+		}
+		f := &Failure{Original: p}
+		f.Program = Shrink(p, func(q Program) bool { return Run(q) != nil })
+		f.Violation = Run(f.Program)
+		return f
+	}
+	return nil
+}
+
+// Shrink returns a smaller program for which fails still reports true:
+// it removes runs of ops and init keys, halving the run length down to
+// single elements, and drops the size hint, the shrink mode and the
+// iter.Seq2 driver, until
+// nothing more can go.
+func Shrink(p Program, fails func(Program) bool) Program {
+	for changed := true; changed; {
+		changed = false
+		if q, ok := shrinkSlice(p, p.Ops, func(q *Program, s []Op) { q.Ops = s }, fails); ok {
+			p, changed = q, true
+		}
+		if q, ok := shrinkSlice(p, p.Init, func(q *Program, s []int) { q.Init = s }, fails); ok {
+			p, changed = q, true
+		}
+		if p.Hint != 0 {
+			q := p
+			q.Hint = 0
+			if fails(q) {
+				p, changed = q, true
+			}
+		}
+		if p.Shrink {
+			q := p
+			q.Shrink = false
+			if fails(q) {
+				p, changed = q, true
+			}
+		}
+		if p.Seq {
+			q := p
+			q.Seq = false
+			if fails(q) {
+				p, changed = q, true
+			}
+		}
+	}
+	return p
+}
+
+// shrinkSlice tries to drop chunks of s, the field of p that set stores,
+// and returns the smallest failing program it found.
+func shrinkSlice[E any](p Program, s []E, set func(*Program, []E), fails func(Program) bool) (Program, bool) {
+	shrunk := false
+	for chunk := len(s) / 2; chunk >= 1; chunk /= 2 {
+		for i := 0; i+chunk <= len(s); {
+			t := slices.Concat(s[:i], s[i+chunk:])
+			q := p
+			set(&q, t)
+			if fails(q) {
+				p, s, shrunk = q, t, true
+				continue // try the chunk now at i
+			}
+			i += chunk
+		}
+	}
+	return p, shrunk
+}
@@ -0,0 +1,110 @@
+// Package itercheck is a randomized checker for the iteration guarantees
+// of hmap.Map while the table grows under the iterator.
+//
+// A Program starts an iterator on a map holding some keys and then
+// interleaves iterator steps with puts, deletes and forced growth (fresh
+// inserts until a grow is in progress) or, for maps created with
+// hmap.WithShrink, forced shrinking (deletes until a shrink is in
+// progress). Run executes a program and checks
+// what the Go spec promises for range over a map:
+//
+//   - no key is produced twice, unless it was deleted and put back in
+//     between;
+//   - a produced key is in the map at that moment, with its current
+//     element, so a key deleted before it is reached is never produced;
+//   - every key present when iteration started and never deleted is
+//     produced exactly once, however many times it was overwritten.
+//
+// Keys inserted during iteration may or may not be produced. Check runs
+// many random programs and shrinks the first failure to a small program
+// that still fails.
+/*
+	itercheck 用随机程序验证 mapiterinit/mapiternext 注释里的承诺：迭代过程中 map 扩容，
+	每个 key 仍然恰好返回一次，checkBucket 会跳过要搬去另一半的 entry。
+	程序先在若干 key 上开始迭代，然后穿插 next、put、delete、强制扩容（插入新 key 直到开始扩容），
+	检查：不重复返回（删除后又插回的 key 除外）；返回的 key 此刻一定在 map 中且值是最新的（遍历到之前被删除的 key 不会出现）；
+	迭代开始时就存在且从未删除的 key 恰好返回一次。迭代中新插入的 key 返回与否都可以。
+	发现错误后会把程序收缩到仍然失败的最小操作序列。
+*/
+package itercheck
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kind is the kind of an Op.
+type Kind uint8
+
+const (
+	Next   Kind = iota // advance the iterator
+	Put                // m.Put(Key, ...): insert or overwrite
+	Delete             // m.Delete(Key)
+	Grow               // insert fresh keys until the map is growing
+	Prune              // delete keys, smallest first, until the map is growing or empty
+)
+
+var kindNames = [...]string{Next: "next", Put: "put", Delete: "delete", Grow: "grow", Prune: "prune"}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("Kind(%d)", uint8(k))
+}
+
+// An Op is one step of a Program. Key is used by Put and Delete.
+type Op struct {
+	Kind Kind
+	Key  int
+}
+
+func (o Op) String() string {
+	if o.Kind == Put || o.Kind == Delete {
+		return fmt.Sprintf("%v %d", o.Kind, o.Key)
+	}
+	return o.Kind.String()
+}
+
+// A Program is a map to build and the operations to interleave with its
+// iteration. The iterator is created after Init has been inserted and
+// before the first op; once the ops are done it is drained. The map is
+// created with hmap.WithSeed(Seed), so a program behaves the same on
+// every run, and with hmap.WithShrink(0.25) if Shrink is set. If Seq is
+// set the iterator is m.All() driven through iter.Pull2 rather than
+// m.Iter().
+type Program struct {
+	Seed   uint64
+	Shrink bool
+	Seq    bool
+	Hint   int   // size hint for hmap.New
+	Init   []int // keys inserted before iteration starts
+	Ops    []Op
+}
+
+// String renders p one op per line, in the form failures are reported.
+func (p Program) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "seed %d\n", p.Seed)
+	if p.Shrink {
+		b.WriteString("shrink\n")
+	}
+	if p.Seq {
+		b.WriteString("seq\n")
+	}
+	fmt.Fprintf(&b, "hint %d\ninit %v\n", p.Hint, p.Init)
+	for _, op := range p.Ops {
+		fmt.Fprintf(&b, "%v\n", op)
+	}
+	return b.String()
+}
+
+// A Violation describes a broken guarantee.
+type Violation struct {
+	Step int // index into Ops, or len(Ops) while draining the iterator
+	Msg  string
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("step %d: %s", v.Step, v.Msg)
+}
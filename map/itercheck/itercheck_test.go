@@ -0,0 +1,17 @@
+package itercheck
+
+import "testing"
+
+// TestCheck runs a fixed set of random programs, so that go test checks
+// the iteration guarantees on every run; cmd/itercheck runs more.
+func TestCheck(t *testing.T) {
+	programs := 2000
+	if testing.Short() {
+		programs = 200
+	}
+	for _, seed := range []uint64{1, 2} {
+		if f := Check(Config{Programs: programs, Size: 64, Seed: seed}); f != nil {
+			t.Fatalf("seed %d: %v", seed, f)
+		}
+	}
+}
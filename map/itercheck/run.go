@@ -0,0 +1,143 @@
+package itercheck
+
+import (
+	"fmt"
+	"iter"
+	"maps"
+	"slices"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hmap"
+)
+
+// freshKey is the first key Grow inserts; Put and Delete keys stay below it.
+const freshKey = 1 << 40
+
+// Run executes p once and returns the first violation, or nil. Panics
+// from the map are reported as violations too.
+//
+// Each Put stores a new element, so a stale element returned by the
+// iterator is caught as well.
+func Run(p Program) (v *Violation) {
+	step := -1
+	defer func() {
+		if e := recover(); e != nil {
+			v = &Violation{Step: step, Msg: fmt.Sprintf("panic: %v", e)}
+		}
+	}()
+
+	opts := []hmap.Option{hmap.WithSeed(p.Seed)}
+	if p.Shrink {
+		opts = append(opts, hmap.WithShrink(0.25))
+	}
+	m := hmap.New[int, int](p.Hint, opts...)
+	model := make(map[int]int) // what m should hold
+	var mustSee, seen map[int]bool
+	version := 0
+	put := func(k int) {
+		version++
+		m.Put(k, version)
+		model[k] = version
+	}
+	// A key deleted and put back is a new entry, which the iterator may
+	// produce again: deleting the last key reseeds hash0, so it can come
+	// back in a bucket the iterator has not reached yet.
+	del := func(k int) {
+		m.Delete(k)
+		delete(model, k)
+		delete(mustSee, k)
+		delete(seen, k)
+	}
+	for _, k := range p.Init {
+		put(k)
+	}
+
+	// Keys present when iteration starts; cleared when deleted.
+	mustSee = make(map[int]bool, len(model))
+	for k := range model {
+		mustSee[k] = true
+	}
+	seen = make(map[int]bool)
+	var pull func() (int, int, bool)
+	if p.Seq {
+		var stop func()
+		pull, stop = iter.Pull2(m.All())
+		defer stop()
+	} else {
+		it := m.Iter()
+		pull = func() (int, int, bool) {
+			if !it.Next() {
+				return 0, 0, false
+			}
+			return it.Key(), it.Elem(), true
+		}
+	}
+	done := false
+	next := func() *Violation {
+		if done {
+			return nil
+		}
+		k, e, ok := pull()
+		if !ok {
+			done = true
+			return nil
+		}
+		if seen[k] {
+			return &Violation{Step: step, Msg: fmt.Sprintf("key %d produced twice", k)}
+		}
+		seen[k] = true
+		want, ok := model[k]
+		if !ok {
+			return &Violation{Step: step, Msg: fmt.Sprintf("key %d produced but not in the map", k)}
+		}
+		if e != want {
+			return &Violation{Step: step, Msg: fmt.Sprintf("key %d produced with elem %d, want %d", k, e, want)}
+		}
+		return nil
+	}
+
+	fresh := freshKey
+	for i, op := range p.Ops {
+		step = i
+		switch op.Kind {
+		case Next:
+			if v := next(); v != nil {
+				return v
+			}
+		case Put:
+			put(op.Key)
+		case Delete:
+			del(op.Key)
+		case Grow:
+			for !m.Growing() {
+				put(fresh)
+				fresh++
+			}
+		case Prune:
+			keys := slices.Sorted(maps.Keys(model))
+			for i := 0; i < len(keys) && !m.Growing(); i++ {
+				del(keys[i])
+			}
+		}
+		if m.Len() != len(model) {
+			return &Violation{Step: step, Msg: fmt.Sprintf("Len() = %d, want %d", m.Len(), len(model))}
+		}
+	}
+
+	// Drain. A correct iterator stops after at most one pass over the
+	// keys ever inserted; anything longer is a loop.
+	step = len(p.Ops)
+	for limit := len(p.Init) + len(p.Ops) + fresh - freshKey + 1; !done; limit-- {
+		if limit == 0 {
+			return &Violation{Step: step, Msg: "iterator does not terminate"}
+		}
+		if v := next(); v != nil {
+			return v
+		}
+	}
+	for k := range mustSee {
+		if !seen[k] {
+			return &Violation{Step: step, Msg: fmt.Sprintf("key %d was present throughout but never produced", k)}
+		}
+	}
+	return nil
+}
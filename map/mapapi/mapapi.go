@@ -0,0 +1,24 @@
+// Package mapapi is the API every map implementation in this repository
+// provides, so that benchmarks and tools can be written once and run
+// against each of them: hmap (the bucket design of map/map.go), swiss
+// (the Swiss-table design that replaced it in Go 1.24) and the maps built
+// on top of them.
+/*
+	mapapi 定义本仓库各个 map 实现共同的接口，压测和工具只写一遍，就能同时跑在
+	hmap（map.go 的桶 + 溢出链设计）和 swiss（Go 1.24 换成的 Swiss table）上。
+*/
+package mapapi
+
+// Map is the common subset of the map implementations' methods. Each
+// method behaves like the builtin map operation of the same meaning.
+type Map[K comparable, V any] interface {
+	Len() int                         // len(m)
+	Get(key K) V                      // m[key]
+	Lookup(key K) (V, bool)           // v, ok := m[key]
+	Put(key K, elem V)                // m[key] = elem
+	Delete(key K)                     // delete(m, key)
+	Clear()                           // clear(m)
+	Range(f func(key K, elem V) bool) // for k, v := range m, stopping when f returns false
+	AppendKeys(s []K) []K             // append(s, maps.Keys(m)...)
+	AppendValues(s []V) []V           // append(s, maps.Values(m)...)
+}
@@ -0,0 +1,39 @@
+package mapfuzz
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hmap"
+	"github.com/ProsperousLi/golang-deep-learn/map/hmapviz"
+)
+
+// Replay runs p again, recording the step at which d happened: the
+// layout of the map before and after it, and the growth events (as JSON
+// lines, see hmap.JSONTracer) it emitted. after is nil if the step
+// panicked in a way that left no readable layout.
+func Replay(p Program, d *Divergence) (before, after *hmap.Layout, events string) {
+	c := &capture{step: d.Step}
+	run(p, c)
+	return c.before, c.after, c.events.String()
+}
+
+// Dump writes a report of d: the divergence, the program, and what
+// Replay records of the diverging step, with the layouts in the text
+// form of hmapviz.WriteText.
+func Dump(w io.Writer, p Program, d *Divergence) error {
+	before, after, events := Replay(p, d)
+	var b strings.Builder
+	fmt.Fprintf(&b, "divergence at %v\n\nprogram:\n%v\n", d, p)
+	fmt.Fprintf(&b, "layout before step %d:\n", d.Step)
+	hmapviz.WriteText(&b, before)
+	if events == "" {
+		events = "(none)\n"
+	}
+	fmt.Fprintf(&b, "\ngrowth events of step %d:\n%s\n", d.Step, events)
+	fmt.Fprintf(&b, "layout after step %d:\n", d.Step)
+	hmapviz.WriteText(&b, after)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
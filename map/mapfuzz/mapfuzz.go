@@ -0,0 +1,223 @@
+// Package mapfuzz is a differential fuzzing engine for hmap.Map. It
+// decodes a byte stream into a Program, a map configuration followed by
+// Put, Get, Delete, Clear, Clone, Iterate and Fill operations, runs the
+// program against an hmap.Map[float64, int] and a builtin map[float64]int
+// side by side, and reports the first step where they disagree: a Get or
+// Len that differs, a different multiset of iterated entries, a panic,
+// or a map that fails Validate.
+//
+// Keys are float64 so that the corner cases of mapassign and evacuate
+// come up: -0 overwrites the key +0 and must replace it (needKeyUpdate),
+// and every NaN is a new key with a random hash. Iteration is compared
+// as a multiset of key bits and elements, since the two maps iterate in
+// different orders.
+//
+// Run is deterministic, the map being created with hmap.WithSeed, so a
+// failing program fails the same way every time; Minimize relies on it,
+// and Dump replays a failure to print the bucket layout before and after
+// the step that diverged, with the growth events of that step.
+//
+// map/cmd/mapfuzz drives the engine with random input, and FuzzMap in
+// the package tests is its go test -fuzz target:
+//
+//	go test -fuzz=FuzzMap ./map/mapfuzz
+/*
+	mapfuzz 是 hmap.Map 的差分模糊测试引擎：把字节流解码成程序（map 的配置加上 Put、Get、Delete、Clear、Clone、
+	Iterate、Fill 操作序列），同时作用在 hmap.Map[float64, int] 和内置 map[float64]int 上，每一步之后比较
+	Get 结果、Len、遍历得到的键值多重集合，并调用 Validate 检查不变量。key 用 float64，是为了覆盖 -0 覆盖 +0
+	时需要更新 key（needKeyUpdate）、NaN 每次都是新 key 且 hash 随机这些边界情况。
+	map 用 WithSeed 创建，运行是确定的：失败的程序可以用 Minimize 收缩，再用 Dump 重放，输出出错那一步
+	前后的桶布局和这一步触发的扩容事件，便于判断是 mapassign、mapdelete 还是 evacuate 出了问题。
+*/
+package mapfuzz
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hmap"
+)
+
+// Kind is the kind of an Op.
+type Kind uint8
+
+const (
+	Put     Kind = iota // m[key] = step number
+	Get                 // compare m[key] with the builtin map
+	Delete              // delete(m, key)
+	Clear               // clear(m)
+	Clone               // compare a clone; with an odd Arg, go on with the clone
+	Iterate             // compare the entries of a range loop
+	Fill                // put Arg+1 keys not used before
+)
+
+var kindNames = [...]string{
+	Put: "put", Get: "get", Delete: "delete", Clear: "clear",
+	Clone: "clone", Iterate: "iterate", Fill: "fill",
+}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("Kind(%d)", uint8(k))
+}
+
+// opBytes maps an op byte to its kind: an op byte below opBytes[k]
+// and not below opBytes[k-1] decodes to k. Writes are the most common,
+// and the ops that throw the map away are rare.
+var opBytes = [...]int{
+	Put:     96,
+	Get:     144,
+	Delete:  200,
+	Clear:   204,
+	Clone:   214,
+	Iterate: 230,
+	Fill:    256,
+}
+
+// An Op is one step of a Program. Arg selects the key of Put, Get and
+// Delete (see Key), and parameterizes Clone and Fill.
+type Op struct {
+	Kind Kind
+	Arg  uint8
+}
+
+// Key returns the key an Arg stands for: 0 to 239 as themselves, -0,
+// NaN, or a large power-of-two multiple.
+func Key(arg uint8) float64 {
+	switch {
+	case arg < 240:
+		return float64(arg)
+	case arg == 240:
+		return math.Copysign(0, -1)
+	case arg < 244:
+		return math.NaN()
+	}
+	return math.Ldexp(float64(arg), 60)
+}
+
+func (o Op) String() string {
+	switch o.Kind {
+	case Put, Get, Delete:
+		return fmt.Sprintf("%v %v", o.Kind, formatKey(Key(o.Arg)))
+	case Clone:
+		if o.Arg&1 != 0 {
+			return "clone and continue with it"
+		}
+	case Fill:
+		return fmt.Sprintf("fill %d", int(o.Arg)+1)
+	}
+	return o.Kind.String()
+}
+
+// formatKey prints -0 as such, which %v does not.
+func formatKey(k float64) string {
+	if k == 0 && math.Signbit(k) {
+		return "-0"
+	}
+	return fmt.Sprint(k)
+}
+
+// Config is the configuration of the map under test.
+type Config struct {
+	BucketCnt int  // hmap.WithBucketCnt
+	Shrink    bool // hmap.WithShrink(0.25)
+	Indirect  bool // hmap.WithIndirect(0, 0): keys and elems out of line
+	Hint      int
+	Seed      uint8 // hmap.WithSeed
+}
+
+var bucketCnts = [...]int{8, 4, 16, 32}
+
+func (c Config) options() []hmap.Option {
+	opts := []hmap.Option{hmap.WithSeed(uint64(c.Seed))}
+	if c.BucketCnt != 0 && c.BucketCnt != 8 {
+		opts = append(opts, hmap.WithBucketCnt(c.BucketCnt))
+	}
+	if c.Shrink {
+		opts = append(opts, hmap.WithShrink(0.25))
+	}
+	if c.Indirect {
+		opts = append(opts, hmap.WithIndirect(0, 0))
+	}
+	return opts
+}
+
+// A Program is a map configuration and the ops to run on it.
+type Program struct {
+	Config Config
+	Ops    []Op
+}
+
+// Decode turns any byte stream into a Program. The first byte selects
+// the bucket size (bits 0-1), shrinking (bit 2), indirect storage (bit
+// 3) and the size hint (bits 4-7, times 8); the second is the seed.
+// Every following pair of bytes is an op byte and its Arg. Missing
+// header bytes count as zero, and an odd trailing byte is ignored.
+func Decode(data []byte) Program {
+	var hdr [2]byte
+	n := copy(hdr[:], data)
+	data = data[n:]
+	p := Program{Config: Config{
+		BucketCnt: bucketCnts[hdr[0]&3],
+		Shrink:    hdr[0]&4 != 0,
+		Indirect:  hdr[0]&8 != 0,
+		Hint:      int(hdr[0]>>4) * 8,
+		Seed:      hdr[1],
+	}}
+	for ; len(data) >= 2; data = data[2:] {
+		k := Kind(0)
+		for int(data[0]) >= opBytes[k] {
+			k++
+		}
+		p.Ops = append(p.Ops, Op{Kind: k, Arg: data[1]})
+	}
+	return p
+}
+
+// Encode returns a byte stream that Decode turns back into p.
+func Encode(p Program) []byte {
+	c := p.Config
+	var hdr byte
+	for i, n := range bucketCnts {
+		if n == c.BucketCnt {
+			hdr = byte(i)
+		}
+	}
+	if c.Shrink {
+		hdr |= 4
+	}
+	if c.Indirect {
+		hdr |= 8
+	}
+	hdr |= byte(min(c.Hint/8, 15)) << 4
+	b := []byte{hdr, c.Seed}
+	for _, op := range p.Ops {
+		first := 0
+		if op.Kind > 0 {
+			first = opBytes[op.Kind-1]
+		}
+		b = append(b, byte(first), op.Arg)
+	}
+	return b
+}
+
+// String renders p one op per line, numbered as Divergence.Step counts.
+func (p Program) String() string {
+	var b strings.Builder
+	c := p.Config
+	fmt.Fprintf(&b, "bucketcnt %d, hint %d, seed %d", c.BucketCnt, c.Hint, c.Seed)
+	if c.Shrink {
+		b.WriteString(", shrink")
+	}
+	if c.Indirect {
+		b.WriteString(", indirect")
+	}
+	b.WriteByte('\n')
+	for i, op := range p.Ops {
+		fmt.Fprintf(&b, "%4d  %v\n", i, op)
+	}
+	return b.String()
+}
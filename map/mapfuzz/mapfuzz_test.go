@@ -0,0 +1,52 @@
+package mapfuzz
+
+import (
+	"testing"
+)
+
+// FuzzMap runs decoded programs against the builtin map. Run calls
+// Validate on the map after every step and on every clone, so a write
+// that leaves the buckets, count, noverflow or nevacuate inconsistent
+// fails here even when every lookup still agrees. The seeds cover the
+// cases the engine was written for; go test -fuzz=FuzzMap explores
+// from them, and the corpus-format crash file cmd/mapfuzz writes can be
+// dropped into testdata/fuzz/FuzzMap to keep a failure as a regression.
+func FuzzMap(f *testing.F) {
+	seeds := []Program{
+		{},
+		// Grow, with writes during the incremental evacuation.
+		{Ops: []Op{{Fill, 200}, {Put, 3}, {Delete, 7}, {Iterate, 0}, {Put, 9}, {Get, 3}}},
+		// -0 replaces +0 (needKeyUpdate), across a growth.
+		{Ops: []Op{{Put, 0}, {Put, 240}, {Fill, 100}, {Get, 0}, {Iterate, 0}, {Delete, 240}, {Get, 0}}},
+		// NaN keys: every put is a new entry, no get or delete finds one.
+		{Ops: []Op{{Put, 241}, {Put, 242}, {Put, 241}, {Get, 241}, {Delete, 242}, {Iterate, 0}, {Clear, 0}}},
+		// Shrinking with 4-cell buckets after a growth.
+		{Config: Config{BucketCnt: 4, Shrink: true, Seed: 7}, Ops: []Op{
+			{Fill, 255}, {Put, 1}, {Put, 2}, {Clone, 1}, {Clear, 0}, {Put, 5}, {Delete, 5}, {Iterate, 0},
+		}},
+		// Indirect storage with a size hint, and a clone taken mid-growth.
+		{Config: Config{BucketCnt: 16, Indirect: true, Hint: 40, Seed: 3}, Ops: []Op{
+			{Fill, 120}, {Clone, 0}, {Put, 250}, {Clone, 1}, {Delete, 250}, {Iterate, 0},
+		}},
+	}
+	for _, p := range seeds {
+		f.Add(Encode(p))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if d := Run(Decode(data)); d != nil {
+			t.Fatal(d)
+		}
+	})
+}
+
+// TestEncode checks that Decode inverts Encode, which Minimize and the
+// crash files rely on.
+func TestEncode(t *testing.T) {
+	p := Program{Config: Config{BucketCnt: 32, Shrink: true, Indirect: true, Hint: 120, Seed: 9}}
+	for k := range Fill + 1 {
+		p.Ops = append(p.Ops, Op{Kind: k, Arg: uint8(40 * k)})
+	}
+	if q := Decode(Encode(p)); q.String() != p.String() {
+		t.Errorf("Decode(Encode(p)) =\n%v\nwant\n%v", q, p)
+	}
+}
@@ -0,0 +1,50 @@
+package mapfuzz
+
+import "slices"
+
+// Minimize returns a smaller program that still diverges: it removes runs
+// of ops, halving the run length down to single ops, shrinks the Args of
+// Fill, and resets the configuration to the default map, keeping each
+// change only if Run still reports a divergence.
+func Minimize(p Program) Program {
+	fails := func(q Program) bool { return Run(q) != nil }
+	for changed := true; changed; {
+		changed = false
+		for chunk := len(p.Ops) / 2; chunk >= 1; chunk /= 2 {
+			for i := 0; i+chunk <= len(p.Ops); {
+				q := p
+				q.Ops = slices.Concat(p.Ops[:i], p.Ops[i+chunk:])
+				if fails(q) {
+					p, changed = q, true
+					continue // try the chunk now at i
+				}
+				i += chunk
+			}
+		}
+		for i, op := range p.Ops {
+			for op.Kind == Fill && op.Arg > 0 {
+				op.Arg /= 2
+				q := p
+				q.Ops = slices.Clone(p.Ops)
+				q.Ops[i] = op
+				if !fails(q) {
+					break
+				}
+				p, changed = q, true
+			}
+		}
+		for _, simpler := range []func(*Config){
+			func(c *Config) { c.BucketCnt = 8 },
+			func(c *Config) { c.Shrink = false },
+			func(c *Config) { c.Indirect = false },
+			func(c *Config) { c.Hint = 0 },
+		} {
+			q := p
+			simpler(&q.Config)
+			if q.Config != p.Config && fails(q) {
+				p, changed = q, true
+			}
+		}
+	}
+	return p
+}
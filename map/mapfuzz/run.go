@@ -0,0 +1,176 @@
+package mapfuzz
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"math"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hmap"
+)
+
+// A Divergence is the first step at which the map under test stopped
+// behaving like the builtin map.
+type Divergence struct {
+	Step int // index into Ops
+	Op   Op
+	Msg  string
+}
+
+func (d *Divergence) Error() string {
+	return fmt.Sprintf("step %d (%v): %s", d.Step, d.Op, d.Msg)
+}
+
+// Run executes p and returns the first divergence, or nil.
+func Run(p Program) *Divergence {
+	return run(p, nil)
+}
+
+// capture records the layouts around one step and the growth events
+// that step emits.
+type capture struct {
+	step          int
+	before, after *hmap.Layout
+	events        bytes.Buffer
+	json          *hmap.JSONTracer
+	recording     bool
+}
+
+func (c *capture) Trace(e hmap.Event) {
+	if c.recording {
+		c.json.Trace(e)
+	}
+}
+
+func run(p Program, c *capture) (d *Divergence) {
+	opts := p.Config.options()
+	if c != nil {
+		c.json = hmap.NewJSONTracer(&c.events)
+		opts = append(opts, hmap.WithTracer(c))
+	}
+	m := hmap.New[float64, int](p.Config.Hint, opts...)
+	model := make(map[float64]int, p.Config.Hint)
+	fresh := 0
+
+	step := 0
+	defer func() {
+		if e := recover(); e != nil {
+			d = &Divergence{Step: step, Op: p.Ops[step], Msg: fmt.Sprintf("panic: %v", e)}
+			if c != nil && c.recording {
+				c.after = layoutAfterPanic(m)
+			}
+		}
+	}()
+	for i, op := range p.Ops {
+		step = i
+		if c != nil && c.step == i {
+			c.before, c.recording = m.Layout(), true
+		}
+		msg := ""
+		switch op.Kind {
+		case Put:
+			k := Key(op.Arg)
+			m.Put(k, i)
+			model[k] = i
+			msg = lookup(m, model, k)
+		case Get:
+			msg = lookup(m, model, Key(op.Arg))
+		case Delete:
+			k := Key(op.Arg)
+			m.Delete(k)
+			delete(model, k)
+			msg = lookup(m, model, k)
+		case Clear:
+			m.Clear()
+			clear(model)
+		case Clone:
+			cm, cmodel := m.Clone(), maps.Clone(model)
+			if msg = compare(cm, cmodel); msg != "" {
+				msg = "clone: " + msg
+			} else if err := cm.Validate(); err != nil {
+				msg = "clone: " + err.Error()
+			}
+			if op.Arg&1 != 0 {
+				m, model = cm, cmodel
+			}
+		case Iterate:
+			msg = compare(m, model)
+		case Fill:
+			for range int(op.Arg) + 1 {
+				k := float64(1000 + fresh)
+				fresh++
+				m.Put(k, i)
+				model[k] = i
+			}
+		}
+		if msg == "" && m.Len() != len(model) {
+			msg = fmt.Sprintf("Len() = %d, builtin len %d", m.Len(), len(model))
+		}
+		if msg == "" {
+			if err := m.Validate(); err != nil {
+				msg = err.Error()
+			}
+		}
+		if c != nil && c.step == i {
+			c.after, c.recording = m.Layout(), false
+		}
+		if msg != "" {
+			return &Divergence{Step: i, Op: op, Msg: msg}
+		}
+	}
+	return nil
+}
+
+// layoutAfterPanic returns the layout of a map that panicked in the
+// middle of a write, or nil if even that fails.
+func layoutAfterPanic(m *hmap.Map[float64, int]) (l *hmap.Layout) {
+	defer func() { recover() }()
+	return m.Layout()
+}
+
+// lookup compares m[k] with the builtin map.
+func lookup(m *hmap.Map[float64, int], model map[float64]int, k float64) string {
+	got, ok := m.Lookup(k)
+	want, wok := model[k]
+	if got != want || ok != wok {
+		return fmt.Sprintf("m[%v] = %d, %v; builtin %d, %v", formatKey(k), got, ok, want, wok)
+	}
+	return ""
+}
+
+// entry is a key, by its bits so that -0 and NaN compare, and its elem.
+type entry struct {
+	bits uint64
+	elem int
+}
+
+// compare checks that a range loop over m produces the entries of the
+// builtin map, each once.
+func compare(m *hmap.Map[float64, int], model map[float64]int) string {
+	want := make(map[entry]int, len(model))
+	for k, v := range model {
+		want[entry{math.Float64bits(k), v}]++
+	}
+	msg := ""
+	m.Range(func(k float64, v int) bool {
+		e := entry{math.Float64bits(k), v}
+		if want[e] == 0 {
+			msg = fmt.Sprintf("iteration produced %v: %d, which the builtin map does not hold", formatKey(k), v)
+			if w, ok := model[k]; ok {
+				msg += fmt.Sprintf(" (it has %d)", w)
+			}
+			return false
+		}
+		want[e]--
+		return true
+	})
+	if msg != "" {
+		return msg
+	}
+	for e, n := range want {
+		if n > 0 {
+			return fmt.Sprintf("iteration missed %v: %d", formatKey(math.Float64frombits(e.bits)), e.elem)
+		}
+	}
+	return ""
+}
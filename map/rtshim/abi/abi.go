@@ -0,0 +1,83 @@
+// Package abi stands in for internal/abi in the generated copy of
+// map/map.go. It declares only the constants, fields and methods that
+// map.go touches, with the same names, so the copied code compiles as is.
+/*
+	abi 包模拟 internal/abi，只提供 map.go 用到的常量、字段和方法，名字保持一致，
+	这样 map.go 的代码不需要任何修改就能编译。
+	Type 额外带一个 RType 字段，shim 用它来分配内存和做带写屏障的拷贝。
+*/
+package abi
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// Map constants common to several packages
+// runtime/runtime-gdb.py:MapTypePrinter contains its own copy
+const (
+	MapBucketCountBits = 3 // log2 of number of elements in a bucket.
+	MapBucketCount     = 1 << MapBucketCountBits
+	MapMaxKeyBytes     = 128 // Must fit in a uint8.
+	MapMaxElemBytes    = 128 // Must fit in a uint8.
+)
+
+// Type is the part of the runtime type descriptor map.go reads.
+type Type struct {
+	Size_    uintptr
+	PtrBytes uintptr // number of (prefix) bytes in the type that can contain pointers
+	Align_   uint8   // alignment of variable with this type
+	// function for comparing objects of this type
+	// (ptr to object A, ptr to object B) -> ==?
+	Equal func(unsafe.Pointer, unsafe.Pointer) bool
+
+	// RType is the reflect.Type this descriptor was built from. The shim
+	// allocates, copies and clears through it so the GC sees typed memory.
+	RType reflect.Type
+}
+
+// MapType is abi.MapType.
+type MapType struct {
+	Type
+	Key    *Type
+	Elem   *Type
+	Bucket *Type // internal type representing a hash bucket
+	// function for hashing keys (ptr to key, seed) -> hash
+	Hasher     func(unsafe.Pointer, uintptr) uintptr
+	KeySize    uint8  // size of key slot
+	ValueSize  uint8  // size of elem slot
+	BucketSize uint16 // size of bucket
+	Flags      uint32
+}
+
+// Note: flag values must match those used in the TMAP case
+// in ../cmd/compile/internal/reflectdata/reflect.go:writeType.
+const (
+	MapIndirectKey    = 1  // store ptr to key instead of key itself
+	MapIndirectElem   = 2  // store ptr to elem instead of elem itself
+	MapReflexiveKey   = 4  // k==k for all keys
+	MapNeedKeyUpdate  = 8  // need to update key on an overwrite
+	MapHashMightPanic = 16 // hash function might panic
+)
+
+func (mt *MapType) IndirectKey() bool { // store ptr to key instead of key itself
+	return mt.Flags&MapIndirectKey != 0
+}
+func (mt *MapType) IndirectElem() bool { // store ptr to elem instead of elem itself
+	return mt.Flags&MapIndirectElem != 0
+}
+func (mt *MapType) ReflexiveKey() bool { // true if k==k for all keys
+	return mt.Flags&MapReflexiveKey != 0
+}
+func (mt *MapType) NeedKeyUpdate() bool { // true if we need to update key on an overwrite
+	return mt.Flags&MapNeedKeyUpdate != 0
+}
+func (mt *MapType) HashMightPanic() bool { // true if hash function might panic
+	return mt.Flags&MapHashMightPanic != 0
+}
+
+// FuncPCABIInternal returns the entry PC of the function f. The runtime
+// uses it only to label race detector reports.
+func FuncPCABIInternal(f interface{}) uintptr {
+	return reflect.ValueOf(f).Pointer()
+}
@@ -0,0 +1,17 @@
+// Package atomic stands in for runtime/internal/atomic in the generated
+// copy of map/map.go.
+package atomic
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// Or8 atomically performs *ptr |= val. sync/atomic has no 8-bit
+// operations, so it ORs val into the aligned 32-bit word holding ptr.
+// (sync/atomic 没有 8 位原子操作，这里对包含该字节的 4 字节对齐字做原子或)
+func Or8(ptr *uint8, val uint8) {
+	off := uintptr(unsafe.Pointer(ptr)) & 3
+	word := (*uint32)(unsafe.Add(unsafe.Pointer(ptr), -int(off)))
+	atomic.OrUint32(word, uint32(val)<<(off*8)) // little-endian
+}
@@ -0,0 +1,6 @@
+// Package goarch stands in for internal/goarch in the generated copy of
+// map/map.go.
+package goarch
+
+// PtrSize is the size of a pointer in bytes - unsafe.Sizeof(uintptr(0)) but as an ideal constant.
+const PtrSize = 4 << (^uintptr(0) >> 63)
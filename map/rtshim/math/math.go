@@ -0,0 +1,12 @@
+// Package math stands in for runtime/internal/math in the generated copy
+// of map/map.go.
+package math
+
+import "math/bits"
+
+// MulUintptr returns a * b and whether the multiplication overflowed.
+// On supported platforms this is an intrinsic lowered by the compiler.
+func MulUintptr(a, b uintptr) (uintptr, bool) {
+	hi, lo := bits.Mul(uint(a), uint(b))
+	return uintptr(lo), hi != 0
+}
@@ -0,0 +1,117 @@
+// Mkrtmap copies the annotated map/map.go into the rtshim runtime package,
+// applying only the mechanical edits needed for it to compile outside
+// GOROOT:
+//
+//   - stray prose lines between declarations ("TODO", "====", the version
+//     note on line 1) become line comments;
+//   - internal/abi, internal/goarch, runtime/internal/atomic and
+//     runtime/internal/math are redirected to the shim packages;
+//   - //go:linkname directives are disabled, since the std runtime
+//     already provides reflect.makemap, maps.clone and friends;
+//   - the assembly-only mapinitnoop gets an empty body.
+//
+// Everything else, including every annotation, is copied verbatim.
+/*
+	mkrtmap 把 map/map.go 复制到 rtshim/runtime 包中，只做让它能在 GOROOT 之外编译的机械修改：
+	夹在声明之间的说明文字改成注释、internal 包的 import 指向 shim 包、
+	禁用 go:linkname（标准库 runtime 已经提供了这些符号）、给汇编实现的 mapinitnoop 补一个空函数体。
+	注释原样保留。
+*/
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"log"
+	"os"
+	"strings"
+)
+
+const shimRoot = "github.com/ProsperousLi/golang-deep-learn/map/rtshim/"
+
+// rewrites are applied to every line of map.go, at most once per line.
+var rewrites = map[string]string{
+	`"internal/abi"`:            `"` + shimRoot + `abi"`,
+	`"internal/goarch"`:         `"` + shimRoot + `goarch"`,
+	`"runtime/internal/atomic"`: `"` + shimRoot + `atomic"`,
+	`"runtime/internal/math"`:   `"` + shimRoot + `math"`,
+	`func mapinitnoop()`:        `func mapinitnoop() {}`,
+	`//go:linkname`:             `// go:linkname`,
+}
+
+func main() {
+	in := flag.String("in", "../../map.go", "annotated map source")
+	out := flag.String("out", "map_gen.go", "output file")
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("mkrtmap: ")
+
+	src, err := os.ReadFile(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	lines := strings.Split(string(src), "\n")
+	for i := range lines {
+		for from, to := range rewrites {
+			if strings.Contains(lines[i], from) {
+				lines[i] = strings.Replace(lines[i], from, to, 1)
+			}
+		}
+	}
+
+	// Comment out prose lines one parse error at a time. Only lines that
+	// start at column 1 outside any declaration are touched; any other
+	// error means map.go itself is broken and is reported as is.
+	for {
+		fset := token.NewFileSet()
+		_, err := parser.ParseFile(fset, *in, strings.Join(lines, "\n"), parser.ParseComments)
+		if err == nil {
+			break
+		}
+		list, ok := err.(scanner.ErrorList)
+		if !ok || len(list) == 0 {
+			log.Fatal(err)
+		}
+		pos := list[0].Pos
+		n := pos.Line - 1
+		if pos.Column != 1 || !isProse(lines[n]) {
+			log.Fatal(err)
+		}
+		lines[n] = "// " + lines[n]
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by mkrtmap from %s; DO NOT EDIT.\n\n", "map/map.go")
+	buf.WriteString(strings.Join(lines, "\n"))
+	// gofmt re-indents the mixed tab/space block comments of map.go a
+	// little more on each pass; run it until the output is stable.
+	formatted := buf.Bytes()
+	for {
+		next, err := format.Source(formatted)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if bytes.Equal(next, formatted) {
+			break
+		}
+		formatted = next
+	}
+	if err := os.WriteFile(*out, formatted, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// isProse reports whether l cannot start a Go declaration.
+func isProse(l string) bool {
+	for _, kw := range []string{"package", "import", "const", "var", "type", "func", "//", "/*", ")", "}"} {
+		if strings.HasPrefix(l, kw) {
+			return false
+		}
+	}
+	return strings.TrimSpace(l) != ""
+}
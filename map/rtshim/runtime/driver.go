@@ -0,0 +1,191 @@
+package runtime
+
+import (
+	"reflect"
+	"sync"
+	"unsafe"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/internal/rtalg"
+	"github.com/ProsperousLi/golang-deep-learn/map/rtshim/abi"
+)
+
+// maptypes caches one maptype per map type, as the compiler emits one
+// type descriptor per map type.
+var maptypes sync.Map // reflect.Type -> *maptype
+
+// mapTypeOf builds the maptype the compiler would emit for map[kt]et,
+// following reflect.MapOf and bucketOf: keys and elems larger than 128
+// bytes are stored indirectly, and the overflow word is a uintptr when
+// the bucket has no other pointers so that the GC never scans it.
+/*
+	mapTypeOf 按照 reflect.MapOf / bucketOf 的规则构造 maptype：
+	key/elem 超过 128 字节时桶里只存指针（IndirectKey/IndirectElem）；
+	如果 key 和 elem 都不含指针，溢出指针用 uintptr 表示，整个桶被标记为无指针，GC 不会扫描，
+	这正是 map.go 里 mapextra.overflow 存在的原因。
+*/
+func mapTypeOf(kt, et reflect.Type) *maptype {
+	mt := reflect.MapOf(kt, et)
+	if t, ok := maptypes.Load(mt); ok {
+		return t.(*maptype)
+	}
+
+	t := &maptype{Type: *typeOf(mt), Key: typeOf(kt), Elem: typeOf(et)}
+	kslot, eslot := kt, et
+	if kt.Size() > maxKeySize {
+		kslot = reflect.PointerTo(kt)
+		t.Flags |= abi.MapIndirectKey
+	}
+	if et.Size() > maxElemSize {
+		eslot = reflect.PointerTo(et)
+		t.Flags |= abi.MapIndirectElem
+	}
+	overflow := reflect.TypeFor[uintptr]()
+	if rtalg.HasPointers(kslot) || rtalg.HasPointers(eslot) {
+		overflow = reflect.TypeFor[unsafe.Pointer]()
+	}
+	t.Bucket = typeOf(reflect.StructOf([]reflect.StructField{
+		{Name: "Topbits", Type: reflect.ArrayOf(bucketCnt, reflect.TypeFor[uint8]())},
+		{Name: "Keys", Type: reflect.ArrayOf(bucketCnt, kslot)},
+		{Name: "Elems", Type: reflect.ArrayOf(bucketCnt, eslot)},
+		{Name: "Overflow", Type: overflow},
+	}))
+	t.KeySize = uint8(kslot.Size())
+	t.ValueSize = uint8(eslot.Size())
+	t.BucketSize = uint16(t.Bucket.Size_)
+	t.Hasher = func(p unsafe.Pointer, seed uintptr) uintptr {
+		return rtalg.Typehash(kt, p, seed)
+	}
+	if rtalg.IsReflexive(kt) {
+		t.Flags |= abi.MapReflexiveKey
+	}
+	if rtalg.NeedKeyUpdate(kt) {
+		t.Flags |= abi.MapNeedKeyUpdate
+	}
+	if rtalg.HashMightPanic(kt) {
+		t.Flags |= abi.MapHashMightPanic
+	}
+
+	actual, _ := maptypes.LoadOrStore(mt, t)
+	return actual.(*maptype)
+}
+
+// typeOf builds the abi.Type fields map.go reads for t.
+func typeOf(t reflect.Type) *_type {
+	at := &_type{Size_: t.Size(), Align_: uint8(t.Align()), RType: t}
+	if rtalg.HasPointers(t) {
+		at.PtrBytes = t.Size()
+	}
+	switch {
+	case !t.Comparable():
+	case rtalg.IsRegularMemory(t):
+		size := t.Size()
+		at.Equal = func(p, q unsafe.Pointer) bool {
+			return unsafe.String((*byte)(p), size) == unsafe.String((*byte)(q), size)
+		}
+	case t.Kind() == reflect.String:
+		at.Equal = func(p, q unsafe.Pointer) bool {
+			return *(*string)(p) == *(*string)(q)
+		}
+	default:
+		at.Equal = func(p, q unsafe.Pointer) bool {
+			return reflect.NewAt(t, p).Elem().Equal(reflect.NewAt(t, q).Elem())
+		}
+	}
+	return at
+}
+
+// Map is a map[K]V whose every operation runs the annotated code in
+// map_gen.go: New calls reflect_makemap, Put calls reflect_mapassign,
+// Clone goes through mapclone and mapclone2, Keys and Values through
+// keys and values, and Range through mapiterinit and mapiternext.
+type Map[K comparable, V any] struct {
+	t *maptype
+	h *hmap
+}
+
+// New returns a map with room for about hint elements, like make(map[K]V, hint).
+func New[K comparable, V any](hint int) *Map[K, V] {
+	t := mapTypeOf(reflect.TypeFor[K](), reflect.TypeFor[V]())
+	return &Map[K, V]{t: t, h: reflect_makemap(t, hint)}
+}
+
+// Len returns the number of elements.
+func (m *Map[K, V]) Len() int {
+	return reflect_maplen(m.h)
+}
+
+// Get returns m[key], reading through mapaccess1 (or mapaccess1_fat for
+// elements larger than zeroVal, as the compiler does).
+func (m *Map[K, V]) Get(key K) V {
+	var zero V
+	if m.t.Elem.Size_ > maxZero {
+		return *(*V)(mapaccess1_fat(m.t, m.h, unsafe.Pointer(&key), unsafe.Pointer(&zero)))
+	}
+	return *(*V)(mapaccess1(m.t, m.h, unsafe.Pointer(&key)))
+}
+
+// Lookup returns m[key] and whether it was present, through mapaccess2.
+func (m *Map[K, V]) Lookup(key K) (V, bool) {
+	p, ok := mapaccess2(m.t, m.h, unsafe.Pointer(&key))
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	return *(*V)(p), true
+}
+
+// Put sets m[key] = elem.
+func (m *Map[K, V]) Put(key K, elem V) {
+	reflect_mapassign(m.t, m.h, unsafe.Pointer(&key), unsafe.Pointer(&elem))
+}
+
+// Delete removes key.
+func (m *Map[K, V]) Delete(key K) {
+	mapdelete(m.t, m.h, unsafe.Pointer(&key))
+}
+
+// Clear removes all elements with mapclear.
+func (m *Map[K, V]) Clear() {
+	mapclear(m.t, m.h)
+}
+
+// Clone returns a copy of m made by mapclone.
+func (m *Map[K, V]) Clone() *Map[K, V] {
+	c := mapclone(m.iface())
+	return &Map[K, V]{t: m.t, h: (*hmap)(efaceOf(&c).data)}
+}
+
+// Keys returns the keys of m, filled in by keys().
+func (m *Map[K, V]) Keys() []K {
+	s := make([]K, 0, m.Len())
+	keys(m.iface(), unsafe.Pointer(&s))
+	return s
+}
+
+// Values returns the elements of m, filled in by values().
+func (m *Map[K, V]) Values() []V {
+	s := make([]V, 0, m.Len())
+	values(m.iface(), unsafe.Pointer(&s))
+	return s
+}
+
+// Range calls f for each entry until f returns false.
+func (m *Map[K, V]) Range(f func(key K, elem V) bool) {
+	var it hiter
+	for mapiterinit(m.t, m.h, &it); it.key != nil; mapiternext(&it) {
+		if !f(*(*K)(it.key), *(*V)(it.elem)) {
+			return
+		}
+	}
+}
+
+// iface packs m the way the compiler passes a map to maps.clone, maps.keys
+// and maps.values: an interface whose type word is the maptype. The value
+// must only be handed to those functions, which unpack it with efaceOf.
+func (m *Map[K, V]) iface() any {
+	var e any
+	ep := efaceOf(&e)
+	ep._type = &m.t.Type
+	ep.data = unsafe.Pointer(m.h)
+	return e
+}
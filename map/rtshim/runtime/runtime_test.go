@@ -0,0 +1,114 @@
+package runtime
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"testing"
+)
+
+// differential runs random writes against a Map[K, V] and a builtin map,
+// forcing a GC between rounds, and compares them after each round. key
+// and elem build the keys and elements from small integers.
+func differential[K comparable, V comparable](t *testing.T, key func(int) K, elem func(int) V) {
+	r := rand.New(rand.NewPCG(1, 2))
+	m := New[K, V](0)
+	model := make(map[K]V)
+	for round := range 20 {
+		for i := range 200 {
+			k := key(r.IntN(500))
+			switch r.IntN(4) {
+			case 0, 1:
+				m.Put(k, elem(i))
+				model[k] = elem(i)
+			case 2:
+				m.Delete(k)
+				delete(model, k)
+			case 3:
+				got, ok := m.Lookup(k)
+				want, wok := model[k]
+				if got != want || ok != wok {
+					t.Fatalf("round %d: m[%v] = %v, %v; builtin %v, %v", round, k, got, ok, want, wok)
+				}
+			}
+		}
+		goruntime.GC()
+		if round == 10 {
+			m = m.Clone()
+			goruntime.GC()
+		}
+		if m.Len() != len(model) {
+			t.Fatalf("round %d: Len() = %d, builtin len %d", round, m.Len(), len(model))
+		}
+		n := 0
+		m.Range(func(k K, v V) bool {
+			n++
+			if want, ok := model[k]; !ok || v != want {
+				t.Fatalf("round %d: range produced %v: %v, builtin %v, %v", round, k, v, want, ok)
+			}
+			return true
+		})
+		if n != len(model) || len(m.Keys()) != n || len(m.Values()) != n {
+			t.Fatalf("round %d: range produced %d entries, builtin len %d", round, n, len(model))
+		}
+	}
+	m.Clear()
+	if m.Len() != 0 {
+		t.Errorf("Len() after Clear = %d", m.Len())
+	}
+}
+
+// big is over maxKeySize and maxElemSize, so the bucket holds pointers
+// to it (IndirectKey, IndirectElem).
+type big struct {
+	s string
+	n [20]int64
+}
+
+// TestDifferential runs map_gen.go through typedmemmove and
+// memclrHasPointers with pointerful keys and elems, inline and indirect.
+func TestDifferential(t *testing.T) {
+	t.Run("int", func(t *testing.T) {
+		differential(t, func(i int) int { return i }, func(i int) int { return i })
+	})
+	t.Run("string", func(t *testing.T) {
+		ptrs := make([]*int, 500)
+		for i := range ptrs {
+			ptrs[i] = new(int)
+		}
+		differential(t, func(i int) string { return fmt.Sprint("key", i) },
+			func(i int) *int { return ptrs[i%len(ptrs)] })
+	})
+	t.Run("indirect", func(t *testing.T) {
+		differential(t, func(i int) big { return big{s: fmt.Sprint(i), n: [20]int64{19: int64(i)}} },
+			func(i int) big { return big{s: fmt.Sprint("elem", i)} })
+	})
+}
+
+// TestGenerated checks that map_gen.go is what mkrtmap makes of map/map.go
+// now, so that an edit to either shows up here.
+func TestGenerated(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go run")
+	}
+	out := filepath.Join(t.TempDir(), "map_gen.go")
+	cmd := exec.Command("go", "run", "../mkrtmap", "-in", "../../map.go", "-out", out)
+	if b, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("mkrtmap: %v\n%s", err, b)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("map_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("map_gen.go is stale; run go generate in map/rtshim/runtime")
+	}
+}
@@ -0,0 +1,164 @@
+// Package runtime runs the annotated map/map.go outside GOROOT.
+//
+// map_gen.go is a mechanical copy of map/map.go (see mkrtmap); this file
+// supplies every runtime symbol it refers to: the allocator entry points,
+// typedmemmove and the memclr helpers, fastrand, roundupsize, the race,
+// msan and asan hooks (all disabled), and the string fast paths from
+// map_faststr.go. driver.go builds maptype descriptors with reflect and
+// exposes the annotated functions through a small generic API.
+//
+// Import it under another name, e.g.
+//
+//	import rtmap "github.com/ProsperousLi/golang-deep-learn/map/rtshim/runtime"
+/*
+	本包让带注释的 map/map.go 能在 GOROOT 之外编译运行。
+	map_gen.go 由 mkrtmap 从 map/map.go 机械生成；本文件补齐 map.go 依赖的 runtime 符号：
+	内存分配、typedmemmove、memclr、fastrand、roundupsize、race/msan/asan 钩子（全部关闭），
+	以及 map_faststr.go 中 string key 的快速路径。driver.go 用 reflect 构造 maptype 并对外暴露泛型 API。
+*/
+package runtime
+
+//go:generate go run ../mkrtmap -in ../../map.go -out map_gen.go
+
+import (
+	"math/rand/v2"
+	"reflect"
+	"unsafe"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/internal/rtalg"
+	"github.com/ProsperousLi/golang-deep-learn/map/rtshim/abi"
+)
+
+type (
+	_type   = abi.Type
+	maptype = abi.MapType
+)
+
+const (
+	raceenabled = false
+	msanenabled = false
+	asanenabled = false
+
+	maxAlloc = (1 << 48) - 1 // 64-bit linux
+)
+
+func add(p unsafe.Pointer, x uintptr) unsafe.Pointer {
+	return unsafe.Add(p, x)
+}
+
+func fastrand() uint32 {
+	return rand.Uint32()
+}
+
+func fastrand64() uint64 {
+	return rand.Uint64()
+}
+
+func roundupsize(size uintptr) uintptr {
+	return rtalg.RoundupSize(size)
+}
+
+// newobject and newarray allocate through reflect so that the memory has
+// the pointer bitmap of t and the GC scans it like runtime-allocated memory.
+func newobject(t *_type) unsafe.Pointer {
+	return reflect.New(t.RType).UnsafePointer()
+}
+
+func newarray(t *_type, n int) unsafe.Pointer {
+	return reflect.MakeSlice(reflect.SliceOf(t.RType), n, n).UnsafePointer()
+}
+
+// typedmemmove copies a value of type t to dst from src, with the write
+// barriers the GC needs.
+func typedmemmove(t *_type, dst, src unsafe.Pointer) {
+	if dst == src {
+		return
+	}
+	if t.PtrBytes == 0 {
+		copy(unsafe.Slice((*byte)(dst), t.Size_), unsafe.Slice((*byte)(src), t.Size_))
+		return
+	}
+	runtime_typedmemmove(rtypeOf(t.RType), dst, src)
+}
+
+func memclrNoHeapPointers(ptr unsafe.Pointer, n uintptr) {
+	clear(unsafe.Slice((*byte)(ptr), n))
+}
+
+// memclrHasPointers clears n bytes of typed memory at ptr, with the
+// deletion barriers the GC needs.
+func memclrHasPointers(ptr unsafe.Pointer, n uintptr) {
+	runtime_memclrHasPointers(ptr, n)
+}
+
+// abiType is the runtime's *abi.Type behind a reflect.Type.
+type abiType struct{}
+
+// rtypeOf returns the *abi.Type a reflect.Type wraps: reflect.Type is an
+// interface whose data word is the runtime type descriptor.
+func rtypeOf(t reflect.Type) *abiType {
+	return (*abiType)((*[2]unsafe.Pointer)(unsafe.Pointer(&t))[1])
+}
+
+//go:linkname runtime_typedmemmove runtime.typedmemmove
+func runtime_typedmemmove(typ *abiType, dst, src unsafe.Pointer)
+
+//go:linkname runtime_memclrHasPointers runtime.memclrHasPointers
+func runtime_memclrHasPointers(ptr unsafe.Pointer, n uintptr)
+
+// The race detector, memory sanitizer and address sanitizer hooks. The
+// shim is never built into the instrumented runtime, so they do nothing.
+func getcallerpc() uintptr                                                 { return 0 }
+func racereadpc(addr unsafe.Pointer, callerpc, pc uintptr)                 {}
+func racewritepc(addr unsafe.Pointer, callerpc, pc uintptr)                {}
+func raceReadObjectPC(t *_type, addr unsafe.Pointer, callerpc, pc uintptr) {}
+func msanread(addr unsafe.Pointer, sz uintptr)                             {}
+func asanread(addr unsafe.Pointer, sz uintptr)                             {}
+
+// A plainError is a runtime error without the "runtime error: " prefix.
+type plainError string
+
+func (e plainError) RuntimeError() {}
+
+func (e plainError) Error() string { return string(e) }
+
+// fatal and throw abort the runtime; here they panic so that a driver
+// can recover and report which check fired.
+func fatal(s string) {
+	panic(plainError("fatal error: " + s))
+}
+
+func throw(s string) {
+	panic(plainError("fatal error: " + s))
+}
+
+// eface is the layout of an interface{} value.
+type eface struct {
+	_type *_type
+	data  unsafe.Pointer
+}
+
+func efaceOf(ep *any) *eface {
+	return (*eface)(unsafe.Pointer(ep))
+}
+
+// slice is the layout of a slice header.
+type slice struct {
+	array unsafe.Pointer
+	len   int
+	cap   int
+}
+
+// The string fast paths from map_faststr.go, which reflect_* call. They
+// take the generic path: the bucket layout is the same either way.
+func mapaccess2_faststr(t *maptype, h *hmap, ky string) (unsafe.Pointer, bool) {
+	return mapaccess2(t, h, unsafe.Pointer(&ky))
+}
+
+func mapassign_faststr(t *maptype, h *hmap, s string) unsafe.Pointer {
+	return mapassign(t, h, unsafe.Pointer(&s))
+}
+
+func mapdelete_faststr(t *maptype, h *hmap, ky string) {
+	mapdelete(t, h, unsafe.Pointer(&ky))
+}
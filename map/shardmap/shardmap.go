@@ -0,0 +1,241 @@
+// Package shardmap is a concurrent map built from hmap.Maps. Keys are
+// spread over a power-of-two number of shards by the high bits of their
+// hash, and each shard is an hmap.Map behind its own sync.RWMutex, so
+// readers of a shard proceed in parallel and writers to different shards
+// do not contend.
+//
+// A plain map (or hmap.Map) written from several goroutines at once hits
+// the hashWriting check and dies with fatal("concurrent map writes"); a
+// shardmap.Map may be used from any number of goroutines.
+/*
+	shardmap 是基于 hmap 的并发 map。map.go 检测到并发写（hashWriting）会直接 fatal，
+	进程无法 recover。这里按 key 的 hash 高位把 key 分到 2^n 个分片，每个分片是一个加了读写锁的 hmap.Map：
+	同一分片的读可以并行，不同分片的写互不影响。
+	分片用的 hash 与分片内 hmap 的 hash0 是两个独立的种子：如果用同一个 hash，一个分片里所有 key 的高位都相同，
+	而 hmap 正是用高 8 位做 tophash，会让 tophash 失去区分度。
+*/
+package shardmap
+
+import (
+	"math/bits"
+	"math/rand/v2"
+	"runtime"
+	"sync"
+	"unsafe"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hmap"
+	"github.com/ProsperousLi/golang-deep-learn/map/internal/mapopt"
+	"github.com/ProsperousLi/golang-deep-learn/map/internal/rtalg"
+)
+
+// cacheLineSize is the padding unit that keeps the locks of neighbouring
+// shards off the same cache line.
+const cacheLineSize = 64
+
+type shard[K comparable, V any] struct {
+	mu sync.RWMutex
+	m  *hmap.Map[K, V]
+	_  [cacheLineSize - (unsafe.Sizeof(sync.RWMutex{})+unsafe.Sizeof(uintptr(0)))%cacheLineSize]byte
+}
+
+// Map is a concurrent hash map. The zero Map is not usable; create one
+// with New. Its methods mirror those of sync.Map.
+type Map[K comparable, V any] struct {
+	hash   func(key K, seed uintptr) uintptr
+	seed   uintptr
+	shift  uint // bits.UintSize - log2(len(shards))
+	shards []shard[K, V]
+}
+
+// An Option configures a Map created by New.
+type Option func(*config)
+
+type config struct {
+	shards  int
+	hint    int
+	mapOpts []hmap.Option
+}
+
+// WithShards sets the number of shards, rounded up to a power of two.
+// The default is 4*GOMAXPROCS, rounded up.
+func WithShards(n int) Option {
+	return func(c *config) { c.shards = n }
+}
+
+// WithHint sizes the map for about hint elements in total.
+func WithHint(hint int) Option {
+	return func(c *config) { c.hint = hint }
+}
+
+// WithMapOptions passes opts to hmap.New for every shard. A hasher given
+// with hmap.WithHasher applies within the shards only; shards are always
+// chosen with the runtime hash. Do not pass hmap.WithRand or WithSeed:
+// every shard would draw from the one source, and even a source per
+// shard is drawn from by Loads of NaN keys, which hold only the read
+// lock. With hmap.SeedEnv set, each shard gets a PCG source of its own
+// seeded from it, behind a mutex.
+func WithMapOptions(opts ...hmap.Option) Option {
+	return func(c *config) { c.mapOpts = append(c.mapOpts, opts...) }
+}
+
+// New returns an empty Map configured by opts.
+func New[K comparable, V any](opts ...Option) *Map[K, V] {
+	c := &config{shards: 4 * runtime.GOMAXPROCS(0)}
+	for _, o := range opts {
+		o(c)
+	}
+	n := 1
+	if c.shards > 1 {
+		n = 1 << bits.Len(uint(c.shards-1))
+	}
+	m := &Map[K, V]{
+		hash:   rtalg.HasherFor[K](rtalg.Alg{}),
+		seed:   uintptr(rtalg.Fastrand64()),
+		shift:  uint(bits.UintSize - bits.TrailingZeros(uint(n))),
+		shards: make([]shard[K, V], n),
+	}
+	seed, seeded := mapopt.EnvSeed()
+	for i := range m.shards {
+		opts := c.mapOpts
+		if seeded {
+			src := &lockedSource{src: rand.NewPCG(seed, uint64(i))}
+			opts = append([]hmap.Option{hmap.WithRand(src)}, opts...)
+		}
+		m.shards[i].m = hmap.New[K, V](c.hint/n, opts...)
+	}
+	return m
+}
+
+// lockedSource is a rand.Source that may be used from several
+// goroutines. A shard's map draws from its source when it hashes a NaN
+// key, and Load and LoadOrStore do that under the read lock.
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Uint64()
+}
+
+// shardFor returns the shard of key: the top log2(len(shards)) bits of
+// its hash. With a single shard the shift is the full word and the
+// result is 0.
+func (m *Map[K, V]) shardFor(key K) *shard[K, V] {
+	return &m.shards[m.hash(key, m.seed)>>m.shift]
+}
+
+// Load returns the value stored for key, and whether it was present.
+func (m *Map[K, V]) Load(key K) (value V, ok bool) {
+	s := m.shardFor(key)
+	s.mu.RLock()
+	value, ok = s.m.Lookup(key)
+	s.mu.RUnlock()
+	return value, ok
+}
+
+// Store sets the value for key.
+func (m *Map[K, V]) Store(key K, value V) {
+	s := m.shardFor(key)
+	s.mu.Lock()
+	s.m.Put(key, value)
+	s.mu.Unlock()
+}
+
+// LoadOrStore returns the existing value for key if present. Otherwise
+// it stores and returns value. loaded reports whether the value was
+// already there.
+func (m *Map[K, V]) LoadOrStore(key K, value V) (actual V, loaded bool) {
+	s := m.shardFor(key)
+	// Most LoadOrStore calls in practice find the key; try under the
+	// read lock first.
+	s.mu.RLock()
+	actual, loaded = s.m.Lookup(key)
+	s.mu.RUnlock()
+	if loaded {
+		return actual, true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if actual, loaded = s.m.Lookup(key); loaded {
+		return actual, true
+	}
+	s.m.Put(key, value)
+	return value, false
+}
+
+// LoadAndDelete deletes the value for key, returning the previous value
+// if any.
+func (m *Map[K, V]) LoadAndDelete(key K) (value V, loaded bool) {
+	s := m.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if value, loaded = s.m.Lookup(key); loaded {
+		s.m.Delete(key)
+	}
+	return value, loaded
+}
+
+// Delete deletes the value for key.
+func (m *Map[K, V]) Delete(key K) {
+	s := m.shardFor(key)
+	s.mu.Lock()
+	s.m.Delete(key)
+	s.mu.Unlock()
+}
+
+// Len returns the number of elements. Concurrent writes may make it
+// stale by the time it returns.
+func (m *Map[K, V]) Len() int {
+	n := 0
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		n += s.m.Len()
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// Clear deletes every entry, one shard at a time.
+func (m *Map[K, V]) Clear() {
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.Lock()
+		s.m.Clear()
+		s.mu.Unlock()
+	}
+}
+
+// Range calls f for each key and value until f returns false. Like
+// sync.Map.Range it is not a snapshot of the whole map: each shard is
+// copied under its lock and f runs without any lock held, so f may call
+// any method of m, and a key stored or deleted concurrently may or may
+// not be seen. No key is visited twice.
+//
+// The copy takes the write lock, not the read lock: mapiterinit sets the
+// iterator bits in h.flags, which the runtime does with an atomic Or8
+// and the port does with a plain store.
+func (m *Map[K, V]) Range(f func(key K, value V) bool) {
+	var keys []K
+	var values []V
+	for i := range m.shards {
+		s := &m.shards[i]
+		keys, values = keys[:0], values[:0]
+		s.mu.Lock()
+		s.m.Range(func(k K, v V) bool {
+			keys = append(keys, k)
+			values = append(values, v)
+			return true
+		})
+		s.mu.Unlock()
+		for j, k := range keys {
+			if !f(k, values[j]) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package shardmap
+
+import (
+	"math"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"sync"
+	"testing"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hmap"
+)
+
+// TestStress runs every method from several goroutines at once; run it
+// with -race. Each goroutine writes its own keys, so it can check them
+// at the end, and reads everyone's. The shared keys go through
+// LoadOrStore only, so every goroutine must see the value of the first.
+func TestStress(t *testing.T) {
+	const (
+		workers = 8
+		keys    = 500 // per worker
+		shared  = 100
+	)
+	ops := 20000
+	if testing.Short() {
+		ops = 2000
+	}
+	m := New[int, int](WithShards(4))
+	got := make([][shared]int, workers)
+	models := make([]map[int]int, workers)
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := rand.New(rand.NewPCG(uint64(w), 0))
+			model := make(map[int]int)
+			for i := range ops {
+				k := w*keys + r.IntN(keys)
+				switch r.IntN(10) {
+				case 0, 1, 2:
+					m.Store(k, i)
+					model[k] = i
+				case 3:
+					v, loaded := m.LoadOrStore(k, i)
+					if want, ok := model[k]; loaded != ok || loaded && v != want {
+						t.Errorf("LoadOrStore(%d) = %d, %v; want %d, %v", k, v, loaded, want, ok)
+					} else if !loaded {
+						model[k] = i
+					}
+				case 4:
+					v, loaded := m.LoadAndDelete(k)
+					if want, ok := model[k]; loaded != ok || v != want {
+						t.Errorf("LoadAndDelete(%d) = %d, %v; want %d, %v", k, v, loaded, want, ok)
+					}
+					delete(model, k)
+				case 5:
+					m.Delete(k)
+					delete(model, k)
+				case 6:
+					s := r.IntN(shared)
+					v, _ := m.LoadOrStore(-1-s, w)
+					if got[w][s] != 0 && got[w][s] != v+1 {
+						t.Errorf("LoadOrStore(%d) = %d, then %d", -1-s, got[w][s]-1, v)
+					}
+					got[w][s] = v + 1
+				case 7:
+					if i%100 == 0 {
+						n := 0
+						m.Range(func(k, v int) bool {
+							n++
+							return n < 50
+						})
+					}
+				default:
+					v, ok := m.Load(k)
+					if want, wok := model[k]; ok != wok || v != want {
+						t.Errorf("Load(%d) = %d, %v; want %d, %v", k, v, ok, want, wok)
+					}
+					m.Load(r.IntN(workers * keys))
+				}
+			}
+			models[w] = model
+		}()
+	}
+	wg.Wait()
+
+	want := make(map[int]int)
+	for w, model := range models {
+		for k, v := range model {
+			want[k] = v
+		}
+		for s, v := range got[w] {
+			if v == 0 {
+				continue
+			}
+			if prev, ok := want[-1-s]; ok && prev != v-1 {
+				t.Errorf("key %d: workers saw %d and %d", -1-s, prev, v-1)
+			}
+			want[-1-s] = v - 1
+		}
+	}
+	if m.Len() != len(want) {
+		t.Errorf("Len() = %d, want %d", m.Len(), len(want))
+	}
+	seen := make(map[int]bool)
+	m.Range(func(k, v int) bool {
+		if seen[k] || want[k] != v {
+			t.Errorf("Range produced %d: %d, want %d once", k, v, want[k])
+		}
+		seen[k] = true
+		return true
+	})
+	m.Clear()
+	if m.Len() != 0 {
+		t.Errorf("Len() after Clear = %d", m.Len())
+	}
+}
+
+// TestSeedEnvNaN runs itself with HMAPSEED set and loads NaN keys from
+// several goroutines, which draws from the shards' random sources under
+// the read lock. Under -race it fails if those sources are not guarded.
+func TestSeedEnvNaN(t *testing.T) {
+	if os.Getenv("SHARDMAP_TEST_SEEDENV") == "" {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestSeedEnvNaN$")
+		cmd.Env = append(os.Environ(), "SHARDMAP_TEST_SEEDENV=1", hmap.SeedEnv+"=1")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%v\n%s", err, out)
+		}
+		return
+	}
+	m := New[float64, int](WithShards(1))
+	m.Store(math.NaN(), 1)
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1000 {
+				if _, ok := m.Load(math.NaN()); ok {
+					t.Error("Load(NaN) found an entry")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// store is what the benchmarks need from each implementation.
+type store interface {
+	Load(key int) (int, bool)
+	Store(key, value int)
+}
+
+type syncMap struct{ m sync.Map }
+
+func (s *syncMap) Load(key int) (int, bool) {
+	v, ok := s.m.Load(key)
+	if !ok {
+		return 0, false
+	}
+	return v.(int), true
+}
+
+func (s *syncMap) Store(key, value int) { s.m.Store(key, value) }
+
+type mutexMap struct {
+	mu sync.Mutex
+	m  map[int]int
+}
+
+func (s *mutexMap) Load(key int) (int, bool) {
+	s.mu.Lock()
+	v, ok := s.m[key]
+	s.mu.Unlock()
+	return v, ok
+}
+
+func (s *mutexMap) Store(key, value int) {
+	s.mu.Lock()
+	s.m[key] = value
+	s.mu.Unlock()
+}
+
+// benchmark fills s with 1<<16 keys and then loads a random key, or
+// stores it one time in (100-reads)/100, from every P at once.
+func benchmark(b *testing.B, s store, reads int) {
+	const keys = 1 << 16
+	for k := range keys {
+		s.Store(k, k)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		r := rand.New(rand.NewPCG(rand.Uint64(), 0))
+		for pb.Next() {
+			k := r.IntN(keys)
+			if r.IntN(100) < reads {
+				s.Load(k)
+			} else {
+				s.Store(k, k)
+			}
+		}
+	})
+}
+
+func benchmarks(b *testing.B, reads int) {
+	b.Run("shardmap", func(b *testing.B) { benchmark(b, New[int, int](), reads) })
+	b.Run("sync.Map", func(b *testing.B) { benchmark(b, new(syncMap), reads) })
+	b.Run("mutex", func(b *testing.B) { benchmark(b, &mutexMap{m: make(map[int]int)}, reads) })
+}
+
+func BenchmarkRead100(b *testing.B) { benchmarks(b, 100) }
+func BenchmarkRead90(b *testing.B)  { benchmarks(b, 90) }
+func BenchmarkRead50(b *testing.B)  { benchmarks(b, 50) }
@@ -0,0 +1,48 @@
+package swiss
+
+import "github.com/ProsperousLi/golang-deep-learn/map/mapapi"
+
+// Len returns the number of elements, like len(m).
+func (m *Map[K, V]) Len() int {
+	return m.used
+}
+
+// Get returns the element for key, or the zero value, like m[key].
+func (m *Map[K, V]) Get(key K) V {
+	if _, e, ok := m.getWithKey(key); ok {
+		return *e
+	}
+	var zero V
+	return zero
+}
+
+// Lookup is the comma-ok form of Get, like v, ok := m[key].
+func (m *Map[K, V]) Lookup(key K) (V, bool) {
+	if _, e, ok := m.getWithKey(key); ok {
+		return *e, true
+	}
+	var zero V
+	return zero, false
+}
+
+// Put sets the element for key, like m[key] = elem.
+func (m *Map[K, V]) Put(key K, elem V) {
+	*m.PutSlot(key) = elem
+}
+
+// Delete removes key, like delete(m, key).
+func (m *Map[K, V]) Delete(key K) {
+	m.delete(key)
+}
+
+// Clear removes every element but keeps the tables, like clear(m).
+func (m *Map[K, V]) Clear() {
+	m.clear()
+}
+
+// Clone returns a copy of m, like maps.Clone.
+func (m *Map[K, V]) Clone() *Map[K, V] {
+	return m.clone()
+}
+
+var _ mapapi.Map[int, int] = (*Map[int, int])(nil)
@@ -0,0 +1,55 @@
+// Package swiss is a user-space, generic port of the Swiss-table map that
+// replaced the design annotated in map/map.go (internal/runtime/maps as of
+// go1.24). It has the API of package hmap, so the same programs, tracers
+// and pictures can be run against both.
+//
+// What changed relative to map/map.go:
+//
+//   - Buckets and tophash become groups and control words. A group is 8
+//     slots plus one 8-byte control word; each control byte is ctrlEmpty,
+//     ctrlDeleted, or 0..127 for a full slot. The whole word is compared at
+//     once with SWAR bit tricks (matchH2, matchEmpty) instead of looping
+//     over tophash bytes.
+//   - The hash is split into H1 (the upper 57 bits, which pick the first
+//     group of the probe sequence) and H2 (the lower 7 bits, stored in the
+//     control byte). The classic map used the low B bits to pick a bucket
+//     and the top 8 bits as tophash.
+//   - Overflow chains are gone. A full group sends the probe on to another
+//     group of the same table by quadratic probing, and a group that still
+//     has an empty slot ends every probe sequence that reaches it.
+//   - emptyOne/emptyRest become ctrlEmpty/ctrlDeleted. A delete can only
+//     leave a tombstone (ctrlDeleted) in a group with no empty slot, since
+//     only full groups lie in the middle of probe sequences.
+//   - Load factor 6.5 per bucket becomes 7/8 of the slots, and growth is no
+//     longer incremental. The map is a directory of tables, each holding at
+//     most 1024 slots. A full table either doubles in a single step or,
+//     at the maximum size, splits in two on the next bit of the hash
+//     (extendible hashing). So one write rehashes at most 1024 slots, and
+//     there is nothing like oldbuckets, evacuate or nevacuate.
+//   - Maps of up to 8 entries are a single group with no table and no
+//     directory.
+//   - The iterator still starts at a random offset, but it cannot follow
+//     entries as they are evacuated. It keeps the table it started on and,
+//     once that table is replaced, looks up every key it finds in the live
+//     map.
+//
+// Function names follow internal/runtime/maps (getWithKey, PutSlot,
+// uncheckedPutSlot, rehash, split, installTableSplit, ...).
+/*
+	swiss 包是 Go 1.24 起取代 map.go 那套设计的 Swiss table 的可运行版本（泛型），
+	公开 API 与 hmap 包相同，同一个程序、tracer、可视化工具可以在两种实现上对比运行。
+
+	与 map.go（tophash + 溢出链）的区别：
+	  - 桶变成 group：8 个 slot 加一个 8 字节的控制字，每个控制字节是 empty、deleted 或
+	    hash 的低 7 位（H2）。查找时用 SWAR 位运算一次比较 8 个字节，而不是逐个比较 tophash。
+	  - hash 的高 57 位（H1）决定探测起点，低 7 位（H2）存进控制字节；旧设计是低 B 位选桶、
+	    高 8 位做 tophash。
+	  - 没有溢出桶：group 满了就按二次探测去同一张表里的下一个 group，遇到还有空位的 group 探测结束。
+	  - 删除时只有 group 已满才留下墓碑（deleted），否则直接置为 empty。
+	  - 装载因子从每桶 6.5 变为 7/8；扩容不再是渐进式的。map 是一个由若干张表组成的目录
+	   （可扩展哈希），每张表最多 1024 个 slot，满了就一次性翻倍，到上限后按 hash 的下一位分裂成两张，
+	    所以一次写入最多重新插入 1024 个 slot，也就没有 oldbuckets/evacuate/nevacuate 了。
+	  - 不超过 8 个元素的小 map 只有一个 group，没有表也没有目录。
+	  - 迭代器无法跟随搬迁，它保留开始时的那张表，表被替换后对其中每个 key 去新 map 里重新查找。
+*/
+package swiss
@@ -0,0 +1,146 @@
+package swiss
+
+import "math/bits"
+
+const (
+	// groupSlots is the number of slots in a group (abi.SwissMapGroupSlots).
+	groupSlots = 8
+
+	// maxAvgGroupLoad is the maximum number of full slots per group, on
+	// average, before a table grows: a load factor of 7/8.
+	maxAvgGroupLoad = 7
+
+	// maxTableCapacity is the largest number of slots a table may have.
+	// A full table of this size splits instead of growing, which bounds
+	// the work a single write can do.
+	maxTableCapacity = 1024
+)
+
+// A ctrl is one control byte:
+//
+//	empty:   1 0 0 0 0 0 0 0
+//	deleted: 1 1 1 1 1 1 1 0
+//	full:    0 h h h h h h h  // h is the 7 bit H2 of the key's hash
+//
+// The high bit alone tells full from not full; empty and deleted differ
+// in bit 1, which matchEmpty uses.
+type ctrl uint8
+
+const (
+	ctrlEmpty   ctrl = 0b10000000
+	ctrlDeleted ctrl = 0b11111110
+)
+
+// A ctrlGroup is the 8 control bytes of a group, slot 0 in the low byte.
+type ctrlGroup uint64
+
+func (g *ctrlGroup) get(i uintptr) ctrl {
+	return ctrl(*g >> (8 * i))
+}
+
+func (g *ctrlGroup) set(i uintptr, c ctrl) {
+	*g = *g&^(0xff<<(8*i)) | ctrlGroup(c)<<(8*i)
+}
+
+// setEmpty sets every control byte to ctrlEmpty.
+func (g *ctrlGroup) setEmpty() {
+	*g = ctrlGroup(bitsetEmpty)
+}
+
+// A bitset holds the result of a match over a control word: the high bit
+// of byte i is set if slot i matched.
+type bitset uint64
+
+const (
+	bitsetLSB   = 0x0101010101010101
+	bitsetMSB   = 0x8080808080808080
+	bitsetEmpty = bitsetLSB * uint64(ctrlEmpty)
+)
+
+// matchH2 returns the slots whose control byte equals h. It is the
+// classic "has zero byte" trick applied to g xor (h in every byte), and
+// may report a false positive for a byte just above a true match, which
+// is harmless because every candidate's key is compared anyway.
+/*
+	把 h 复制到 8 个字节后与控制字异或，相等的字节变成 0，再用"找零字节"的位运算一次找出所有候选 slot。
+	对应旧实现里 for i := 0; i < bucketCnt; i++ { if b.tophash[i] != top ... } 的循环。
+*/
+func (g ctrlGroup) matchH2(h uintptr) bitset {
+	v := uint64(g) ^ (bitsetLSB * uint64(h))
+	return bitset(((v - bitsetLSB) &^ v) & bitsetMSB)
+}
+
+// matchEmpty returns the empty slots. Shifting by 6 lines bit 1 of each
+// byte up with its bit 7; only ctrlEmpty has bit 7 set and bit 1 clear.
+func (g ctrlGroup) matchEmpty() bitset {
+	v := uint64(g)
+	return bitset((v &^ (v << 6)) & bitsetMSB)
+}
+
+// matchEmptyOrDeleted returns the slots that are not full.
+func (g ctrlGroup) matchEmptyOrDeleted() bitset {
+	return bitset(uint64(g) & bitsetMSB)
+}
+
+// matchFull returns the full slots.
+func (g ctrlGroup) matchFull() bitset {
+	return bitset(^uint64(g) & bitsetMSB)
+}
+
+// first returns the index of the first matched slot. b must not be 0.
+func (b bitset) first() uintptr {
+	return uintptr(bits.TrailingZeros64(uint64(b))) >> 3
+}
+
+// removeFirst clears the first matched slot.
+func (b bitset) removeFirst() bitset {
+	return b & (b - 1)
+}
+
+// A slot holds one key and its element.
+type slot[K comparable, V any] struct {
+	key  K
+	elem V
+}
+
+// A group is the unit of probing: 8 control bytes and 8 slots.
+// (相当于旧实现的 bmap，但没有 overflow 指针)
+type group[K comparable, V any] struct {
+	ctrls ctrlGroup
+	slots [groupSlots]slot[K, V]
+}
+
+// isFull reports whether control byte c marks a full slot.
+func isFull(c ctrl) bool {
+	return c&ctrlEmpty == 0
+}
+
+// h1 is the part of the hash that selects groups, h2 the 7 bits stored
+// in the control byte.
+func h1(h uintptr) uintptr {
+	return h >> 7
+}
+
+func h2(h uintptr) uintptr {
+	return h & 0x7f
+}
+
+// probeSeq is the quadratic (triangular) probe sequence over the groups
+// of a table: offset, offset+1, offset+3, offset+6, ... modulo the
+// number of groups. With a power-of-two group count it visits every
+// group exactly once before repeating.
+type probeSeq struct {
+	mask   uint64
+	offset uint64
+	index  uint64
+}
+
+func makeProbeSeq(hash uintptr, mask uint64) probeSeq {
+	return probeSeq{mask: mask, offset: uint64(hash) & mask}
+}
+
+func (s probeSeq) next() probeSeq {
+	s.index++
+	s.offset = (s.offset + s.index) & s.mask
+	return s
+}
@@ -0,0 +1,250 @@
+package swiss
+
+import "slices"
+
+// Iter is a map iterator, the runtime's maps.Iter.
+//
+// The classic iterator could follow entries into the new bucket array
+// because evacuation was incremental and deterministic. A Swiss table
+// is rehashed in one go, so the iterator keeps a reference to the table
+// it is walking. When that table has been replaced (index == -1) it
+// keeps using the old groups to choose which keys to return, but looks
+// each one up in the live map to get its current element and to skip
+// keys deleted since.
+/*
+	旧迭代器靠 evacuate 的确定性跟着元素走；Swiss table 一次性重建，迭代器只能继续拿着旧表决定
+	遍历哪些 key，再回到新 map 里查找每个 key 的当前值，查不到说明已被删除，跳过。
+*/
+type Iter[K comparable, V any] struct {
+	key  *K // nil when iteration is done
+	elem *V
+
+	m *Map[K, V]
+
+	// Randomize the iteration order by starting at a random slot
+	// offset within each table and a random offset into the directory.
+	entryOffset uint64
+	dirOffset   uint64
+
+	// clearSeq is m.clearSeq at iteration start.
+	clearSeq uint64
+
+	// globalDepth is m.globalDepth at the last call to next.
+	globalDepth uint8
+
+	// dirIdx is the current directory index before adding dirOffset,
+	// or -1 if the map was small when the iteration started.
+	dirIdx int
+
+	// tab is the table at dirIdx during the previous call to next.
+	tab *table[K, V]
+
+	// group is the group at entryIdx during the previous call to next.
+	group *group[K, V]
+
+	// entryIdx is the current entry index before adding entryOffset,
+	// counting slots across the groups of tab.
+	entryIdx uint64
+
+	started bool
+}
+
+// Iter returns an iterator positioned before the first entry.
+func (m *Map[K, V]) Iter() *Iter[K, V] {
+	return &Iter[K, V]{m: m}
+}
+
+// init is Iter.Init, called on the first Next.
+func (it *Iter[K, V]) init() {
+	m := it.m
+	if m.used == 0 {
+		it.m = nil
+		return
+	}
+	if m.directory == nil {
+		// dirIdx == -1 is the sentinel for a map that was small at
+		// Init; group stays the small group for the whole iteration.
+		it.dirIdx = -1
+		it.group = m.small
+	}
+	it.entryOffset = m.random()
+	it.dirOffset = m.random()
+	it.globalDepth = m.globalDepth
+	it.clearSeq = m.clearSeq
+}
+
+// Next advances the iterator and reports whether an entry is available.
+func (it *Iter[K, V]) Next() bool {
+	if !it.started {
+		it.started = true
+		it.init()
+	}
+	it.next()
+	return it.key != nil
+}
+
+// Key returns the key of the current entry.
+func (it *Iter[K, V]) Key() K {
+	return *it.key
+}
+
+// Elem returns the element of the current entry.
+func (it *Iter[K, V]) Elem() V {
+	return *it.elem
+}
+
+func (it *Iter[K, V]) next() {
+	m := it.m
+	if m == nil {
+		it.key, it.elem = nil, nil
+		return
+	}
+	if m.writing != 0 {
+		fatal("concurrent map iteration and map write")
+	}
+
+	if it.dirIdx < 0 {
+		// Map was small at Init.
+		for ; it.entryIdx < groupSlots; it.entryIdx++ {
+			k := uintptr(it.entryIdx+it.entryOffset) % groupSlots
+			if !isFull(it.group.ctrls.get(k)) {
+				continue
+			}
+			key, elem := &it.group.slots[k].key, &it.group.slots[k].elem
+
+			// As below, if the map has grown to a full map since
+			// Init, the old group still decides which keys to return,
+			// but they must be looked up again in the new tables.
+			if m.directory != nil {
+				var ok bool
+				if key, elem, ok = it.grownKeyElem(key, elem); !ok {
+					continue
+				}
+			}
+			it.entryIdx++
+			it.key, it.elem = key, elem
+			return
+		}
+		it.key, it.elem = nil, nil
+		return
+	}
+
+	if it.globalDepth != m.globalDepth {
+		// The directory has grown since the last call. The current
+		// position dirIdx + dirOffset (mod len(directory)) must be
+		// scaled by the same factor as the directory:
+		//
+		//	before:  0: t1   1: t2 <- dirIdx
+		//	after:   0: t1a  1: t1b  2: t2 <- dirIdx  3: t2
+		//
+		// Since A*(B mod C) == (A*B) mod (A*C), shifting dirIdx and
+		// dirOffset left does that.
+		orders := m.globalDepth - it.globalDepth
+		it.dirIdx <<= orders
+		it.dirOffset <<= orders
+		it.globalDepth = m.globalDepth
+	}
+
+	for ; it.dirIdx < len(m.directory); it.nextDirIdx() {
+		// Resolve the table.
+		if it.tab == nil {
+			dirIdx := int((uint64(it.dirIdx) + it.dirOffset) & uint64(len(m.directory)-1))
+			newTab := m.directory[dirIdx]
+			if newTab.index != dirIdx {
+				// nextDirIdx skips every entry of a table, so this only
+				// happens on the first call, when the random dirOffset
+				// may land in the middle of a table's run of entries.
+				// Move the offset back to the start of the run.
+				diff := dirIdx - newTab.index
+				it.dirOffset -= uint64(diff)
+			}
+			it.tab = newTab
+		}
+
+		// Use it.tab, not the directory: if the table has grown, the
+		// old table still decides which keys are returned.
+		entryMask := uint64(it.tab.capacity) - 1
+		for ; it.entryIdx <= entryMask; it.entryIdx++ {
+			entryIdx := (it.entryIdx + it.entryOffset) & entryMask
+			slotIdx := uintptr(entryIdx % groupSlots)
+			if slotIdx == 0 || it.group == nil {
+				// Look the group up only when crossing into a new one,
+				// or on the first entry of this table.
+				it.group = &it.tab.groups[entryIdx/groupSlots]
+			}
+			if !isFull(it.group.ctrls.get(slotIdx)) {
+				continue
+			}
+			key, elem := &it.group.slots[slotIdx].key, &it.group.slots[slotIdx].elem
+			if it.tab.index == -1 {
+				var ok bool
+				if key, elem, ok = it.grownKeyElem(key, elem); !ok {
+					continue // deleted since
+				}
+			}
+			it.entryIdx++
+			it.key, it.elem = key, elem
+			return
+		}
+	}
+	it.key, it.elem = nil, nil
+}
+
+// nextDirIdx moves to the next table, skipping the other directory
+// entries that point to the table just finished. If that table has been
+// split since, the skip also covers the tables it was split into,
+// whose keys it has already returned.
+func (it *Iter[K, V]) nextDirIdx() {
+	entries := 1 << (it.m.globalDepth - it.tab.localDepth)
+	it.dirIdx += entries
+	it.tab = nil
+	it.group = nil
+	it.entryIdx = 0
+}
+
+// grownKeyElem looks a key found in a stale group up in the live map.
+// A key that is not found has been deleted, with one exception: keys
+// that are not equal to themselves (NaNs) can never be found. They also
+// cannot be updated or deleted, except by clear, so unless the map has
+// been cleared the old slot still holds the live entry.
+func (it *Iter[K, V]) grownKeyElem(key *K, elem *V) (*K, *V, bool) {
+	if k, e, ok := it.m.getWithKey(*key); ok {
+		return k, e, true
+	}
+	if it.clearSeq == it.m.clearSeq && *key != *key {
+		return key, elem, true
+	}
+	return nil, nil, false
+}
+
+// Range calls f for each key and element, in the randomized order of a
+// range loop, until f returns false. As with range over a builtin map,
+// f may insert and delete entries.
+func (m *Map[K, V]) Range(f func(key K, elem V) bool) {
+	it := m.Iter()
+	for it.Next() {
+		if !f(it.Key(), it.Elem()) {
+			return
+		}
+	}
+}
+
+// AppendKeys appends the keys of m to s in map order and returns the
+// extended slice, like append(s, slices.Collect(maps.Keys(m))...).
+func (m *Map[K, V]) AppendKeys(s []K) []K {
+	s = slices.Grow(s, m.used)
+	for it := m.Iter(); it.Next(); {
+		s = append(s, it.Key())
+	}
+	return s
+}
+
+// AppendValues appends the elements of m to s in map order and returns
+// the extended slice.
+func (m *Map[K, V]) AppendValues(s []V) []V {
+	s = slices.Grow(s, m.used)
+	for it := m.Iter(); it.Next(); {
+		s = append(s, it.Elem())
+	}
+	return s
+}
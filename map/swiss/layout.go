@@ -0,0 +1,108 @@
+package swiss
+
+import "fmt"
+
+// Layout is a snapshot of the internals of a Map: the small group, or
+// the directory and every table it points to. It copies everything it
+// reports, so it stays valid after the map changes. Package hmapviz
+// renders it.
+type Layout struct {
+	Used        int
+	Seed        uintptr
+	GlobalDepth uint8
+
+	// Small is the single group of a small map, nil otherwise.
+	Small *Group
+
+	// Directory has one entry per directory slot: the index in Tables
+	// of the table it points to.
+	Directory []int
+
+	// Tables are the distinct tables, in directory order.
+	Tables []Table
+}
+
+// Table is the snapshot of one table.
+type Table struct {
+	Index      int // first directory entry pointing to the table
+	LocalDepth uint8
+	Capacity   int
+	Used       int
+	GrowthLeft int
+	Tombstones int
+	Groups     []Group
+}
+
+// Group is the snapshot of one group.
+type Group struct {
+	Ctrl []uint8
+	// Keys holds fmt.Sprint of the key in each full slot, "" elsewhere.
+	Keys []string
+}
+
+// Layout returns a snapshot of the internals of m.
+func (m *Map[K, V]) Layout() *Layout {
+	l := &Layout{Used: m.used, Seed: m.seed, GlobalDepth: m.globalDepth}
+	if m.small != nil {
+		g := groupOf(m.small)
+		l.Small = &g
+	}
+	for i, t := range m.directory {
+		if i == 0 || t != m.directory[i-1] {
+			s := Table{
+				Index:      t.index,
+				LocalDepth: t.localDepth,
+				Capacity:   int(t.capacity),
+				Used:       int(t.used),
+				GrowthLeft: int(t.growthLeft),
+				Tombstones: t.tombstones(),
+				Groups:     make([]Group, len(t.groups)),
+			}
+			for j := range t.groups {
+				s.Groups[j] = groupOf(&t.groups[j])
+			}
+			l.Tables = append(l.Tables, s)
+		}
+		l.Directory = append(l.Directory, len(l.Tables)-1)
+	}
+	return l
+}
+
+func groupOf[K comparable, V any](g *group[K, V]) Group {
+	s := Group{Ctrl: make([]uint8, groupSlots), Keys: make([]string, groupSlots)}
+	for i := range uintptr(groupSlots) {
+		c := g.ctrls.get(i)
+		s.Ctrl[i] = uint8(c)
+		if isFull(c) {
+			s.Keys[i] = fmt.Sprint(g.slots[i].key)
+		}
+	}
+	return s
+}
+
+// Ctrl classifies a control byte.
+type Ctrl uint8
+
+const (
+	CtrlEmpty   Ctrl = Ctrl(ctrlEmpty)
+	CtrlDeleted Ctrl = Ctrl(ctrlDeleted)
+	CtrlFull    Ctrl = 0
+)
+
+// CtrlOf returns the state a control byte encodes.
+func CtrlOf(c uint8) Ctrl {
+	if isFull(ctrl(c)) {
+		return CtrlFull
+	}
+	return Ctrl(c)
+}
+
+func (c Ctrl) String() string {
+	switch c {
+	case CtrlEmpty:
+		return "empty"
+	case CtrlDeleted:
+		return "deleted"
+	}
+	return "full"
+}
@@ -0,0 +1,446 @@
+package swiss
+
+import (
+	"math/bits"
+	"math/rand/v2"
+	"reflect"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/internal/mapopt"
+	"github.com/ProsperousLi/golang-deep-learn/map/internal/rtalg"
+)
+
+// maptype holds what the runtime keeps in abi.SwissMapType for one map
+// type: the key hasher and the key flags.
+type maptype[K comparable] struct {
+	hasher func(key K, seed uintptr) uintptr
+
+	needKeyUpdate  bool // t.NeedKeyUpdate()
+	hashMightPanic bool // t.HashMightPanic()
+}
+
+// Map is a Swiss-table hash map with the layout and growth behaviour of
+// the builtin map since go1.24. The zero Map is not usable; create one
+// with New. Like the builtin map, a Map must not be written concurrently
+// with any other access; doing so panics with "concurrent map writes"
+// when detected.
+/*
+	Map 对应 runtime 的 maps.Map。三种状态：
+	  - small == nil 且 directory == nil：还没有分配；
+	  - small != nil：小 map，只有一个 group，最多 8 个元素；
+	  - directory != nil：完整的 map，目录项指向表，多个目录项可以指向同一张表。
+*/
+type Map[K comparable, V any] struct {
+	typ *maptype[K]
+
+	// used is the number of entries, len(m).
+	used int
+
+	// seed is the hash seed (h.hash0 in the classic map).
+	seed uintptr
+
+	// small is the single group of a small map, nil otherwise. The
+	// runtime keeps it in dirPtr and tells the cases apart by dirLen == 0.
+	small *group[K, V]
+
+	// directory holds 1<<globalDepth table pointers, indexed by the top
+	// globalDepth bits of the hash. A table with localDepth < globalDepth
+	// appears in several consecutive entries.
+	directory   []*table[K, V]
+	globalDepth uint8
+	globalShift uint8 // bits.UintSize - globalDepth
+
+	// writing is set while a write is in progress, like hashWriting.
+	writing uint8
+
+	// clearSeq counts the Clears of a full map. Iterators use it to tell
+	// whether a NaN key they saw may still be in the map.
+	clearSeq uint64
+
+	rand  rand.Source // source of random numbers when non-nil; see WithRand
+	trace *tracer     // non-nil when created with WithTracer
+}
+
+// New returns an empty map with room for about hint elements,
+// like make(map[K]V, hint), configured by opts.
+func New[K comparable, V any](hint int, opts ...Option) *Map[K, V] {
+	c := newConfig(opts)
+	m := &Map[K, V]{rand: c.rand}
+	m.typ = newMaptype[K](rtalg.Alg{Mem: mapopt.Memhash(c.hasher), Rand: func() uint32 { return uint32(m.random()) }})
+	if c.tracer != nil {
+		m.trace = &tracer{t: c.tracer}
+	}
+	m.init(hint)
+	return m
+}
+
+func newMaptype[K comparable](alg rtalg.Alg) *maptype[K] {
+	kt := reflect.TypeFor[K]()
+	return &maptype[K]{
+		hasher:         rtalg.HasherFor[K](alg),
+		needKeyUpdate:  rtalg.NeedKeyUpdate(kt),
+		hashMightPanic: rtalg.HashMightPanic(kt),
+	}
+}
+
+// init is NewMap: it picks the seed and, for a hint above one group,
+// allocates a directory of tables big enough to hold hint entries
+// without growing.
+func (m *Map[K, V]) init(hint int) {
+	m.seed = uintptr(m.random())
+	if hint <= groupSlots {
+		// A small map can fill all 8 slots, and the group is allocated
+		// by the first insert anyway, so there is nothing to do.
+		return
+	}
+
+	// Full size map. Set the capacity to hold hint entries without
+	// growing in the average case.
+	targetCapacity := uint64(hint) * groupSlots / maxAvgGroupLoad
+	if targetCapacity < uint64(hint) { // overflow
+		return
+	}
+	dirSize := alignUpPow2((targetCapacity + maxTableCapacity - 1) / maxTableCapacity)
+	if dirSize > 1<<(bits.UintSize-8) {
+		// Obviously too large; the runtime panics with
+		// "makemap: size out of range" via makeBucketArray here.
+		panic(plainError("makemap: size out of range"))
+	}
+
+	m.globalDepth = uint8(bits.TrailingZeros64(dirSize))
+	m.globalShift = depthToShift(m.globalDepth)
+	m.directory = make([]*table[K, V], dirSize)
+	for i := range m.directory {
+		m.directory[i] = newTable[K, V](targetCapacity/dirSize, i, m.globalDepth)
+	}
+}
+
+func depthToShift(depth uint8) uint8 {
+	return uint8(bits.UintSize) - depth
+}
+
+// directoryIndex selects the directory entry for hash by its top
+// globalDepth bits. (旧实现用 hash 的低 B 位选桶，这里用高位选表)
+func (m *Map[K, V]) directoryIndex(hash uintptr) uintptr {
+	if len(m.directory) == 1 {
+		return 0
+	}
+	return hash >> (m.globalShift & (bits.UintSize - 1))
+}
+
+// replaceTable points every directory entry of nt's range at nt.
+func (m *Map[K, V]) replaceTable(nt *table[K, V]) {
+	// The number of entries that reference the same table doubles for
+	// each time the globalDepth grows without the table splitting.
+	entries := 1 << (m.globalDepth - nt.localDepth)
+	for i := range entries {
+		m.directory[nt.index+i] = nt
+	}
+}
+
+// installTableSplit replaces old by left and right, doubling the
+// directory first if old was already using every bit of it.
+/*
+	old.localDepth == globalDepth 说明 old 只占一个目录项，没法再分给两张表，
+	先把目录翻倍（每项复制一份），然后 left/right 各占 old 原来那段目录项的一半。
+*/
+func (m *Map[K, V]) installTableSplit(old, left, right *table[K, V]) {
+	if old.localDepth == m.globalDepth {
+		// No room for another level in the directory. Grow it.
+		newDir := make([]*table[K, V], len(m.directory)*2)
+		for i, t := range m.directory {
+			newDir[2*i] = t
+			newDir[2*i+1] = t
+			// t may appear at several indices. Its index only ever
+			// doubles, so seeing the original index means this is
+			// the first time we meet t.
+			if t.index == i {
+				t.index = 2 * i
+			}
+		}
+		m.globalDepth++
+		m.globalShift--
+		m.directory = newDir
+	}
+
+	// left and right may still span several entries if the directory
+	// has grown several times since old was last split.
+	left.index = old.index
+	m.replaceTable(left)
+
+	entries := 1 << (m.globalDepth - left.localDepth)
+	right.index = left.index + entries
+	m.replaceTable(right)
+}
+
+// getWithKey looks key up in a small map or in the table for its hash.
+func (m *Map[K, V]) getWithKey(key K) (*K, *V, bool) {
+	if m.used == 0 {
+		if m.typ.hashMightPanic {
+			m.typ.hasher(key, 0) // see issue 23734
+		}
+		return nil, nil, false
+	}
+	if m.writing != 0 {
+		fatal("concurrent map read and map write")
+	}
+	hash := m.typ.hasher(key, m.seed)
+	if m.directory == nil {
+		return m.getWithKeySmall(hash, key)
+	}
+	return m.directory[m.directoryIndex(hash)].getWithKey(hash, key)
+}
+
+// getWithKeySmall scans the small group. It checks the control bytes one
+// by one rather than with matchH2, which would need a full-word compare
+// per call for at most 8 slots.
+func (m *Map[K, V]) getWithKeySmall(hash uintptr, key K) (*K, *V, bool) {
+	g := m.small
+	h := ctrl(h2(hash))
+	ctrls := g.ctrls
+	for i := range groupSlots {
+		c := ctrl(ctrls)
+		ctrls >>= 8
+		if c != h {
+			continue
+		}
+		if s := &g.slots[i]; key == s.key {
+			return &s.key, &s.elem, true
+		}
+	}
+	return nil, nil, false
+}
+
+// PutSlot returns the element slot for key, inserting key if needed.
+// It is mapassign.
+func (m *Map[K, V]) PutSlot(key K) *V {
+	if m.writing != 0 {
+		fatal("concurrent map writes")
+	}
+	hash := m.typ.hasher(key, m.seed)
+
+	// Set writing after calling hasher, since hasher may panic, in
+	// which case we have not actually done a write.
+	m.writing ^= 1
+	if m.trace != nil {
+		m.trace.begin("mapassign")
+	}
+
+	if m.small == nil && m.directory == nil {
+		m.growToSmall()
+	}
+
+	if m.directory == nil {
+		if m.used < groupSlots {
+			elem := m.putSlotSmall(hash, key)
+			m.doneWriting()
+			return elem
+		}
+		// Can't fit another entry, grow to a full size map.
+		m.growToTable()
+	}
+
+	for {
+		elem, ok := m.directory[m.directoryIndex(hash)].PutSlot(m, hash, key)
+		if !ok {
+			// The table was rehashed; the key's table may have changed.
+			continue
+		}
+		m.doneWriting()
+		return elem
+	}
+}
+
+func (m *Map[K, V]) doneWriting() {
+	if m.writing == 0 {
+		fatal("concurrent map writes")
+	}
+	m.writing ^= 1
+}
+
+func (m *Map[K, V]) putSlotSmall(hash uintptr, key K) *V {
+	g := m.small
+	match := g.ctrls.matchH2(h2(hash))
+	for match != 0 {
+		i := match.first()
+		if s := &g.slots[i]; key == s.key {
+			if m.typ.needKeyUpdate {
+				s.key = key
+			}
+			return &s.elem
+		}
+		match = match.removeFirst()
+	}
+
+	// A small map never has tombstones (see deleteSmall), so empty or
+	// deleted is the same as empty here, and cheaper to compute.
+	match = g.ctrls.matchEmptyOrDeleted()
+	if match == 0 {
+		fatal("no space left in small map")
+	}
+	i := match.first()
+	g.slots[i].key = key
+	g.ctrls.set(i, ctrl(h2(hash)))
+	m.used++
+	return &g.slots[i].elem
+}
+
+func (m *Map[K, V]) growToSmall() {
+	m.small = new(group[K, V])
+	m.small.ctrls.setEmpty()
+}
+
+// growToTable moves the 8 entries of a small map into a table of two
+// groups, the first directory with a single entry.
+func (m *Map[K, V]) growToTable() {
+	tab := newTable[K, V](2*groupSlots, 0, 0)
+	g := m.small
+	for i := range uintptr(groupSlots) {
+		if !isFull(g.ctrls.get(i)) {
+			continue
+		}
+		s := &g.slots[i]
+		tab.uncheckedPutSlot(m.typ.hasher(s.key, m.seed), s.key, s.elem)
+	}
+	m.small = nil
+	m.directory = []*table[K, V]{tab}
+	m.globalDepth = 0
+	m.globalShift = depthToShift(m.globalDepth)
+	if m.trace != nil {
+		m.trace.emit(&SmallGrow{Used: int(tab.used), Capacity: int(tab.capacity)})
+	}
+}
+
+// delete is mapdelete.
+func (m *Map[K, V]) delete(key K) {
+	if m.used == 0 {
+		if m.typ.hashMightPanic {
+			m.typ.hasher(key, 0) // see issue 23734
+		}
+		return
+	}
+	if m.writing != 0 {
+		fatal("concurrent map writes")
+	}
+	hash := m.typ.hasher(key, m.seed)
+	m.writing ^= 1
+	if m.trace != nil {
+		m.trace.begin("mapdelete")
+	}
+
+	if m.directory == nil {
+		m.deleteSmall(hash, key)
+	} else {
+		m.directory[m.directoryIndex(hash)].Delete(m, hash, key)
+	}
+
+	if m.used == 0 {
+		// Reset the hash seed to make it more difficult for attackers
+		// to repeatedly trigger hash collisions. See issue 25237.
+		m.seed = uintptr(m.random())
+	}
+	m.doneWriting()
+}
+
+func (m *Map[K, V]) deleteSmall(hash uintptr, key K) {
+	g := m.small
+	match := g.ctrls.matchH2(h2(hash))
+	for match != 0 {
+		i := match.first()
+		if s := &g.slots[i]; key == s.key {
+			m.used--
+			*s = slot[K, V]{}
+			// There is only one group, so no probe sequence passes
+			// through it, and the slot can be reused at once.
+			g.ctrls.set(i, ctrlEmpty)
+			return
+		}
+		match = match.removeFirst()
+	}
+}
+
+// clear is mapclear. Unlike the classic map it keeps the directory and
+// every table, emptied, so the memory of a large map is not released.
+func (m *Map[K, V]) clear() {
+	if m.used == 0 {
+		return
+	}
+	if m.writing != 0 {
+		fatal("concurrent map writes")
+	}
+	m.writing ^= 1
+	if m.trace != nil {
+		m.trace.begin("mapclear")
+	}
+
+	if m.directory == nil {
+		*m.small = group[K, V]{}
+		m.small.ctrls.setEmpty()
+	} else {
+		var last *table[K, V]
+		for _, t := range m.directory {
+			if t == last {
+				continue
+			}
+			t.Clear()
+			last = t
+		}
+		m.clearSeq++
+	}
+	m.used = 0
+	m.seed = uintptr(m.random())
+	m.doneWriting()
+}
+
+// clone is mapclone: a deep copy that keeps the directory shape, so the
+// copy grows and splits exactly as the original would.
+func (m *Map[K, V]) clone() *Map[K, V] {
+	c := &Map[K, V]{
+		used:        m.used,
+		seed:        m.seed,
+		globalDepth: m.globalDepth,
+		globalShift: m.globalShift,
+		rand:        m.rand,
+	}
+	c.typ = m.typ
+	if m.small != nil {
+		g := *m.small
+		c.small = &g
+	}
+	if m.directory != nil {
+		c.directory = make([]*table[K, V], len(m.directory))
+		for i, t := range m.directory {
+			if i > 0 && t == m.directory[i-1] {
+				c.directory[i] = c.directory[i-1]
+				continue
+			}
+			nt := *t
+			nt.groups = append([]group[K, V](nil), t.groups...)
+			c.directory[i] = &nt
+		}
+	}
+	return c
+}
+
+// random stands in for the runtime's rand(). A map with its own source
+// (WithRand, WithSeed or HMAPSEED) draws from it instead.
+func (m *Map[K, V]) random() uint64 {
+	if m.rand != nil {
+		return m.rand.Uint64()
+	}
+	return rtalg.Fastrand64()
+}
+
+// A plainError is the panic value for misuse of a map, like runtime.plainError.
+type plainError string
+
+func (e plainError) Error() string { return string(e) }
+
+// fatal reports a map misuse the runtime would treat as unrecoverable.
+func fatal(s string) {
+	panic(plainError(s))
+}
+
+// throw reports a broken internal invariant.
+func throw(s string) {
+	panic(plainError("swiss: " + s))
+}
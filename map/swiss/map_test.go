@@ -0,0 +1,199 @@
+package swiss
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hmap"
+)
+
+// entries returns the entries of a range loop over m, keyed by the bits
+// of the key so that -0 and NaN count, with how often each came up.
+func entries(m *Map[float64, int]) map[[2]uint64]int {
+	got := make(map[[2]uint64]int)
+	m.Range(func(k float64, v int) bool {
+		got[[2]uint64{math.Float64bits(k), uint64(v)}]++
+		return true
+	})
+	return got
+}
+
+func checkSame(t *testing.T, step int, m *Map[float64, int], model map[float64]int) {
+	t.Helper()
+	if m.Len() != len(model) {
+		t.Fatalf("step %d: Len() = %d, builtin len %d", step, m.Len(), len(model))
+	}
+	want := make(map[[2]uint64]int)
+	for k, v := range model {
+		want[[2]uint64{math.Float64bits(k), uint64(v)}]++
+	}
+	got := entries(m)
+	if len(got) != len(want) {
+		t.Fatalf("step %d: range produced %d distinct entries, builtin %d", step, len(got), len(want))
+	}
+	for e, n := range want {
+		if got[e] != n {
+			t.Fatalf("step %d: range produced %v: %d %d times, builtin %d", step, math.Float64frombits(e[0]), e[1], got[e], n)
+		}
+	}
+}
+
+// kinds counts the events of a map by kind.
+type kinds map[string]int
+
+func (k kinds) Trace(e hmap.Event) { k[e.Kind()]++ }
+
+// TestDifferential runs random writes against a Map and a builtin map
+// and compares them, over enough keys that tables grow and split and
+// the directory doubles, and through Clear and Clone.
+func TestDifferential(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	events := make(kinds)
+	m := New[float64, int](0, WithSeed(3), WithTracer(events))
+	model := make(map[float64]int)
+	for i := range 60000 {
+		k := float64(r.IntN(4000))
+		switch x := r.IntN(1000); {
+		case x < 5:
+			k = math.NaN()
+		case x < 15:
+			k = math.Copysign(0, -1)
+		}
+		switch x := r.IntN(100); {
+		case x < 60:
+			m.Put(k, i)
+			model[k] = i
+		case x < 85:
+			m.Delete(k)
+			delete(model, k)
+		default:
+			got, ok := m.Lookup(k)
+			want, wok := model[k]
+			if got != want || ok != wok {
+				t.Fatalf("step %d: m[%v] = %d, %v; builtin %d, %v", i, k, got, ok, want, wok)
+			}
+		}
+		switch {
+		case i%20000 == 19999:
+			m.Clear()
+			clear(model)
+		case i%5000 == 2500:
+			// Go on with the clone, after checking that a write to it
+			// leaves the original alone.
+			cm := m.Clone()
+			checkSame(t, i, cm, model)
+			cm.Put(-1, -1)
+			checkSame(t, i, m, model)
+			cm.Delete(-1)
+			m = cm
+		}
+		if i%500 == 0 {
+			checkSame(t, i, m, model)
+		}
+	}
+	checkSame(t, -1, m, model)
+	for _, k := range []string{"smallgrow", "tablegrow", "split", "dirgrow"} {
+		if events[k] == 0 {
+			t.Errorf("no %s event: %v", k, events)
+		}
+	}
+}
+
+// TestNegativeZero checks that putting -0 over +0 replaces the key, as
+// it does in the builtin map, in a small map and after the tables grow.
+func TestNegativeZero(t *testing.T) {
+	for _, n := range []int{0, 3000} {
+		m := New[float64, int](0)
+		for i := 1; i <= n; i++ {
+			m.Put(float64(i), i)
+		}
+		m.Put(0, 1)
+		m.Put(math.Copysign(0, -1), 2)
+		zeros := 0
+		m.Range(func(k float64, v int) bool {
+			if k == 0 {
+				zeros++
+				if !math.Signbit(k) || v != 2 {
+					t.Errorf("%d entries: key %v: %d, want -0: 2", n, k, v)
+				}
+			}
+			return true
+		})
+		if zeros != 1 {
+			t.Errorf("%d entries: %d zero keys, want 1", n, zeros)
+		}
+	}
+}
+
+// TestNaN checks that every NaN is a new key that no lookup finds and no
+// delete removes, and that Clear drops them all.
+func TestNaN(t *testing.T) {
+	m := New[float64, int](0)
+	for i := range 2000 {
+		m.Put(math.NaN(), i)
+	}
+	m.Delete(math.NaN())
+	if m.Len() != 2000 {
+		t.Fatalf("Len() = %d, want 2000", m.Len())
+	}
+	if _, ok := m.Lookup(math.NaN()); ok {
+		t.Error("Lookup(NaN) found an entry")
+	}
+	seen := make(map[int]bool)
+	m.Range(func(k float64, v int) bool {
+		if !math.IsNaN(k) || seen[v] {
+			t.Errorf("range produced %v: %d", k, v)
+		}
+		seen[v] = true
+		return true
+	})
+	if len(seen) != 2000 {
+		t.Errorf("range produced %d entries, want 2000", len(seen))
+	}
+	m.Clear()
+	if m.Len() != 0 || len(entries(m)) != 0 {
+		t.Errorf("Len() after Clear = %d", m.Len())
+	}
+}
+
+// TestIterWhileWriting deletes and inserts at every step of an
+// iteration, enough to grow and split the tables under it. No key may
+// come up twice, no key deleted before the iterator reached it may come
+// up at all, and every key present throughout must come up once.
+func TestIterWhileWriting(t *testing.T) {
+	for seed := range uint64(20) {
+		r := rand.New(rand.NewPCG(seed, 0))
+		m := New[int, int](0, WithSeed(seed))
+		const n = 2000
+		for i := range n {
+			m.Put(i, i)
+		}
+		seen := make(map[int]bool)
+		deleted := make(map[int]bool)
+		next := n
+		for it := m.Iter(); it.Next(); {
+			k := it.Key()
+			if seen[k] || deleted[k] {
+				t.Fatalf("seed %d: iterator produced %d again or after it was deleted", seed, k)
+			}
+			if k < n && it.Elem() != k {
+				t.Fatalf("seed %d: iterator produced %d: %d", seed, k, it.Elem())
+			}
+			seen[k] = true
+			if d := r.IntN(n); !seen[d] {
+				m.Delete(d)
+				deleted[d] = true
+			}
+			for range 3 {
+				m.Put(next, next)
+				next++
+			}
+		}
+		for i := range n {
+			if !deleted[i] && !seen[i] {
+				t.Fatalf("seed %d: iterator missed %d", seed, i)
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package swiss
+
+import (
+	"math/rand/v2"
+
+	"github.com/ProsperousLi/golang-deep-learn/map/hasher"
+	"github.com/ProsperousLi/golang-deep-learn/map/hmap"
+	"github.com/ProsperousLi/golang-deep-learn/map/internal/mapopt"
+)
+
+// An Option configures a Map created by New. The options are those of
+// package hmap, so that the two maps can be set up the same way.
+type Option func(*config)
+
+type config struct {
+	tracer hmap.Tracer
+	rand   rand.Source
+	hasher hasher.Hasher
+}
+
+func newConfig(opts []Option) *config {
+	c := new(config)
+	if seed, ok := mapopt.EnvSeed(); ok {
+		c.rand = rand.NewPCG(seed, 0)
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// WithTracer reports the table growth events of the map to t, in the
+// same stream format as hmap's events; see TableGrow. Clones of the map
+// are not traced.
+func WithTracer(t hmap.Tracer) Option {
+	return func(c *config) { c.tracer = t }
+}
+
+// WithHasher replaces memhash with h, as hmap.WithHasher does.
+func WithHasher(h hasher.Hasher) Option {
+	return func(c *config) { c.hasher = h }
+}
+
+// WithRand makes src the map's only source of randomness: the hash seed
+// at creation and whenever Clear or the last Delete reseeds it, the
+// directory and slot offsets of every iteration and the hashes of NaN
+// keys. With a deterministic src the same sequence of operations gives
+// the same tables and iteration order on every run. Clones share src.
+func WithRand(src rand.Source) Option {
+	return func(c *config) { c.rand = src }
+}
+
+// WithSeed is WithRand with a PCG generator seeded by seed.
+func WithSeed(seed uint64) Option {
+	return WithRand(rand.NewPCG(seed, 0))
+}
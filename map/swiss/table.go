@@ -0,0 +1,327 @@
+package swiss
+
+import "math/bits"
+
+// A table is an open-addressed hash table of groups with at most
+// maxTableCapacity slots. It is what a directory entry points to.
+type table[K comparable, V any] struct {
+	// used is the number of full slots.
+	used uint16
+
+	// capacity is the number of slots, a power of two >= groupSlots.
+	capacity uint16
+
+	// growthLeft is the number of empty slots that may still be filled
+	// before the table must be rehashed. It starts at 7/8 of capacity and
+	// is not given back by deletes that leave a tombstone, so tombstones
+	// count against the load factor until the next rehash drops them.
+	/*
+		growthLeft 是还能填多少个 empty slot。留下墓碑的删除不会归还它，
+		所以墓碑一直占着装载因子，直到下一次 rehash 把它们清掉。
+	*/
+	growthLeft uint16
+
+	// localDepth is the number of leading hash bits shared by every key
+	// in this table. The table fills 1<<(globalDepth-localDepth)
+	// consecutive directory entries.
+	localDepth uint8
+
+	// index is the first directory entry pointing to this table, or -1
+	// once the table has been replaced by a grow or split. Iterators
+	// use -1 to notice that the table they are walking is stale.
+	index int
+
+	groups []group[K, V]
+}
+
+func newTable[K comparable, V any](capacity uint64, index int, localDepth uint8) *table[K, V] {
+	if capacity < groupSlots {
+		capacity = groupSlots
+	}
+	if capacity > maxTableCapacity {
+		throw("initial table capacity too large")
+	}
+	t := &table[K, V]{index: index, localDepth: localDepth}
+	t.reset(uint16(alignUpPow2(capacity)))
+	return t
+}
+
+// reset replaces the groups with capacity empty slots.
+func (t *table[K, V]) reset(capacity uint16) {
+	t.groups = make([]group[K, V], capacity/groupSlots)
+	t.capacity = capacity
+	t.resetGrowthLeft()
+	for i := range t.groups {
+		t.groups[i].ctrls.setEmpty()
+	}
+}
+
+// resetGrowthLeft sets growthLeft for an empty table.
+func (t *table[K, V]) resetGrowthLeft() {
+	if t.capacity <= groupSlots {
+		// A single group can fill all but one slot: an empty slot must
+		// remain to terminate lookups.
+		t.growthLeft = t.capacity - 1
+	} else {
+		t.growthLeft = t.capacity * maxAvgGroupLoad / groupSlots
+	}
+}
+
+// tombstones counts the deleted slots: every slot taken from growthLeft
+// is either full or a tombstone.
+func (t *table[K, V]) tombstones() int {
+	initial := *t
+	initial.resetGrowthLeft()
+	return int(initial.growthLeft) - int(t.growthLeft) - int(t.used)
+}
+
+func (t *table[K, V]) lengthMask() uint64 {
+	return uint64(len(t.groups) - 1)
+}
+
+// getWithKey looks key up. The probe sequence ends at the first group
+// with an empty slot: had key been inserted, it would be there or earlier.
+func (t *table[K, V]) getWithKey(hash uintptr, key K) (*K, *V, bool) {
+	seq := makeProbeSeq(h1(hash), t.lengthMask())
+	for ; ; seq = seq.next() {
+		g := &t.groups[seq.offset]
+		match := g.ctrls.matchH2(h2(hash))
+		for match != 0 {
+			i := match.first()
+			if s := &g.slots[i]; key == s.key {
+				return &s.key, &s.elem, true
+			}
+			match = match.removeFirst()
+		}
+		if g.ctrls.matchEmpty() != 0 {
+			return nil, nil, false
+		}
+	}
+}
+
+// PutSlot returns the element slot for key, inserting key if needed.
+// If the table is out of room it rehashes itself (growing or splitting,
+// which replaces it in m's directory) and returns false; the caller must
+// then retry on whichever table now holds key's hash.
+/*
+	PutSlot 沿探测序列查找 key；途中记住第一个墓碑，到达有 empty 的 group 说明 key 不存在，
+	优先复用墓碑（不消耗 growthLeft），否则占用 empty slot。growthLeft 用完就 rehash，
+	调用方要重新从目录里找表再试一次（对比旧实现：hashGrow 之后 goto again）。
+*/
+func (t *table[K, V]) PutSlot(m *Map[K, V], hash uintptr, key K) (*V, bool) {
+	seq := makeProbeSeq(h1(hash), t.lengthMask())
+
+	var firstDeleted *group[K, V]
+	var firstDeletedSlot uintptr
+
+	for ; ; seq = seq.next() {
+		g := &t.groups[seq.offset]
+		match := g.ctrls.matchH2(h2(hash))
+		for match != 0 {
+			i := match.first()
+			if s := &g.slots[i]; key == s.key {
+				if m.typ.needKeyUpdate {
+					s.key = key
+				}
+				return &s.elem, true
+			}
+			match = match.removeFirst()
+		}
+
+		// No existing slot for this key in this group. Remember the
+		// first tombstone on the way, then stop at the first group
+		// with an empty slot: the key cannot be further along.
+		if firstDeleted == nil {
+			if match := g.ctrls.matchEmptyOrDeleted(); match != 0 {
+				if i := match.first(); g.ctrls.get(i) == ctrlDeleted {
+					firstDeleted, firstDeletedSlot = g, i
+				}
+			}
+		}
+		match = g.ctrls.matchEmpty()
+		if match == 0 {
+			continue
+		}
+		i := match.first()
+
+		// A tombstone can be reused without consuming growthLeft.
+		if firstDeleted != nil {
+			g, i = firstDeleted, firstDeletedSlot
+			t.growthLeft++ // will be decremented below to become a no-op.
+		}
+
+		if t.growthLeft > 0 {
+			s := &g.slots[i]
+			s.key = key
+			g.ctrls.set(i, ctrl(h2(hash)))
+			t.growthLeft--
+			t.used++
+			m.used++
+			return &s.elem, true
+		}
+
+		t.rehash(m)
+		return nil, false
+	}
+}
+
+// uncheckedPutSlot inserts a key known to be absent into a table known
+// to have room and no tombstones, as when rehashing.
+func (t *table[K, V]) uncheckedPutSlot(hash uintptr, key K, elem V) {
+	if t.growthLeft == 0 {
+		throw("invariant failed: growthLeft is unexpectedly 0")
+	}
+	seq := makeProbeSeq(h1(hash), t.lengthMask())
+	for ; ; seq = seq.next() {
+		g := &t.groups[seq.offset]
+		if match := g.ctrls.matchEmptyOrDeleted(); match != 0 {
+			i := match.first()
+			g.slots[i] = slot[K, V]{key, elem}
+			g.ctrls.set(i, ctrl(h2(hash)))
+			t.growthLeft--
+			t.used++
+			return
+		}
+	}
+}
+
+// Delete removes key from the table.
+func (t *table[K, V]) Delete(m *Map[K, V], hash uintptr, key K) {
+	seq := makeProbeSeq(h1(hash), t.lengthMask())
+	for ; ; seq = seq.next() {
+		g := &t.groups[seq.offset]
+		match := g.ctrls.matchH2(h2(hash))
+		for match != 0 {
+			i := match.first()
+			if s := &g.slots[i]; key == s.key {
+				t.used--
+				m.used--
+				*s = slot[K, V]{}
+
+				// Only a full group can be in the middle of a probe
+				// sequence, and a group stays full until the table is
+				// rehashed. If this group has an empty slot, no probe
+				// passes through it and the slot can simply become
+				// empty again. Otherwise a lookup for some other key
+				// may need to continue past it, so leave a tombstone.
+				/*
+					只有满的 group 会出现在探测序列中间。group 里还有 empty，说明没有探测会越过它，
+					直接置 empty；否则必须留下墓碑，不然后面 group 里的 key 就找不到了。
+					对比旧实现的 emptyOne/emptyRest：那里是为了让查找在桶链中提前结束。
+				*/
+				if g.ctrls.matchEmpty() != 0 {
+					g.ctrls.set(i, ctrlEmpty)
+					t.growthLeft++
+				} else {
+					g.ctrls.set(i, ctrlDeleted)
+				}
+				return
+			}
+			match = match.removeFirst()
+		}
+		if g.ctrls.matchEmpty() != 0 {
+			return
+		}
+	}
+}
+
+// Clear empties the table in place, tombstones included.
+func (t *table[K, V]) Clear() {
+	for i := range t.groups {
+		t.groups[i] = group[K, V]{}
+		t.groups[i].ctrls.setEmpty()
+	}
+	t.used = 0
+	t.resetGrowthLeft()
+}
+
+// rehash makes room in a full table: it doubles the table, or splits it
+// in two once it is at maxTableCapacity. Either way t is replaced in the
+// directory and marked stale.
+func (t *table[K, V]) rehash(m *Map[K, V]) {
+	// Like go1.24, always grow, even when much of the table is
+	// tombstones; later releases first try to reclaim those in place.
+	newCapacity := 2 * t.capacity
+	if newCapacity <= maxTableCapacity {
+		t.grow(m, newCapacity)
+		return
+	}
+	t.split(m)
+}
+
+// localDepthMask is the hash bit that decides which half of a split a
+// key goes to: the bit just below the localDepth bits the table's keys
+// share.
+func localDepthMask(localDepth uint8) uintptr {
+	return uintptr(1) << (bits.UintSize - int(localDepth))
+}
+
+// split moves the entries of t into two new tables of maxTableCapacity,
+// one for each value of the next hash bit.
+func (t *table[K, V]) split(m *Map[K, V]) {
+	localDepth := t.localDepth + 1
+	left := newTable[K, V](maxTableCapacity, -1, localDepth)
+	right := newTable[K, V](maxTableCapacity, -1, localDepth)
+
+	mask := localDepthMask(localDepth)
+	for i := range t.groups {
+		g := &t.groups[i]
+		for j := range uintptr(groupSlots) {
+			if !isFull(g.ctrls.get(j)) {
+				continue
+			}
+			s := &g.slots[j]
+			hash := m.typ.hasher(s.key, m.seed)
+			if hash&mask == 0 {
+				left.uncheckedPutSlot(hash, s.key, s.elem)
+			} else {
+				right.uncheckedPutSlot(hash, s.key, s.elem)
+			}
+		}
+	}
+
+	oldDepth := m.globalDepth
+	m.installTableSplit(t, left, right)
+	if m.trace != nil {
+		if m.globalDepth != oldDepth {
+			m.trace.emit(&DirectoryGrow{OldDepth: oldDepth, GlobalDepth: m.globalDepth, Len: len(m.directory)})
+		}
+		m.trace.emit(&TableSplit{
+			Index: left.index, LocalDepth: localDepth, Used: int(t.used),
+			Left: int(left.used), Right: int(right.used), Tombstones: t.tombstones(),
+		})
+	}
+	t.index = -1
+}
+
+// grow replaces t with a table of newCapacity holding the same entries.
+// Tombstones are dropped on the way.
+func (t *table[K, V]) grow(m *Map[K, V], newCapacity uint16) {
+	nt := newTable[K, V](uint64(newCapacity), t.index, t.localDepth)
+	for i := range t.groups {
+		g := &t.groups[i]
+		for j := range uintptr(groupSlots) {
+			if !isFull(g.ctrls.get(j)) {
+				continue
+			}
+			s := &g.slots[j]
+			nt.uncheckedPutSlot(m.typ.hasher(s.key, m.seed), s.key, s.elem)
+		}
+	}
+	m.replaceTable(nt)
+	if m.trace != nil {
+		m.trace.emit(&TableGrow{
+			Index: t.index, LocalDepth: t.localDepth, OldCapacity: int(t.capacity),
+			Capacity: int(newCapacity), Used: int(t.used), Tombstones: t.tombstones(),
+		})
+	}
+	t.index = -1
+}
+
+// alignUpPow2 rounds n up to a power of two.
+func alignUpPow2(n uint64) uint64 {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << bits.Len64(n-1)
+}
@@ -0,0 +1,76 @@
+package swiss
+
+import "github.com/ProsperousLi/golang-deep-learn/map/hmap"
+
+// The events of a traced Map. They implement hmap.Event, so hmap's
+// JSONTracer and any other hmap.Tracer accept them. There is no
+// equivalent of Evacuate or EvacuationMark: every rehash completes
+// inside the write that triggers it.
+/*
+	Swiss table 的扩容事件，同样实现 hmap.Event，可以直接交给 hmap.JSONTracer。
+	没有 evacuate/nevacuate：每次 rehash 都在触发它的那次写入里做完。
+*/
+
+// SmallGrow is emitted when a small map's single group overflows into
+// its first table.
+type SmallGrow struct {
+	hmap.EventHeader
+	Used     int `json:"used"`
+	Capacity int `json:"capacity"`
+}
+
+// TableGrow is emitted when a table doubles in place in the directory.
+// Tombstones is the number of deleted slots that were dropped.
+type TableGrow struct {
+	hmap.EventHeader
+	Index       int   `json:"index"`
+	LocalDepth  uint8 `json:"localDepth"`
+	OldCapacity int   `json:"oldCapacity"`
+	Capacity    int   `json:"capacity"`
+	Used        int   `json:"used"`
+	Tombstones  int   `json:"tombstones"`
+}
+
+// TableSplit is emitted when a table at maxTableCapacity is split into
+// two tables of LocalDepth, holding Left and Right of its Used entries.
+type TableSplit struct {
+	hmap.EventHeader
+	Index      int   `json:"index"`
+	LocalDepth uint8 `json:"localDepth"`
+	Used       int   `json:"used"`
+	Left       int   `json:"left"`
+	Right      int   `json:"right"`
+	Tombstones int   `json:"tombstones"`
+}
+
+// DirectoryGrow is emitted, before the TableSplit that needed it, when
+// the directory doubles.
+type DirectoryGrow struct {
+	hmap.EventHeader
+	OldDepth    uint8 `json:"oldDepth"`
+	GlobalDepth uint8 `json:"globalDepth"`
+	Len         int   `json:"len"`
+}
+
+func (*SmallGrow) Kind() string     { return "smallgrow" }
+func (*TableGrow) Kind() string     { return "tablegrow" }
+func (*TableSplit) Kind() string    { return "split" }
+func (*DirectoryGrow) Kind() string { return "dirgrow" }
+
+// tracer is the per-map tracing state hung off Map.trace.
+type tracer struct {
+	t   hmap.Tracer
+	seq uint64
+	op  string
+}
+
+// begin records the start of a write operation.
+func (tr *tracer) begin(op string) {
+	tr.seq++
+	tr.op = op
+}
+
+func (tr *tracer) emit(e hmap.Event) {
+	*e.Header() = hmap.EventHeader{Seq: tr.seq, Op: tr.op}
+	tr.t.Trace(e)
+}